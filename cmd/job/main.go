@@ -2,15 +2,23 @@ package main
 
 import (
 	"context"
+	"flag"
+	"fmt"
 	"log/slog"
 	"os"
+	"path/filepath"
 
 	"github.com/schraf/assistant/internal/config"
-	"github.com/schraf/assistant/internal/gemini"
+	_ "github.com/schraf/assistant/internal/gemini"
 	"github.com/schraf/assistant/internal/job"
 	"github.com/schraf/assistant/internal/log"
+	_ "github.com/schraf/assistant/internal/mocks"
 	"github.com/schraf/assistant/internal/notify"
+	_ "github.com/schraf/assistant/internal/ollama"
+	_ "github.com/schraf/assistant/internal/openai"
 	"github.com/schraf/assistant/internal/telegraph"
+	"github.com/schraf/assistant/pkg/cassette"
+	"github.com/schraf/assistant/pkg/providers"
 	_ "github.com/schraf/newspaper-assistant/pkg/generator"
 	_ "github.com/schraf/research-assistant/pkg/generator"
 )
@@ -27,21 +35,36 @@ func main() {
 		os.Exit(1)
 	}
 
-	// Create assistant
-	assistant, err := gemini.NewClient(ctx, 3)
+	replay := flag.String("replay", "", "Re-run a past request from its transcript, given as a file or a request id in TRANSCRIPT_DIR")
+	replayMatch := flag.String("replay-match", "request", "How calls are matched to the transcript: request, or sequence to ignore changed prompts")
+	flag.Parse()
+
+	if *replay != "" {
+		os.Exit(runReplay(ctx, logger, *replay, *replayMatch))
+	}
+
+	// Create assistant from the configured provider
+	providerName, providerConfig := providers.FromEnv()
+
+	assistant, err := providers.Create(ctx, providerName, providerConfig)
 	if err != nil {
 		logger.ErrorContext(ctx, "failed_creating_assistant",
+			slog.String("provider", providerName),
 			slog.String("error", err.Error()),
 		)
 		os.Exit(1)
 	}
 
+	logger.InfoContext(ctx, "using_provider",
+		slog.String("provider", providerName),
+	)
+
 	// Create dependencies
 	publisher := telegraph.NewPublisher()
 	notifier := notify.NewEmailNotifier()
 
 	// Create processor
-	processor := job.NewProcessor(assistant, publisher, notifier, logger)
+	processor := job.NewProcessor(providerName, assistant, publisher, notifier, logger)
 
 	// Process the job
 	if err := processor.Process(ctx); err != nil {
@@ -53,3 +76,81 @@ func main() {
 
 	os.Exit(0)
 }
+
+// runReplay re-runs a recorded request with every assistant call answered
+// from its transcript. The document is written to stdout rather than
+// published, and the notification is logged rather than sent.
+func runReplay(ctx context.Context, logger *slog.Logger, replay string, match string) int {
+	path := replay
+	if _, err := os.Stat(path); err != nil {
+		path = filepath.Join(os.Getenv("TRANSCRIPT_DIR"), replay+".jsonl")
+	}
+
+	replayMatch, err := parseMatch(match)
+	if err != nil {
+		logger.ErrorContext(ctx, "invalid_replay_match",
+			slog.String("error", err.Error()),
+		)
+		return 1
+	}
+
+	recorded, err := job.LoadReplay(path)
+	if err != nil {
+		logger.ErrorContext(ctx, "failed_loading_transcript",
+			slog.String("path", path),
+			slog.String("error", err.Error()),
+		)
+		return 1
+	}
+
+	if err := recorded.SetEnv(); err != nil {
+		logger.ErrorContext(ctx, "failed_setting_replay_env",
+			slog.String("error", err.Error()),
+		)
+		return 1
+	}
+
+	assistant, err := recorded.Assistant(replayMatch)
+	if err != nil {
+		logger.ErrorContext(ctx, "failed_creating_replay_assistant",
+			slog.String("error", err.Error()),
+		)
+		return 1
+	}
+
+	logger.InfoContext(ctx, "replaying_transcript",
+		slog.String("path", path),
+		slog.Int("calls", len(recorded.Calls)),
+	)
+
+	publisher := job.NewReplayPublisher(os.Stdout)
+	processor := job.NewProcessor(recorded.Provider(), assistant, publisher, job.NewReplayNotifier(logger), logger)
+
+	if err := processor.Process(ctx); err != nil {
+		logger.ErrorContext(ctx, "replay_failed",
+			slog.String("error", err.Error()),
+		)
+		return 1
+	}
+
+	logger.InfoContext(ctx, "replay_completed",
+		slog.Int("unused_calls", len(assistant.Unused())),
+		slog.Bool("recorded_document", recorded.Document != nil),
+		slog.Bool("document_matches", recorded.Matches(publisher.Document())),
+	)
+
+	return 0
+}
+
+// parseMatch reads the -replay-match flag. Strict matching is not offered,
+// as the transcript records the model before the model table resolves it.
+func parseMatch(match string) (cassette.Match, error) {
+	switch match {
+	case "request":
+		return cassette.MatchRequest, nil
+	case "sequence":
+		return cassette.MatchSequence, nil
+	default:
+		return 0, fmt.Errorf("unknown replay match %q", match)
+	}
+}
@@ -0,0 +1,136 @@
+package breaker
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrOpen is returned by callers that skip work because the circuit is open.
+var ErrOpen = errors.New("circuit open")
+
+type State int
+
+const (
+	// Closed lets every call through.
+	Closed State = iota
+	// Open rejects calls until the cooldown has passed.
+	Open
+	// HalfOpen lets a single trial call through after the cooldown. Its
+	// outcome closes or reopens the circuit.
+	HalfOpen
+)
+
+func (s State) String() string {
+	switch s {
+	case Closed:
+		return "closed"
+	case Open:
+		return "open"
+	case HalfOpen:
+		return "half-open"
+	default:
+		return "unknown"
+	}
+}
+
+// Breaker is a circuit breaker that opens after FailureThreshold consecutive
+// failures and stays open for Cooldown. It is safe for concurrent use.
+type Breaker struct {
+	FailureThreshold int
+	Cooldown         time.Duration
+
+	// OnStateChange, if set, is called whenever the state changes. It runs
+	// while the breaker is locked and must not call back into it.
+	OnStateChange func(from State, to State)
+
+	lock     sync.Mutex
+	state    State
+	failures int
+	openedAt time.Time
+	trial    bool
+}
+
+// Allow reports whether a call may go ahead. Every allowed call must be
+// followed by Success or Failure.
+func (b *Breaker) Allow() bool {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	switch b.state {
+	case Open:
+		if time.Since(b.openedAt) < b.Cooldown {
+			return false
+		}
+
+		b.setState(HalfOpen)
+		b.trial = true
+		return true
+	case HalfOpen:
+		// Only one trial call at a time
+		if b.trial {
+			return false
+		}
+
+		b.trial = true
+		return true
+	default:
+		return true
+	}
+}
+
+// Success records a successful call, closing the circuit.
+func (b *Breaker) Success() {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	b.failures = 0
+	b.trial = false
+	b.setState(Closed)
+}
+
+// Failure records a failed call. A failed trial reopens the circuit, as does
+// reaching the failure threshold.
+func (b *Breaker) Failure() {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	b.failures++
+	b.trial = false
+
+	if b.state == HalfOpen || b.failures >= max(b.FailureThreshold, 1) {
+		b.openedAt = time.Now()
+		b.setState(Open)
+	}
+}
+
+// Release ends an allowed call without judging the provider, for example
+// when the caller gave up. A pending trial can then be retried.
+func (b *Breaker) Release() {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	b.trial = false
+}
+
+// State returns the current state. An open circuit whose cooldown has passed
+// is still reported as open until the next call is allowed.
+func (b *Breaker) State() State {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	return b.state
+}
+
+func (b *Breaker) setState(state State) {
+	if b.state == state {
+		return
+	}
+
+	from := b.state
+	b.state = state
+
+	if b.OnStateChange != nil {
+		b.OnStateChange(from, state)
+	}
+}
@@ -0,0 +1,103 @@
+package breaker
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestBreaker_OpensAndRecovers(t *testing.T) {
+	var transitions []string
+
+	b := &Breaker{
+		FailureThreshold: 2,
+		Cooldown:         20 * time.Millisecond,
+		OnStateChange: func(from State, to State) {
+			transitions = append(transitions, to.String())
+		},
+	}
+
+	if !b.Allow() {
+		t.Fatal("a closed circuit should allow calls")
+	}
+	b.Failure()
+	if b.State() != Closed {
+		t.Errorf("one failure should not open the circuit, got %s", b.State())
+	}
+
+	if !b.Allow() {
+		t.Fatal("a closed circuit should allow calls")
+	}
+	b.Failure()
+	if b.State() != Open {
+		t.Errorf("state = %s, want open", b.State())
+	}
+	if b.Allow() {
+		t.Error("an open circuit should reject calls")
+	}
+
+	time.Sleep(30 * time.Millisecond)
+
+	if !b.Allow() {
+		t.Fatal("a trial call should be allowed after the cooldown")
+	}
+	if b.Allow() {
+		t.Error("only one trial call should be allowed")
+	}
+	b.Failure()
+	if b.State() != Open {
+		t.Errorf("a failed trial should reopen the circuit, got %s", b.State())
+	}
+
+	time.Sleep(30 * time.Millisecond)
+
+	if !b.Allow() {
+		t.Fatal("a trial call should be allowed after the cooldown")
+	}
+	b.Success()
+	if b.State() != Closed {
+		t.Errorf("state = %s, want closed", b.State())
+	}
+
+	expected := []string{"open", "half-open", "open", "half-open", "closed"}
+	if !reflect.DeepEqual(transitions, expected) {
+		t.Errorf("transitions = %v, want %v", transitions, expected)
+	}
+}
+
+func TestBreaker_Release(t *testing.T) {
+	b := &Breaker{FailureThreshold: 2, Cooldown: 20 * time.Millisecond}
+
+	// Released calls count neither way
+	for range 3 {
+		if !b.Allow() {
+			t.Fatal("a closed circuit should allow calls")
+		}
+		b.Release()
+	}
+
+	b.Allow()
+	b.Failure()
+	if b.State() != Closed {
+		t.Errorf("released calls should not count as failures, got %s", b.State())
+	}
+
+	b.Allow()
+	b.Failure()
+	if b.State() != Open {
+		t.Fatalf("state = %s, want open", b.State())
+	}
+
+	time.Sleep(30 * time.Millisecond)
+
+	if !b.Allow() {
+		t.Fatal("a trial call should be allowed after the cooldown")
+	}
+	b.Release()
+	if b.State() != HalfOpen {
+		t.Errorf("a released trial should leave the circuit half-open, got %s", b.State())
+	}
+	if !b.Allow() {
+		t.Error("a released trial should let another trial through")
+	}
+}
@@ -0,0 +1,61 @@
+// Package chatapi holds what the OpenAI and Ollama clients share. Both speak
+// a chat protocol of role tagged messages and function tools, posted as JSON
+// and retried on transient failures.
+package chatapi
+
+import "github.com/schraf/assistant/pkg/models"
+
+// Message is a chat message. The protocols differ in how tool calls are
+// encoded, so C is the client's tool call type. Fields a protocol does not
+// use are left empty and omitted.
+type Message[C any] struct {
+	Role       string   `json:"role"`
+	Content    string   `json:"content"`
+	Images     [][]byte `json:"images,omitempty"`
+	ToolCalls  []C      `json:"tool_calls,omitempty"`
+	ToolCallID string   `json:"tool_call_id,omitempty"`
+	ToolName   string   `json:"tool_name,omitempty"`
+}
+
+// NewMessages returns the persona as the system message followed by the
+// request.
+func NewMessages[C any](persona string, request string) []Message[C] {
+	return []Message[C]{
+		{
+			Role:    "system",
+			Content: persona,
+		},
+		{
+			Role:    "user",
+			Content: request,
+		},
+	}
+}
+
+// NewConversation returns the persona as the system message followed by
+// the conversation.
+func NewConversation[C any](persona string, messages []models.Message) []Message[C] {
+	conversation := make([]Message[C], 0, len(messages)+1)
+	conversation = append(conversation, Message[C]{
+		Role:    "system",
+		Content: persona,
+	})
+
+	for _, m := range messages {
+		conversation = append(conversation, Message[C]{
+			Role:    Role(m.Role),
+			Content: m.Content,
+		})
+	}
+
+	return conversation
+}
+
+// Role returns the protocol's name for a conversation role.
+func Role(role models.Role) string {
+	if role == models.RoleModel {
+		return "assistant"
+	}
+
+	return string(role)
+}
@@ -0,0 +1,128 @@
+package chatapi
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/schraf/assistant/internal/ratelimit"
+	"github.com/schraf/assistant/internal/retry"
+	"github.com/schraf/assistant/internal/usage"
+)
+
+// maxElapsed bounds the retries of a request, so a server that keeps
+// failing cannot hold a job indefinitely.
+const maxElapsed = 10 * time.Minute
+
+// StatusError is a non-200 response. Its status and Retry-After header are
+// exposed for retry.IsTransient and retry.RetryAfterHint.
+type StatusError struct {
+	Provider         string
+	Status           int
+	Body             string
+	RetryAfterHeader string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("%s API returned status %d: %s", e.Provider, e.Status, e.Body)
+}
+
+func (e *StatusError) StatusCode() int {
+	return e.Status
+}
+
+func (e *StatusError) RetryAfter() (time.Duration, bool) {
+	return retry.ParseRetryAfter(e.RetryAfterHeader)
+}
+
+// Poster posts requests to a chat endpoint.
+type Poster struct {
+	Provider   string
+	URL        string
+	Header     http.Header
+	HTTPClient *http.Client
+	MaxRetries int
+	Limiter    *ratelimit.Limiter
+
+	// IsRetryableError defaults to retry.IsTransient.
+	IsRetryableError func(error) bool
+}
+
+// Post sends the request as JSON, retrying transient failures after waiting
+// for the model's rate limit. Retries stop once a response arrives, so a
+// stream is never replayed. The caller closes the response body.
+func (p *Poster) Post(ctx context.Context, model string, request any) (*http.Response, error) {
+	reqBody, err := json.Marshal(request)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	var resp *http.Response
+
+	retryable := retry.Retryer{
+		MaxRetries:       p.MaxRetries,
+		InitialBackoff:   1 * time.Second,
+		MaxBackoff:       30 * time.Second,
+		Jitter:           retry.EqualJitter,
+		MaxElapsed:       maxElapsed,
+		IsRetryableError: p.IsRetryableError,
+		Attempt: func(retryCtx context.Context) error {
+			if err := p.Limiter.Wait(retryCtx, model); err != nil {
+				return err
+			}
+
+			// The response is read after Try returns and cancels retryCtx,
+			// so the request is sent with the caller's context
+			var err error
+			resp, err = p.post(ctx, reqBody)
+			return err
+		},
+	}
+
+	if err := retryable.Try(ctx); err != nil {
+		return nil, err
+	}
+
+	return resp, nil
+}
+
+func (p *Poster) post(ctx context.Context, reqBody []byte) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, "POST", p.URL, bytes.NewReader(reqBody))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	for name, values := range p.Header {
+		req.Header[name] = values
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := p.HTTPClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return nil, &StatusError{
+			Provider:         p.Provider,
+			Status:           resp.StatusCode,
+			Body:             string(body),
+			RetryAfterHeader: resp.Header.Get("Retry-After"),
+		}
+	}
+
+	return resp, nil
+}
+
+// RecordUsage records token usage and charges it to the rate limiter.
+func (p *Poster) RecordUsage(ctx context.Context, u usage.Usage) {
+	usage.Record(ctx, u)
+	p.Limiter.Record(u.Model, u.TotalTokens())
+}
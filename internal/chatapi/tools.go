@@ -0,0 +1,38 @@
+package chatapi
+
+import "github.com/schraf/assistant/pkg/models"
+
+// Tool declares a function the model may call.
+type Tool struct {
+	Type     string       `json:"type"`
+	Function ToolFunction `json:"function"`
+}
+
+type ToolFunction struct {
+	Name        string         `json:"name"`
+	Description string         `json:"description,omitempty"`
+	Parameters  map[string]any `json:"parameters,omitempty"`
+}
+
+// NewTools declares the tools as functions, or returns nil when there are
+// none so the tools field is left out of the request.
+func NewTools(tools []models.Tool) []Tool {
+	if len(tools) == 0 {
+		return nil
+	}
+
+	definitions := make([]Tool, 0, len(tools))
+
+	for _, t := range tools {
+		definitions = append(definitions, Tool{
+			Type: "function",
+			Function: ToolFunction{
+				Name:        t.Name,
+				Description: t.Description,
+				Parameters:  t.Parameters,
+			},
+		})
+	}
+
+	return definitions
+}
@@ -0,0 +1,27 @@
+package gemini
+
+import (
+	"context"
+
+	"github.com/schraf/assistant/pkg/models"
+	"google.golang.org/genai"
+)
+
+// requestParts returns the parts of a user turn: the text followed by the
+// attachments set on the context, sent inline.
+func requestParts(ctx context.Context, text string) []*genai.Part {
+	attachments := models.AttachmentsFromContext(ctx)
+	parts := make([]*genai.Part, 0, len(attachments)+1)
+	parts = append(parts, genai.NewPartFromText(text))
+
+	for _, attachment := range attachments {
+		parts = append(parts, genai.NewPartFromBytes(attachment.Data, attachment.MIMEType))
+	}
+
+	return parts
+}
+
+// requestContents returns a single user turn for GenerateContent.
+func requestContents(ctx context.Context, text string) []*genai.Content {
+	return []*genai.Content{genai.NewContentFromParts(requestParts(ctx, text), genai.RoleUser)}
+}
@@ -3,28 +3,46 @@ package gemini
 import (
 	"context"
 	"encoding/json"
-	"net/http"
+	"errors"
+	"strings"
 	"time"
 
+	"github.com/schraf/assistant/internal/ratelimit"
 	"github.com/schraf/assistant/internal/retry"
+	"github.com/schraf/assistant/internal/structured"
+	"github.com/schraf/assistant/internal/usage"
 	"github.com/schraf/assistant/pkg/models"
 	"github.com/schraf/syncext"
 	"google.golang.org/genai"
-	"google.golang.org/grpc/codes"
-	"google.golang.org/grpc/status"
+)
+
+const (
+	// Thinking models can take minutes on long prompts, so a single attempt
+	// gets a generous limit and all attempts together a little more.
+	attemptTimeout = 10 * time.Minute
+	maxElapsed     = 20 * time.Minute
 )
 
 type Client struct {
 	genaiClient *genai.Client
 	semaphore   *syncext.Semaphore
+	limiter     *ratelimit.Limiter
 }
 
+// NewClient creates a new Client making at most concurrency requests at a
+// time. GEMINI_REQUESTS_PER_MINUTE and GEMINI_TOKENS_PER_MINUTE optionally
+// keep each model under its quota.
 func NewClient(ctx context.Context, concurrency int) (*Client, error) {
 	sem, err := syncext.NewSemaphore(concurrency)
 	if err != nil {
 		return nil, err
 	}
 
+	limiter, err := ratelimit.FromEnv("GEMINI")
+	if err != nil {
+		return nil, err
+	}
+
 	genaiClient, err := genai.NewClient(ctx, nil)
 	if err != nil {
 		return nil, err
@@ -33,85 +51,181 @@ func NewClient(ctx context.Context, concurrency int) (*Client, error) {
 	return &Client{
 		genaiClient: genaiClient,
 		semaphore:   sem,
+		limiter:     limiter,
 	}, nil
 }
 
 func (c *Client) Ask(ctx context.Context, persona string, request string) (*string, error) {
-	if err := c.semaphore.Acquire(ctx); err != nil {
+	result, err := c.ask(ctx, persona, request)
+	if err != nil {
 		return nil, err
 	}
-	defer c.semaphore.Release()
 
-	config := &genai.GenerateContentConfig{
-		SystemInstruction: genai.NewContentFromText(persona, genai.RoleModel),
-		Tools: []*genai.Tool{
-			{
-				GoogleSearch: &genai.GoogleSearch{},
-				URLContext:   &genai.URLContext{},
-			},
-		},
+	responseText := result.Text()
+	return &responseText, nil
+}
+
+func (c *Client) AskWithSources(ctx context.Context, persona string, request string) (*models.Answer, error) {
+	result, err := c.ask(ctx, persona, request)
+	if err != nil {
+		return nil, err
 	}
 
-	result, err := c.generateContext(ctx, request, config)
+	return &models.Answer{
+		Text:       result.Text(),
+		References: references(result),
+	}, nil
+}
+
+func (c *Client) ask(ctx context.Context, persona string, request string) (*genai.GenerateContentResponse, error) {
+	cfg := askConfig(ctx, persona)
+	parts := requestParts(ctx, request)
+
+	result, err := c.generateTurns(ctx, cfg, parts, c.generateSender(cfg))
 	if err != nil {
 		return nil, err
 	}
 
-	if result.PromptFeedback != nil {
-		return nil, models.ErrContentBlocked
+	if err := checkResponse(result); err != nil {
+		return nil, err
 	}
 
-	responseText := result.Text()
-	return &responseText, nil
+	return result, nil
 }
 
-func (c *Client) StructuredAsk(ctx context.Context, persona string, request string, schema map[string]any) (json.RawMessage, error) {
+func (c *Client) AskStream(ctx context.Context, persona string, request string, handler models.StreamHandler) (*string, error) {
+	// The permit is held until the stream ends
 	if err := c.semaphore.Acquire(ctx); err != nil {
 		return nil, err
 	}
 	defer c.semaphore.Release()
 
-	config := &genai.GenerateContentConfig{
-		ResponseMIMEType:   "application/json",
-		ResponseJsonSchema: schema,
-		SystemInstruction:  genai.NewContentFromText(persona, genai.RoleModel),
-	}
+	var response strings.Builder
+
+	err := c.generateContextStream(ctx, request, askConfig(ctx, persona), func(result *genai.GenerateContentResponse) error {
+		// A truncated stream still delivers its last chunk before failing
+		var truncated *models.TruncatedError
+
+		if err := checkResponse(result); err != nil && !errors.As(err, &truncated) {
+			return err
+		}
+
+		if chunk := result.Text(); chunk != "" {
+			response.WriteString(chunk)
+
+			if err := handler(chunk); err != nil {
+				return err
+			}
+		}
+
+		if truncated != nil {
+			truncated.Text = response.String()
+			return truncated
+		}
+
+		return nil
+	})
 
-	result, err := c.generateContext(ctx, request, config)
 	if err != nil {
 		return nil, err
 	}
 
-	if result.PromptFeedback != nil {
-		return nil, models.ErrContentBlocked
+	responseText := response.String()
+	return &responseText, nil
+}
+
+func (c *Client) Chat(ctx context.Context, persona string, messages []models.Message) (*string, error) {
+	if err := models.ValidateMessages(messages); err != nil {
+		return nil, err
 	}
 
-	responseText := result.Text()
+	history := make([]*genai.Content, 0, len(messages)-1)
+	for _, message := range messages[:len(messages)-1] {
+		history = append(history, genai.NewContentFromText(message.Content, genai.Role(message.Role)))
+	}
 
-	var responseJson json.RawMessage
+	cfg := askConfig(ctx, persona)
+	parts := requestParts(ctx, messages[len(messages)-1].Content)
 
-	err = json.Unmarshal([]byte(responseText), &responseJson)
+	result, err := c.generateTurns(ctx, cfg, parts, c.chatSender(cfg, history))
 	if err != nil {
 		return nil, err
 	}
 
-	return responseJson, nil
+	if err := checkResponse(result); err != nil {
+		return nil, err
+	}
+
+	responseText := result.Text()
+	return &responseText, nil
+}
+
+func (c *Client) StructuredAsk(ctx context.Context, persona string, request string, schema map[string]any) (json.RawMessage, error) {
+	// Structured prompts are only grounded when asked for
+	config := &genai.GenerateContentConfig{
+		ResponseMIMEType:   "application/json",
+		ResponseJsonSchema: schema,
+		SystemInstruction:  genai.NewContentFromText(persona, genai.RoleModel),
+		Tools:              builtinTools(ctx),
+	}
+
+	return structured.Ask(ctx, request, schema, func(ctx context.Context, request string) (string, error) {
+		result, err := c.generateContext(ctx, request, config)
+		if err != nil {
+			return "", err
+		}
+
+		if err := checkResponse(result); err != nil {
+			return "", err
+		}
+
+		return result.Text(), nil
+	})
 }
 
 func (c *Client) generateContext(ctx context.Context, request string, cfg *genai.GenerateContentConfig) (*genai.GenerateContentResponse, error) {
+	prompt := requestContents(ctx, request)
+
+	return c.generate(ctx, cfg, func(ctx context.Context, model string) (*genai.GenerateContentResponse, error) {
+		return c.genaiClient.Models.GenerateContent(ctx, model, prompt, cfg)
+	})
+}
+
+// generate runs a single generation with budget checks, generation options,
+// retries and usage recording applied around it. The permit is taken for
+// this round-trip only, so tool handlers run without one and may call back
+// into the client.
+func (c *Client) generate(ctx context.Context, cfg *genai.GenerateContentConfig, attempt func(ctx context.Context, model string) (*genai.GenerateContentResponse, error)) (*genai.GenerateContentResponse, error) {
 	var result *genai.GenerateContentResponse
 
+	if err := c.semaphore.Acquire(ctx); err != nil {
+		return nil, err
+	}
+	defer c.semaphore.Release()
+
+	if err := usage.Check(ctx); err != nil {
+		return nil, err
+	}
+
 	model := modelFromContext(ctx)
-	prompt := genai.Text(request)
+	applyOptions(ctx, cfg)
 
 	retryable := retry.Retryer{
 		MaxRetries:       3,
 		InitialBackoff:   1 * time.Second,
 		MaxBackoff:       30 * time.Second,
-		IsRetryableError: isRateLimitError,
+		Jitter:           retry.EqualJitter,
+		AttemptTimeout:   attemptTimeout,
+		MaxElapsed:       maxElapsed,
+		IsRetryableError: isRetryableError,
+		RetryAfter:       retryAfter,
 		Attempt: func(ctx context.Context) error {
+			if err := c.limiter.Wait(ctx, model); err != nil {
+				return err
+			}
+
 			var err error
-			result, err = c.genaiClient.Models.GenerateContent(ctx, model, prompt, cfg)
+			result, err = attempt(ctx, model)
 			return err
 		},
 	}
@@ -120,26 +234,183 @@ func (c *Client) generateContext(ctx context.Context, request string, cfg *genai
 		return nil, err
 	}
 
+	c.recordUsage(ctx, model, result.UsageMetadata)
+
 	return result, nil
 }
 
+// generateContextStream streams a response, calling handler for each partial
+// result. Transient errors are only retried before anything has been handed
+// to the handler, otherwise the caller would see duplicated output.
+func (c *Client) generateContextStream(ctx context.Context, request string, cfg *genai.GenerateContentConfig, handler func(*genai.GenerateContentResponse) error) error {
+	if err := usage.Check(ctx); err != nil {
+		return err
+	}
+
+	model := modelFromContext(ctx)
+	prompt := requestContents(ctx, request)
+	applyOptions(ctx, cfg)
+	streaming := false
+
+	var metadata *genai.GenerateContentResponseUsageMetadata
+
+	retryable := retry.Retryer{
+		MaxRetries:     3,
+		InitialBackoff: 1 * time.Second,
+		MaxBackoff:     30 * time.Second,
+		Jitter:         retry.EqualJitter,
+		MaxElapsed:     maxElapsed,
+		IsRetryableError: func(err error) bool {
+			return !streaming && isRetryableError(err)
+		},
+		RetryAfter: retryAfter,
+		Attempt: func(ctx context.Context) error {
+			if err := c.limiter.Wait(ctx, model); err != nil {
+				return err
+			}
+
+			for result, err := range c.genaiClient.Models.GenerateContentStream(ctx, model, prompt, cfg) {
+				if err != nil {
+					return err
+				}
+
+				streaming = true
+
+				// Usage is cumulative, so the last chunk carries the totals
+				if result.UsageMetadata != nil {
+					metadata = result.UsageMetadata
+				}
+
+				if err := handler(result); err != nil {
+					return err
+				}
+			}
+
+			return nil
+		},
+	}
+
+	err := retryable.Try(ctx)
+
+	// A stream failing part way, or stopped by the handler, has still used
+	// the tokens reported so far
+	c.recordUsage(ctx, model, metadata)
+
+	return err
+}
+
+// recordUsage records token usage and charges it to the rate limiter.
+func (c *Client) recordUsage(ctx context.Context, model string, metadata *genai.GenerateContentResponseUsageMetadata) {
+	if metadata == nil {
+		return
+	}
+
+	u := usage.Usage{
+		Model:          model,
+		PromptTokens:   int(metadata.PromptTokenCount + metadata.ToolUsePromptTokenCount),
+		ResponseTokens: int(metadata.CandidatesTokenCount),
+		ThinkingTokens: int(metadata.ThoughtsTokenCount),
+	}
+
+	usage.Record(ctx, u)
+	c.limiter.Record(model, u.TotalTokens())
+}
+
+// applyOptions copies the generation options set on the context into cfg.
+func applyOptions(ctx context.Context, cfg *genai.GenerateContentConfig) {
+	options := models.GenerationOptionsFromContext(ctx)
+
+	if options.Temperature != nil {
+		cfg.Temperature = genai.Ptr(float32(*options.Temperature))
+	}
+
+	if options.TopP != nil {
+		cfg.TopP = genai.Ptr(float32(*options.TopP))
+	}
+
+	if options.MaxOutputTokens != nil {
+		cfg.MaxOutputTokens = int32(*options.MaxOutputTokens)
+	}
+
+	if options.Seed != nil {
+		cfg.Seed = genai.Ptr(int32(*options.Seed))
+	}
+
+	if options.StopSequences != nil {
+		cfg.StopSequences = options.StopSequences
+	}
+
+	if options.ThinkingBudget != nil {
+		cfg.ThinkingConfig = &genai.ThinkingConfig{
+			ThinkingBudget: genai.Ptr(int32(*options.ThinkingBudget)),
+		}
+	}
+}
+
+func askConfig(ctx context.Context, persona string) *genai.GenerateContentConfig {
+	return &genai.GenerateContentConfig{
+		SystemInstruction: genai.NewContentFromText(persona, genai.RoleModel),
+		Tools:             builtinTools(ctx, models.BuiltinSearch, models.BuiltinURLContext),
+	}
+}
+
+// builtinTools returns the built-in tools set on the context, or defaults
+// when none were chosen.
+func builtinTools(ctx context.Context, defaults ...models.BuiltinTool) []*genai.Tool {
+	builtins, ok := models.BuiltinToolsFromContext(ctx)
+	if !ok {
+		builtins = defaults
+	}
+
+	if len(builtins) == 0 {
+		return nil
+	}
+
+	tool := &genai.Tool{}
+
+	for _, builtin := range builtins {
+		switch builtin {
+		case models.BuiltinSearch:
+			tool.GoogleSearch = &genai.GoogleSearch{}
+		case models.BuiltinURLContext:
+			tool.URLContext = &genai.URLContext{}
+		case models.BuiltinCodeExecution:
+			tool.CodeExecution = &genai.ToolCodeExecution{}
+		}
+	}
+
+	return []*genai.Tool{tool}
+}
+
 // WithModel returns a context with the specified model set.
 func (c *Client) WithModel(ctx context.Context, model string) context.Context {
 	return context.WithValue(ctx, modelKey, model)
 }
 
-func isRateLimitError(err error) bool {
-	if apiErr, ok := err.(interface {
-		HTTPCode() int
-	}); ok && apiErr.HTTPCode() == http.StatusTooManyRequests {
-		return true
+// DefaultModel returns the model used when none is set with WithModel.
+func (c *Client) DefaultModel() string {
+	return defaultModel
+}
+
+// isRetryableError reports whether a failed request is worth repeating. The
+// genai SDK reports HTTP failures as genai.APIError values, which carry the
+// status code; anything else is classified by retry.IsTransient.
+func isRetryableError(err error) bool {
+	var apiErr genai.APIError
+	if errors.As(err, &apiErr) {
+		return retry.IsRetryableStatus(apiErr.Code)
 	}
 
-	if st, ok := status.FromError(err); ok {
-		if st.Code() == codes.ResourceExhausted {
-			return true
-		}
+	return retry.IsTransient(err)
+}
+
+// retryAfter returns the delay the API asked for in the RetryInfo details of
+// a rate limit error.
+func retryAfter(err error) (time.Duration, bool) {
+	var apiErr genai.APIError
+	if errors.As(err, &apiErr) {
+		return retry.RetryInfoDelay(apiErr.Details)
 	}
 
-	return false
+	return 0, false
 }
@@ -0,0 +1,73 @@
+package gemini
+
+import (
+	"github.com/schraf/assistant/pkg/models"
+	"google.golang.org/genai"
+)
+
+// references collects the web sources from the grounding metadata of the
+// first candidate, along with the passages each one supports, followed by
+// any URLs read through URL context.
+func references(result *genai.GenerateContentResponse) []models.Reference {
+	if len(result.Candidates) == 0 {
+		return nil
+	}
+
+	candidate := result.Candidates[0]
+
+	var refs []models.Reference
+	indices := map[string]int{}
+
+	add := func(title string, uri string) int {
+		if index, ok := indices[uri]; ok {
+			return index
+		}
+
+		if title == "" {
+			title = uri
+		}
+
+		indices[uri] = len(refs)
+		refs = append(refs, models.Reference{
+			Title: title,
+			URL:   uri,
+		})
+
+		return len(refs) - 1
+	}
+
+	if metadata := candidate.GroundingMetadata; metadata != nil {
+		chunks := make([]int, len(metadata.GroundingChunks))
+
+		for i, chunk := range metadata.GroundingChunks {
+			chunks[i] = -1
+
+			if chunk.Web != nil && chunk.Web.URI != "" {
+				chunks[i] = add(chunk.Web.Title, chunk.Web.URI)
+			}
+		}
+
+		for _, support := range metadata.GroundingSupports {
+			if support.Segment == nil || support.Segment.Text == "" {
+				continue
+			}
+
+			for _, index := range support.GroundingChunkIndices {
+				if int(index) < len(chunks) && chunks[index] >= 0 {
+					ref := &refs[chunks[index]]
+					ref.Supports = append(ref.Supports, support.Segment.Text)
+				}
+			}
+		}
+	}
+
+	if metadata := candidate.URLContextMetadata; metadata != nil {
+		for _, url := range metadata.URLMetadata {
+			if url.RetrievedURL != "" && url.URLRetrievalStatus == genai.URLRetrievalStatusSuccess {
+				add("", url.RetrievedURL)
+			}
+		}
+	}
+
+	return refs
+}
@@ -0,0 +1,23 @@
+package gemini
+
+import (
+	"context"
+
+	"github.com/schraf/assistant/pkg/models"
+	"github.com/schraf/assistant/pkg/providers"
+)
+
+const defaultConcurrency = 3
+
+func init() {
+	providers.MustRegister("gemini", newProvider)
+}
+
+func newProvider(ctx context.Context, config providers.Config) (models.Assistant, error) {
+	concurrency, err := config.Int("concurrency", defaultConcurrency)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewClient(ctx, concurrency)
+}
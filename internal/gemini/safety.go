@@ -0,0 +1,77 @@
+package gemini
+
+import (
+	"github.com/schraf/assistant/pkg/models"
+	"google.golang.org/genai"
+)
+
+// checkResponse returns a *models.ContentBlockedError when the prompt or the
+// response was blocked and a *models.TruncatedError when the response hit
+// the output token limit.
+func checkResponse(result *genai.GenerateContentResponse) error {
+	blocked := &models.ContentBlockedError{}
+
+	if feedback := result.PromptFeedback; feedback != nil {
+		blocked.BlockReason = string(feedback.BlockReason)
+		blocked.Message = feedback.BlockReasonMessage
+		blocked.SafetyRatings = safetyRatings(blocked.SafetyRatings, "prompt", feedback.SafetyRatings)
+	}
+
+	if blocked.BlockReason != "" {
+		return blocked
+	}
+
+	if len(result.Candidates) == 0 {
+		// No candidates with feedback means the prompt was refused
+		if result.PromptFeedback != nil {
+			return blocked
+		}
+
+		return nil
+	}
+
+	candidate := result.Candidates[0]
+
+	switch candidate.FinishReason {
+	case genai.FinishReasonSafety,
+		genai.FinishReasonRecitation,
+		genai.FinishReasonBlocklist,
+		genai.FinishReasonProhibitedContent,
+		genai.FinishReasonSPII,
+		genai.FinishReasonImageSafety,
+		genai.FinishReasonImageProhibitedContent:
+		blocked.FinishReason = string(candidate.FinishReason)
+		blocked.SafetyRatings = safetyRatings(blocked.SafetyRatings, "response", candidate.SafetyRatings)
+
+		if candidate.FinishMessage != "" {
+			blocked.Message = candidate.FinishMessage
+		}
+
+		return blocked
+
+	case genai.FinishReasonMaxTokens:
+		return &models.TruncatedError{
+			FinishReason: string(candidate.FinishReason),
+			Text:         result.Text(),
+		}
+	}
+
+	return nil
+}
+
+func safetyRatings(ratings []models.SafetyRating, source string, genaiRatings []*genai.SafetyRating) []models.SafetyRating {
+	for _, rating := range genaiRatings {
+		if rating == nil {
+			continue
+		}
+
+		ratings = append(ratings, models.SafetyRating{
+			Source:      source,
+			Category:    string(rating.Category),
+			Probability: string(rating.Probability),
+			Blocked:     rating.Blocked,
+		})
+	}
+
+	return ratings
+}
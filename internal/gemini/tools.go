@@ -0,0 +1,125 @@
+package gemini
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+
+	"github.com/schraf/assistant/pkg/models"
+	"google.golang.org/genai"
+)
+
+// sendFunc sends one user turn and returns the model's response. Senders keep
+// the conversation so far, so a turn answering function calls is sent with
+// the history that produced them.
+type sendFunc func(ctx context.Context, model string, parts []*genai.Part) (*genai.GenerateContentResponse, error)
+
+// generateTurns sends the first turn and then, for as long as the model calls
+// the tools set on the context, sends the tool results back as further turns
+// until the model gives a final response.
+func (c *Client) generateTurns(ctx context.Context, cfg *genai.GenerateContentConfig, parts []*genai.Part, send sendFunc) (*genai.GenerateContentResponse, error) {
+	tools := models.ToolsFromContext(ctx)
+	applyTools(cfg, tools)
+
+	maxIterations := models.MaxToolIterationsFromContext(ctx)
+
+	for iteration := 0; ; iteration++ {
+		result, err := c.generate(ctx, cfg, func(ctx context.Context, model string) (*genai.GenerateContentResponse, error) {
+			return send(ctx, model, parts)
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		calls := result.FunctionCalls()
+		if len(tools) == 0 || len(calls) == 0 {
+			return result, nil
+		}
+
+		if iteration >= maxIterations {
+			return nil, models.ErrToolIterationsExceeded
+		}
+
+		parts = make([]*genai.Part, 0, len(calls))
+
+		for _, call := range calls {
+			arguments, err := json.Marshal(call.Args)
+			if err != nil {
+				return nil, fmt.Errorf("failed to marshal arguments for tool '%s': %w", call.Name, err)
+			}
+
+			part := genai.NewPartFromFunctionResponse(call.Name, models.CallTool(ctx, tools, call.Name, arguments))
+			part.FunctionResponse.ID = call.ID
+
+			parts = append(parts, part)
+		}
+	}
+}
+
+// generateSender sends turns with GenerateContent, keeping the history
+// itself. Turns are only added to the history once they succeed, so retried
+// turns are not duplicated.
+func (c *Client) generateSender(cfg *genai.GenerateContentConfig) sendFunc {
+	var contents []*genai.Content
+
+	return func(ctx context.Context, model string, parts []*genai.Part) (*genai.GenerateContentResponse, error) {
+		turn := append(slices.Clone(contents), genai.NewContentFromParts(parts, genai.RoleUser))
+
+		result, err := c.genaiClient.Models.GenerateContent(ctx, model, turn, cfg)
+		if err != nil {
+			return nil, err
+		}
+
+		contents = turn
+
+		if len(result.Candidates) > 0 && result.Candidates[0].Content != nil {
+			contents = append(contents, result.Candidates[0].Content)
+		}
+
+		return result, nil
+	}
+}
+
+// chatSender sends turns through a chat session started from history, which
+// records each successful turn.
+func (c *Client) chatSender(cfg *genai.GenerateContentConfig, history []*genai.Content) sendFunc {
+	var chat *genai.Chat
+
+	return func(ctx context.Context, model string, parts []*genai.Part) (*genai.GenerateContentResponse, error) {
+		if chat == nil {
+			var err error
+
+			chat, err = c.genaiClient.Chats.Create(ctx, model, cfg, history)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		return chat.Send(ctx, parts...)
+	}
+}
+
+// applyTools declares the tools as functions. Gemini cannot combine function
+// calling with its built-in search tools, so they replace any built-ins.
+func applyTools(cfg *genai.GenerateContentConfig, tools []models.Tool) {
+	if len(tools) == 0 {
+		return
+	}
+
+	declarations := make([]*genai.FunctionDeclaration, 0, len(tools))
+
+	for _, tool := range tools {
+		declarations = append(declarations, &genai.FunctionDeclaration{
+			Name:                 tool.Name,
+			Description:          tool.Description,
+			ParametersJsonSchema: tool.Parameters,
+		})
+	}
+
+	cfg.Tools = []*genai.Tool{
+		{
+			FunctionDeclarations: declarations,
+		},
+	}
+}
@@ -0,0 +1,137 @@
+package job
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/schraf/assistant/internal/usage"
+	"github.com/schraf/assistant/pkg/generators"
+	"github.com/schraf/assistant/pkg/models"
+)
+
+// getBudget reads the optional Max-Tokens and Max-Cost config values.
+func getBudget(config generators.Config) (usage.Budget, error) {
+	var budget usage.Budget
+
+	maxTokens, err := configInt(config, "max-tokens")
+	if err != nil {
+		return budget, err
+	}
+
+	maxCost, err := configFloat(config, "max-cost")
+	if err != nil {
+		return budget, err
+	}
+
+	if maxTokens != nil {
+		budget.MaxTokens = *maxTokens
+	}
+
+	if maxCost != nil {
+		budget.MaxCost = *maxCost
+	}
+
+	return budget, nil
+}
+
+// getGenerationOptions reads the optional Temperature, Top-P,
+// Max-Output-Tokens, Seed, Stop-Sequences and Thinking-Budget config values.
+func getGenerationOptions(config generators.Config) (models.GenerationOptions, error) {
+	var options models.GenerationOptions
+	var err error
+
+	if options.Temperature, err = configFloat(config, "temperature"); err != nil {
+		return options, err
+	}
+
+	if options.TopP, err = configFloat(config, "top-p"); err != nil {
+		return options, err
+	}
+
+	if options.MaxOutputTokens, err = configInt(config, "max-output-tokens"); err != nil {
+		return options, err
+	}
+
+	if options.Seed, err = configInt(config, "seed"); err != nil {
+		return options, err
+	}
+
+	if options.ThinkingBudget, err = configInt(config, "thinking-budget"); err != nil {
+		return options, err
+	}
+
+	options.StopSequences = configStrings(config, "stop-sequences")
+
+	return options, nil
+}
+
+// lookupConfig finds a config value regardless of key case, since keys from
+// X-Config-* headers arrive in canonical header form (e.g. "Max-Tokens").
+func lookupConfig(config generators.Config, key string) (any, bool) {
+	for name, value := range config {
+		if strings.EqualFold(name, key) {
+			return value, true
+		}
+	}
+
+	return nil, false
+}
+
+func configString(value any) string {
+	if number, ok := value.(float64); ok {
+		return strconv.FormatFloat(number, 'f', -1, 64)
+	}
+
+	return strings.TrimSpace(fmt.Sprint(value))
+}
+
+func configInt(config generators.Config, key string) (*int, error) {
+	value, ok := lookupConfig(config, key)
+	if !ok {
+		return nil, nil
+	}
+
+	n, err := strconv.Atoi(configString(value))
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s '%v': %w", key, value, err)
+	}
+
+	return &n, nil
+}
+
+func configFloat(config generators.Config, key string) (*float64, error) {
+	value, ok := lookupConfig(config, key)
+	if !ok {
+		return nil, nil
+	}
+
+	f, err := strconv.ParseFloat(configString(value), 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s '%v': %w", key, value, err)
+	}
+
+	return &f, nil
+}
+
+// configStrings reads a list value, which arrives as an array when the
+// header is repeated or as a single string otherwise.
+func configStrings(config generators.Config, key string) []string {
+	value, ok := lookupConfig(config, key)
+	if !ok {
+		return nil
+	}
+
+	switch v := value.(type) {
+	case []any:
+		values := make([]string, 0, len(v))
+		for _, item := range v {
+			values = append(values, configString(item))
+		}
+		return values
+	case []string:
+		return v
+	default:
+		return []string{configString(v)}
+	}
+}
@@ -4,27 +4,39 @@ import (
 	"context"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
 	"os"
+	"sync/atomic"
+	"time"
 
 	"github.com/google/uuid"
 	internal_models "github.com/schraf/assistant/internal/models"
+	"github.com/schraf/assistant/internal/retry"
+	"github.com/schraf/assistant/internal/staging"
+	"github.com/schraf/assistant/internal/transcript"
+	"github.com/schraf/assistant/internal/usage"
 	"github.com/schraf/assistant/pkg/generators"
 	"github.com/schraf/assistant/pkg/models"
+	"github.com/schraf/assistant/pkg/providers"
 )
 
 // Processor handles the job processing workflow.
 type Processor struct {
+	provider  string
 	assistant models.Assistant
 	publisher internal_models.Publisher
 	notifier  internal_models.Notifier
 	logger    *slog.Logger
 }
 
-// NewProcessor creates a new Processor with the given dependencies.
-func NewProcessor(assistant models.Assistant, publisher internal_models.Publisher, notifier internal_models.Notifier, logger *slog.Logger) *Processor {
+// NewProcessor creates a new Processor with the given dependencies. The
+// provider names the registry entry the assistant was created from, whose
+// model table resolves the requested model.
+func NewProcessor(provider string, assistant models.Assistant, publisher internal_models.Publisher, notifier internal_models.Notifier, logger *slog.Logger) *Processor {
 	return &Processor{
+		provider:  provider,
 		assistant: assistant,
 		publisher: publisher,
 		notifier:  notifier,
@@ -34,15 +46,15 @@ func NewProcessor(assistant models.Assistant, publisher internal_models.Publishe
 
 // Process executes the complete job workflow:
 // 1. Get request and config from environment
-// 2. Generate content
+// 2. Generate content, tracking assistant usage
 // 3. Publish document
-// 4. Send notification
+// 4. Send notification with a usage summary
 func (p *Processor) Process(ctx context.Context) error {
 	//--========================================================================--
 	//--== GET THE REQUEST
 	//--========================================================================--
 
-	request, err := getRequest()
+	request, err := getRequest(ctx)
 	if err != nil {
 		p.logger.ErrorContext(ctx, "invalid_request",
 			slog.String("error", err.Error()),
@@ -54,10 +66,20 @@ func (p *Processor) Process(ctx context.Context) error {
 		slog.String("request_id", request.Id.String()),
 	)
 
+	ctx = models.WithRequestID(ctx, request.Id.String())
+
 	logger.InfoContext(ctx, "request_body",
 		slog.Any("body", request.Body),
 	)
 
+	for _, attachment := range request.Attachments {
+		logger.InfoContext(ctx, "request_attachment",
+			slog.String("name", attachment.Name),
+			slog.String("mime_type", attachment.MIMEType),
+			slog.Int("size", len(attachment.Data)),
+		)
+	}
+
 	//--========================================================================--
 	//--== GET THE CONFIG
 	//--========================================================================--
@@ -70,28 +92,98 @@ func (p *Processor) Process(ctx context.Context) error {
 		return fmt.Errorf("failed getting config: %w", err)
 	}
 
+	//--========================================================================--
+	//--== OPEN THE TRANSCRIPT
+	//--========================================================================--
+
+	middleware := []models.Middleware{models.Logging(p.logger)}
+
+	var record *transcript.Transcript
+
+	if dir := os.Getenv("TRANSCRIPT_DIR"); dir != "" {
+		record, err = transcript.Create(dir, request.Id.String())
+		if err != nil {
+			logger.ErrorContext(ctx, "failed_opening_transcript",
+				slog.String("error", err.Error()),
+			)
+			return fmt.Errorf("failed opening transcript: %w", err)
+		}
+		defer record.Close()
+
+		if err := record.WriteRequest(p.provider, os.Getenv("CONTENT_TYPE"), *config, *request); err != nil {
+			logger.ErrorContext(ctx, "failed_writing_transcript",
+				slog.String("error", err.Error()),
+			)
+			return fmt.Errorf("failed writing transcript: %w", err)
+		}
+
+		middleware = append(middleware, record.Middleware())
+
+		logger.InfoContext(ctx, "writing_transcript",
+			slog.String("path", record.Path()),
+		)
+	}
+
 	//--========================================================================--
 	//--== APPLY MODEL SELECTION
 	//--========================================================================--
 
-	if model, ok := (*config)["model"].(string); ok {
-		var modelName string
-		if model == "pro" {
-			modelName = "gemini-pro-latest"
-		} else if model == "basic" {
-			modelName = "gemini-flash-latest"
-		} else {
-			// For other assistants (like Ollama), use the model name directly
-			modelName = model
+	modelTables, err := providers.LoadModelTables()
+	if err != nil {
+		logger.ErrorContext(ctx, "failed_loading_model_tables",
+			slog.String("error", err.Error()),
+		)
+		return fmt.Errorf("failed loading model tables: %w", err)
+	}
+
+	tabled := providers.WithModelTable(p.assistant, modelTables[p.provider])
+	assistant := models.Chain(tabled, middleware...)
+
+	if value, ok := lookupConfig(*config, "model"); ok {
+		model := configString(value)
+
+		modelName, err := providers.ResolveModel(tabled, model)
+		if err != nil {
+			logger.ErrorContext(ctx, "unknown_model",
+				slog.String("provider", p.provider),
+				slog.String("model", model),
+			)
+			return fmt.Errorf("invalid model: %w", err)
 		}
 
-		ctx = p.assistant.WithModel(ctx, modelName)
+		ctx = assistant.WithModel(ctx, modelName)
+
+		logger.InfoContext(ctx, "using_model",
+			slog.String("requested", model),
+			slog.String("model", modelName),
+		)
+	} else if modelName := providers.DefaultModel(tabled); modelName != "" {
+		// Selected explicitly so interceptors and the transcript see it
+		ctx = assistant.WithModel(ctx, modelName)
 
 		logger.InfoContext(ctx, "using_model",
 			slog.String("model", modelName),
 		)
 	}
 
+	//--========================================================================--
+	//--== APPLY GENERATION OPTIONS
+	//--========================================================================--
+
+	generationOptions, err := getGenerationOptions(*config)
+	if err != nil {
+		logger.ErrorContext(ctx, "invalid_generation_options",
+			slog.String("error", err.Error()),
+		)
+		return fmt.Errorf("invalid generation options: %w", err)
+	}
+
+	ctx = models.WithGenerationOptions(ctx, generationOptions)
+
+	logger.InfoContext(ctx, "using_generation_options",
+		slog.Any("options", generationOptions),
+	)
+
 	//--========================================================================--
 	//--== GENERATE CONTENT
 	//--========================================================================--
@@ -106,7 +198,71 @@ func (p *Processor) Process(ctx context.Context) error {
 		return fmt.Errorf("failed creating generator: %w", err)
 	}
 
-	doc, err := contentGenerator.Generate(ctx, *request, p.assistant)
+	budget, err := getBudget(*config)
+	if err != nil {
+		logger.ErrorContext(ctx, "invalid_budget",
+			slog.String("error", err.Error()),
+		)
+		return fmt.Errorf("invalid budget: %w", err)
+	}
+
+	tracker := usage.NewTracker(usage.DefaultPrices)
+	tracker.SetBudget(budget)
+	ctx = usage.WithTracker(ctx, tracker)
+
+	// Count retries so provider trouble shows up in the summary
+	var retries atomic.Int64
+	ctx = retry.WithHooks(ctx, &retry.Hooks{
+		OnRetry: func(attempt int, err error, backoff time.Duration) {
+			retries.Add(1)
+		},
+	})
+
+	doc, err := contentGenerator.Generate(ctx, *request, assistant)
+
+	// Usage is logged whether or not generation succeeded, since a failed
+	// generation has still spent tokens
+	summary := tracker.Summary()
+
+	logger.InfoContext(ctx, "usage_summary",
+		slog.Int("calls", summary.Total.Calls),
+		slog.Int("prompt_tokens", summary.Total.PromptTokens),
+		slog.Int("response_tokens", summary.Total.ResponseTokens),
+		slog.Int("thinking_tokens", summary.Total.ThinkingTokens),
+		slog.Float64("estimated_cost_usd", summary.Total.Cost),
+		slog.Int64("retries", retries.Load()),
+		slog.Any("models", summary.Models),
+	)
+
+	if errors.Is(err, models.ErrBudgetExceeded) {
+		logger.ErrorContext(ctx, "budget_exceeded",
+			slog.Int("max_tokens", budget.MaxTokens),
+			slog.Float64("max_cost_usd", budget.MaxCost),
+			slog.String("error", err.Error()),
+		)
+		return fmt.Errorf("budget exceeded: %w", err)
+	}
+
+	var blocked *models.ContentBlockedError
+	if errors.As(err, &blocked) {
+		logger.ErrorContext(ctx, "content_blocked",
+			slog.String("block_reason", blocked.BlockReason),
+			slog.String("finish_reason", blocked.FinishReason),
+			slog.String("message", blocked.Message),
+			slog.Any("safety_ratings", blocked.SafetyRatings),
+		)
+		return fmt.Errorf("content blocked: %w", err)
+	}
+
+	var truncated *models.TruncatedError
+	if errors.As(err, &truncated) {
+		logger.ErrorContext(ctx, "response_truncated",
+			slog.String("finish_reason", truncated.FinishReason),
+			slog.Int("partial_length", len(truncated.Text)),
+		)
+		return fmt.Errorf("response truncated: %w", err)
+	}
+
 	if err != nil {
 		logger.ErrorContext(ctx, "content_generation_error",
 			slog.String("error", err.Error()),
@@ -142,13 +298,21 @@ func (p *Processor) Process(ctx context.Context) error {
 		slog.String("url", url.String()),
 	)
 
+	if record != nil {
+		if err := record.WriteDocument(doc, url); err != nil {
+			logger.WarnContext(ctx, "failed_writing_transcript",
+				slog.String("error", err.Error()),
+			)
+		}
+	}
+
 	//--========================================================================--
 	//--== SEND NOTIFICATION
 	//--========================================================================--
 
 	logger.InfoContext(ctx, "sending_notification")
 
-	if err := p.notifier.SendPublishedURLNotification(url, doc.Title); err != nil {
+	if err := p.notifier.SendPublishedURLNotification(url, doc.Title, summary.String()); err != nil {
 		logger.ErrorContext(ctx, "failed_sending_notification",
 			slog.String("error", err.Error()),
 		)
@@ -181,7 +345,7 @@ func getConfig() (*generators.Config, error) {
 	return &config, nil
 }
 
-func getRequest() (*models.ContentRequest, error) {
+func getRequest(ctx context.Context) (*models.ContentRequest, error) {
 	requestId, err := uuid.Parse(os.Getenv("REQUEST_ID"))
 	if err != nil {
 		return nil, err
@@ -203,12 +367,38 @@ func getRequest() (*models.ContentRequest, error) {
 		return nil, fmt.Errorf("failed to parse json body in REQUEST_BODY: %w", err)
 	}
 
+	attachments, err := getAttachments(ctx)
+	if err != nil {
+		return nil, err
+	}
+
 	return &models.ContentRequest{
-		Id:   requestId,
-		Body: body,
+		Id:          requestId,
+		Body:        body,
+		Attachments: attachments,
 	}, nil
 }
 
+func getAttachments(ctx context.Context) ([]models.Attachment, error) {
+	uri := os.Getenv("REQUEST_ATTACHMENTS_URI")
+	if uri == "" {
+		return nil, nil
+	}
+
+	attachments, err := staging.Load(ctx, uri)
+	if err != nil {
+		return nil, fmt.Errorf("invalid attachments in REQUEST_ATTACHMENTS_URI: %w", err)
+	}
+
+	for _, attachment := range attachments {
+		if err := attachment.Validate(); err != nil {
+			return nil, fmt.Errorf("invalid attachment in REQUEST_ATTACHMENTS_URI: %w", err)
+		}
+	}
+
+	return attachments, nil
+}
+
 func getContentGenerator(config generators.Config) (models.ContentGenerator, error) {
 	contentType := os.Getenv("CONTENT_TYPE")
 	if contentType == "" {
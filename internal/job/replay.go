@@ -0,0 +1,245 @@
+package job
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/url"
+	"os"
+	"path/filepath"
+
+	"github.com/schraf/assistant/internal/staging"
+	"github.com/schraf/assistant/internal/transcript"
+	"github.com/schraf/assistant/pkg/cassette"
+	"github.com/schraf/assistant/pkg/models"
+	"github.com/schraf/assistant/pkg/providers"
+)
+
+// Replay is a past request loaded from its transcript, for re-running the
+// job against the responses the model gave at the time.
+type Replay struct {
+	Request  transcript.Entry
+	Calls    []transcript.Entry
+	Document *models.Document
+}
+
+// LoadReplay reads the transcript at path. A transcript appended to by a
+// retried job holds several attempts, and only the last one is replayed.
+func LoadReplay(path string) (*Replay, error) {
+	entries, err := transcript.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var replay *Replay
+
+	for _, entry := range entries {
+		switch entry.Kind {
+		case transcript.KindRequest:
+			replay = &Replay{Request: entry}
+		case transcript.KindCall:
+			if replay != nil {
+				replay.Calls = append(replay.Calls, entry)
+			}
+		case transcript.KindDocument:
+			if replay != nil {
+				replay.Document = entry.Document
+			}
+		}
+	}
+
+	if replay == nil {
+		return nil, fmt.Errorf("no request found in transcript %s", path)
+	}
+
+	return replay, nil
+}
+
+// SetEnv sets the environment the job reads its request from to the
+// recorded request, so Process runs it exactly as it was run before.
+// Attachments are staged in a file under the temporary directory, and
+// transcripts are turned off for the replay.
+func (r *Replay) SetEnv() error {
+	body, err := json.Marshal(r.Request.Body)
+	if err != nil {
+		return fmt.Errorf("failed to marshal request body: %w", err)
+	}
+
+	config, err := json.Marshal(r.Request.Config)
+	if err != nil {
+		return fmt.Errorf("failed to marshal request config: %w", err)
+	}
+
+	var attachments string
+
+	if len(r.Request.Attachments) > 0 {
+		path := filepath.Join(os.TempDir(), "assistant-"+r.Request.RequestID+"-attachments.json")
+
+		attachments, err = staging.WriteFile(path, r.Request.Attachments)
+		if err != nil {
+			return fmt.Errorf("failed to stage request attachments: %w", err)
+		}
+	}
+
+	env := map[string]string{
+		"REQUEST_ID":              r.Request.RequestID,
+		"REQUEST_BODY":            base64.StdEncoding.EncodeToString(body),
+		"REQUEST_ATTACHMENTS_URI": attachments,
+		"CONTENT_CONFIG":          base64.StdEncoding.EncodeToString(config),
+		"CONTENT_TYPE":            r.Request.ContentType,
+		// A replay must not append to the transcript it is replaying
+		"TRANSCRIPT_DIR": "",
+	}
+
+	for key, value := range env {
+		if err := os.Setenv(key, value); err != nil {
+			return fmt.Errorf("failed to set %s: %w", key, err)
+		}
+	}
+
+	return nil
+}
+
+// Provider returns the provider the request was run with, whose model table
+// resolves the requested model.
+func (r *Replay) Provider() string {
+	if r.Request.Provider == "" {
+		return providers.DefaultProvider
+	}
+
+	return r.Request.Provider
+}
+
+// Assistant returns an assistant answering calls with the recorded
+// responses. The transcript keeps only the text of an answer, so
+// AskWithSources is replayed without its sources.
+func (r *Replay) Assistant(match cassette.Match) (*cassette.Replayer, error) {
+	var recorded cassette.Cassette
+
+	for _, call := range r.Calls {
+		interaction := cassette.Interaction{
+			Method:   call.Method,
+			Model:    call.Model,
+			Persona:  call.Persona,
+			Request:  call.Request,
+			Messages: call.Messages,
+			Schema:   call.Schema,
+			Error:    call.Error,
+			Outcome:  call.Outcome,
+		}
+
+		if call.Error == "" {
+			response, err := replayResponse(call)
+			if err != nil {
+				return nil, err
+			}
+			interaction.Response = response
+		}
+
+		recorded.Interactions = append(recorded.Interactions, interaction)
+	}
+
+	return cassette.NewReplayerFromCassette(&recorded, match), nil
+}
+
+// Matches reports whether doc is the document the recorded run published.
+// Documents are compared through JSON, as that is how the recorded one was
+// kept.
+func (r *Replay) Matches(doc *models.Document) bool {
+	if r.Document == nil || doc == nil {
+		return false
+	}
+
+	recorded, err := json.Marshal(r.Document)
+	if err != nil {
+		return false
+	}
+
+	replayed, err := json.Marshal(doc)
+	if err != nil {
+		return false
+	}
+
+	return bytes.Equal(recorded, replayed)
+}
+
+// replayResponse encodes the recorded response text the way a cassette
+// holds the result of the call's method.
+func replayResponse(call transcript.Entry) (json.RawMessage, error) {
+	var response any
+
+	switch call.Method {
+	case models.MethodStructuredAsk:
+		if !json.Valid([]byte(call.Response)) {
+			return nil, fmt.Errorf("recorded %s response is not valid JSON", call.Method)
+		}
+		return json.RawMessage(call.Response), nil
+	case models.MethodAskWithSources:
+		response = models.Answer{Text: call.Response}
+	default:
+		response = call.Response
+	}
+
+	data, err := json.Marshal(response)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode recorded response: %w", err)
+	}
+
+	return data, nil
+}
+
+// ReplayPublisher writes the document as JSON instead of publishing it, so
+// a replayed job leaves no trace outside its output.
+type ReplayPublisher struct {
+	writer io.Writer
+	doc    *models.Document
+}
+
+// NewReplayPublisher returns a ReplayPublisher writing to w.
+func NewReplayPublisher(w io.Writer) *ReplayPublisher {
+	return &ReplayPublisher{writer: w}
+}
+
+func (p *ReplayPublisher) PublishDocument(ctx context.Context, doc *models.Document) (*url.URL, error) {
+	data, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal document: %w", err)
+	}
+
+	if _, err := p.writer.Write(append(data, '\n')); err != nil {
+		return nil, fmt.Errorf("failed to write document: %w", err)
+	}
+
+	p.doc = doc
+
+	return &url.URL{Scheme: "replay", Opaque: models.RequestIDFromContext(ctx)}, nil
+}
+
+// Document returns the document the job published, or nil.
+func (p *ReplayPublisher) Document() *models.Document {
+	return p.doc
+}
+
+// ReplayNotifier logs the notification instead of sending it.
+type ReplayNotifier struct {
+	logger *slog.Logger
+}
+
+// NewReplayNotifier returns a ReplayNotifier logging to logger.
+func NewReplayNotifier(logger *slog.Logger) *ReplayNotifier {
+	return &ReplayNotifier{logger: logger}
+}
+
+func (n *ReplayNotifier) SendPublishedURLNotification(publishedURL *url.URL, title string, details string) error {
+	n.logger.Info("replay_notification",
+		slog.String("url", publishedURL.String()),
+		slog.String("title", title),
+		slog.String("details", details),
+	)
+
+	return nil
+}
@@ -4,13 +4,18 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+
+	"github.com/schraf/assistant/pkg/models"
 )
 
 // MockAssistant is a mock implementation of models.Assistant.
 type MockAssistant struct {
-	AskFunc           func(ctx context.Context, persona string, request string) (*string, error)
-	StructuredAskFunc func(ctx context.Context, persona string, request string, schema map[string]any) (json.RawMessage, error)
-	WithModelFunc     func(ctx context.Context, model string) context.Context
+	AskFunc            func(ctx context.Context, persona string, request string) (*string, error)
+	AskWithSourcesFunc func(ctx context.Context, persona string, request string) (*models.Answer, error)
+	AskStreamFunc      func(ctx context.Context, persona string, request string, handler models.StreamHandler) (*string, error)
+	ChatFunc           func(ctx context.Context, persona string, messages []models.Message) (*string, error)
+	StructuredAskFunc  func(ctx context.Context, persona string, request string, schema map[string]any) (json.RawMessage, error)
+	WithModelFunc      func(ctx context.Context, model string) context.Context
 }
 
 // Ask calls AskFunc if set, otherwise returns a mock response.
@@ -23,6 +28,51 @@ func (m *MockAssistant) Ask(ctx context.Context, persona string, request string)
 	return &response, nil
 }
 
+// AskWithSources calls AskWithSourcesFunc if set, otherwise returns the response from Ask without references.
+func (m *MockAssistant) AskWithSources(ctx context.Context, persona string, request string) (*models.Answer, error) {
+	if m.AskWithSourcesFunc != nil {
+		return m.AskWithSourcesFunc(ctx, persona, request)
+	}
+
+	response, err := m.Ask(ctx, persona, request)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.Answer{Text: *response}, nil
+}
+
+// AskStream calls AskStreamFunc if set, otherwise streams the response from Ask as a single chunk.
+func (m *MockAssistant) AskStream(ctx context.Context, persona string, request string, handler models.StreamHandler) (*string, error) {
+	if m.AskStreamFunc != nil {
+		return m.AskStreamFunc(ctx, persona, request, handler)
+	}
+
+	response, err := m.Ask(ctx, persona, request)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := handler(*response); err != nil {
+		return nil, err
+	}
+
+	return response, nil
+}
+
+// Chat calls ChatFunc if set, otherwise answers the last message with Ask.
+func (m *MockAssistant) Chat(ctx context.Context, persona string, messages []models.Message) (*string, error) {
+	if m.ChatFunc != nil {
+		return m.ChatFunc(ctx, persona, messages)
+	}
+
+	if err := models.ValidateMessages(messages); err != nil {
+		return nil, err
+	}
+
+	return m.Ask(ctx, persona, messages[len(messages)-1].Content)
+}
+
 // StructuredAsk calls StructuredAskFunc if set, otherwise returns mock JSON that matches the schema.
 func (m *MockAssistant) StructuredAsk(ctx context.Context, persona string, request string, schema map[string]any) (json.RawMessage, error) {
 	if m.StructuredAskFunc != nil {
@@ -45,8 +95,12 @@ func generateMockFromSchema(schema map[string]any) any {
 		return map[string]any{"mock": "data"}
 	}
 
-	// Check if this is a JSON schema with a "type" field
+	// Check if this is a JSON schema with a "type" field, which lists the
+	// types a nullable value may have
 	schemaType, hasType := schema["type"].(string)
+	if types, ok := schema["type"].([]string); ok && len(types) > 0 {
+		schemaType, hasType = types[0], true
+	}
 	if !hasType {
 		// If no type, check for "properties" (object schema)
 		if properties, ok := schema["properties"].(map[string]any); ok {
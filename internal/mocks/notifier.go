@@ -4,13 +4,13 @@ import "net/url"
 
 // MockNotifier is a mock implementation of models.Notifier.
 type MockNotifier struct {
-	SendPublishedURLNotificationFunc func(publishedURL *url.URL, title string) error
+	SendPublishedURLNotificationFunc func(publishedURL *url.URL, title string, details string) error
 }
 
 // SendPublishedURLNotification calls SendPublishedURLNotificationFunc if set, otherwise returns nil.
-func (m *MockNotifier) SendPublishedURLNotification(publishedURL *url.URL, title string) error {
+func (m *MockNotifier) SendPublishedURLNotification(publishedURL *url.URL, title string, details string) error {
 	if m.SendPublishedURLNotificationFunc != nil {
-		return m.SendPublishedURLNotificationFunc(publishedURL, title)
+		return m.SendPublishedURLNotificationFunc(publishedURL, title, details)
 	}
 
 	return nil
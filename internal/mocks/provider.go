@@ -0,0 +1,16 @@
+package mocks
+
+import (
+	"context"
+
+	"github.com/schraf/assistant/pkg/models"
+	"github.com/schraf/assistant/pkg/providers"
+)
+
+func init() {
+	providers.MustRegister("mock", newProvider)
+}
+
+func newProvider(ctx context.Context, config providers.Config) (models.Assistant, error) {
+	return &MockAssistant{}, nil
+}
@@ -18,6 +18,7 @@ type Publisher interface {
 }
 
 // Notifier defines the interface for sending notifications.
+// Details is optional free text appended after the URL, such as a usage summary.
 type Notifier interface {
-	SendPublishedURLNotification(publishedURL *url.URL, title string) error
+	SendPublishedURLNotification(publishedURL *url.URL, title string, details string) error
 }
@@ -18,8 +18,8 @@ func NewEmailNotifier() internal_models.Notifier {
 }
 
 // SendPublishedURLNotification sends an email notification with the published URL
-// using environment variables defined in terraform/job.tf
-func (n *EmailNotifier) SendPublishedURLNotification(publishedURL *url.URL, title string) error {
+// and any details using environment variables defined in terraform/job.tf
+func (n *EmailNotifier) SendPublishedURLNotification(publishedURL *url.URL, title string, details string) error {
 	host := os.Getenv("MAIL_SMTP_SERVER")
 	if host == "" {
 		return fmt.Errorf("missing MAIL_SMTP_SERVER environment variable")
@@ -53,5 +53,9 @@ func (n *EmailNotifier) SendPublishedURLNotification(publishedURL *url.URL, titl
 	subject := title
 	body := publishedURL.String()
 
+	if details != "" {
+		body += "\n\n" + details
+	}
+
 	return SendEmail(host, port, from, password, to, from, subject, body)
 }
@@ -1,179 +1,429 @@
 package ollama
 
 import (
-	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"log/slog"
+	"net"
 	"net/http"
 	"os"
+	"slices"
+	"strconv"
 	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/schraf/assistant/internal/chatapi"
+	"github.com/schraf/assistant/internal/ratelimit"
+	"github.com/schraf/assistant/internal/retry"
+	"github.com/schraf/assistant/internal/structured"
+	"github.com/schraf/assistant/internal/usage"
+	"github.com/schraf/assistant/pkg/models"
+	"github.com/schraf/syncext"
+)
+
+const (
+	defaultTimeout    = 5 * time.Minute
+	defaultMaxRetries = 3
 )
 
 type Client struct {
-	baseURL    string
-	httpClient *http.Client
+	poster       *chatapi.Poster
+	semaphore    *syncext.Semaphore
+	legacyFormat atomic.Bool
 }
 
 type chatRequest struct {
-	Model    string    `json:"model"`
-	Messages []message `json:"messages"`
-	Format   string    `json:"format,omitempty"`
-	Stream   bool      `json:"stream"`
+	Model    string         `json:"model"`
+	Messages []message      `json:"messages"`
+	Tools    []chatapi.Tool `json:"tools,omitempty"`
+	Format   any            `json:"format,omitempty"`
+	Stream   bool           `json:"stream"`
+	Think    *bool          `json:"think,omitempty"`
+	Options  *options       `json:"options,omitempty"`
 }
 
-type message struct {
-	Role    string `json:"role"`
-	Content string `json:"content"`
+type options struct {
+	Temperature *float64 `json:"temperature,omitempty"`
+	TopP        *float64 `json:"top_p,omitempty"`
+	NumPredict  *int     `json:"num_predict,omitempty"`
+	Seed        *int     `json:"seed,omitempty"`
+	Stop        []string `json:"stop,omitempty"`
 }
 
+type message = chatapi.Message[toolCall]
+
 type chatResponse struct {
-	Message struct {
-		Content string `json:"content"`
-	} `json:"message"`
-	Done bool `json:"done"`
+	Message         message `json:"message"`
+	Done            bool    `json:"done"`
+	DoneReason      string  `json:"done_reason"`
+	PromptEvalCount int     `json:"prompt_eval_count"`
+	EvalCount       int     `json:"eval_count"`
+	Error           string  `json:"error"`
 }
 
-func NewClient(ctx context.Context) (*Client, error) {
+// NewClient creates a new Client making at most concurrency requests at a
+// time. The server is read from OLLAMA_BASE_URL. OLLAMA_TIMEOUT bounds how
+// long to wait for a response to start, which for requests that are not
+// streamed is the whole generation, and OLLAMA_MAX_RETRIES sets how often
+// failed requests are retried. OLLAMA_REQUESTS_PER_MINUTE and
+// OLLAMA_TOKENS_PER_MINUTE optionally limit the rate per model.
+func NewClient(ctx context.Context, concurrency int) (*Client, error) {
+	sem, err := syncext.NewSemaphore(concurrency)
+	if err != nil {
+		return nil, err
+	}
+
 	baseURL := os.Getenv("OLLAMA_BASE_URL")
 	if baseURL == "" {
 		baseURL = "http://localhost:11434"
 	}
 
+	timeout := defaultTimeout
+	if value := os.Getenv("OLLAMA_TIMEOUT"); value != "" {
+		timeout, err = time.ParseDuration(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid OLLAMA_TIMEOUT: %w", err)
+		}
+	}
+
+	maxRetries := defaultMaxRetries
+	if value := os.Getenv("OLLAMA_MAX_RETRIES"); value != "" {
+		maxRetries, err = strconv.Atoi(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid OLLAMA_MAX_RETRIES: %w", err)
+		}
+	}
+
+	limiter, err := ratelimit.FromEnv("OLLAMA")
+	if err != nil {
+		return nil, err
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.ResponseHeaderTimeout = timeout
+
 	return &Client{
-		baseURL:    baseURL,
-		httpClient: &http.Client{},
+		poster: &chatapi.Poster{
+			Provider:         "ollama",
+			URL:              baseURL + "/api/chat",
+			HTTPClient:       &http.Client{Transport: transport},
+			MaxRetries:       maxRetries,
+			Limiter:          limiter,
+			IsRetryableError: isRetryableError,
+		},
+		semaphore: sem,
 	}, nil
 }
 
 func (c *Client) Ask(ctx context.Context, persona string, request string) (*string, error) {
 	model := modelFromContext(ctx)
 
-	messages := []message{
-		{
-			Role:    "system",
-			Content: persona,
-		},
-		{
-			Role:    "user",
-			Content: request,
-		},
+	chatResp, err := c.chat(ctx, model, chatapi.NewMessages[toolCall](persona, request))
+	if err != nil {
+		return nil, err
 	}
 
-	chatReq := chatRequest{
-		Model:    model,
-		Messages: messages,
-		Stream:   false,
-	}
+	responseText := strings.TrimSpace(chatResp.Message.Content)
+	return &responseText, nil
+}
 
-	reqBody, err := json.Marshal(chatReq)
+// AskWithSources returns the response from Ask. There is no grounding, so
+// it never has references.
+func (c *Client) AskWithSources(ctx context.Context, persona string, request string) (*models.Answer, error) {
+	responseText, err := c.Ask(ctx, persona, request)
 	if err != nil {
-		return nil, fmt.Errorf("failed to marshal request: %w", err)
+		return nil, err
 	}
 
-	req, err := http.NewRequestWithContext(ctx, "POST", c.baseURL+"/api/chat", bytes.NewBuffer(reqBody))
-	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
+	return &models.Answer{Text: *responseText}, nil
+}
+
+func (c *Client) AskStream(ctx context.Context, persona string, request string, handler models.StreamHandler) (*string, error) {
+	// The permit is held until the stream ends
+	if err := c.semaphore.Acquire(ctx); err != nil {
+		return nil, err
 	}
+	defer c.semaphore.Release()
 
-	req.Header.Set("Content-Type", "application/json")
+	model := modelFromContext(ctx)
 
-	resp, err := c.httpClient.Do(req)
+	chatReq := chatRequest{
+		Model:    model,
+		Messages: chatapi.NewMessages[toolCall](persona, request),
+		Stream:   true,
+	}
+
+	resp, err := c.sendChat(ctx, chatReq)
 	if err != nil {
-		return nil, fmt.Errorf("failed to send request: %w", err)
+		return nil, err
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("ollama API returned status %d: %s", resp.StatusCode, string(body))
-	}
+	var response strings.Builder
 
-	var chatResp chatResponse
-	if err := json.NewDecoder(resp.Body).Decode(&chatResp); err != nil {
-		return nil, fmt.Errorf("failed to decode response: %w", err)
+	// Streamed responses are newline delimited JSON objects, the last of
+	// which has done set. A server that fails part way sends an error
+	// object instead, or just closes the connection.
+	decoder := json.NewDecoder(resp.Body)
+
+	for {
+		var chatResp chatResponse
+		if err := decoder.Decode(&chatResp); err != nil {
+			if err == io.EOF {
+				return nil, fmt.Errorf("stream ended before the response was done: %w", io.ErrUnexpectedEOF)
+			}
+			return nil, fmt.Errorf("failed to decode response: %w", err)
+		}
+
+		if chatResp.Error != "" {
+			return nil, fmt.Errorf("stream failed: %s", chatResp.Error)
+		}
+
+		if chunk := chatResp.Message.Content; chunk != "" {
+			response.WriteString(chunk)
+
+			if err := handler(chunk); err != nil {
+				return nil, err
+			}
+		}
+
+		if chatResp.Done {
+			c.recordUsage(ctx, model, chatResp)
+
+			if err := checkDoneReason(chatResp.DoneReason, response.String()); err != nil {
+				return nil, err
+			}
+
+			break
+		}
 	}
 
-	responseText := strings.TrimSpace(chatResp.Message.Content)
+	responseText := strings.TrimSpace(response.String())
 	return &responseText, nil
 }
 
-func (c *Client) StructuredAsk(ctx context.Context, persona string, request string, schema map[string]any) (json.RawMessage, error) {
+func (c *Client) Chat(ctx context.Context, persona string, messages []models.Message) (*string, error) {
+	if err := models.ValidateMessages(messages); err != nil {
+		return nil, err
+	}
+
 	model := modelFromContext(ctx)
 
-	// Convert schema to JSON string for the format parameter
-	schemaJSON, err := json.Marshal(schema)
+	chatResp, err := c.chat(ctx, model, chatapi.NewConversation[toolCall](persona, messages))
 	if err != nil {
-		return nil, fmt.Errorf("failed to marshal schema: %w", err)
+		return nil, err
 	}
 
-	// Enhance persona with JSON format instruction
-	enhancedPersona := persona + "\n\nYou must respond with valid JSON that matches the following schema:\n" + string(schemaJSON)
+	responseText := strings.TrimSpace(chatResp.Message.Content)
+	return &responseText, nil
+}
 
-	messages := []message{
-		{
-			Role:    "system",
-			Content: enhancedPersona,
-		},
-		{
-			Role:    "user",
-			Content: request,
-		},
-	}
+func (c *Client) StructuredAsk(ctx context.Context, persona string, request string, schema map[string]any) (json.RawMessage, error) {
+	return structured.Ask(ctx, request, schema, func(ctx context.Context, request string) (string, error) {
+		chatResp, err := c.structuredComplete(ctx, persona, request, schema)
+		if err != nil {
+			return "", err
+		}
+
+		return chatResp.Message.Content, nil
+	})
+}
 
+// structuredComplete sends the schema as the request format. Servers too old
+// to accept a schema there reject the request, after which the client falls
+// back to JSON mode with the schema described in the system prompt.
+func (c *Client) structuredComplete(ctx context.Context, persona string, request string, schema map[string]any) (*chatResponse, error) {
 	chatReq := chatRequest{
-		Model:    model,
-		Messages: messages,
-		Format:   "json",
+		Model:    modelFromContext(ctx),
+		Messages: chatapi.NewMessages[toolCall](persona, request),
+		Format:   schema,
 		Stream:   false,
 	}
 
-	reqBody, err := json.Marshal(chatReq)
-	if err != nil {
-		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	if !c.legacyFormat.Load() {
+		chatResp, err := c.complete(ctx, chatReq)
+		if !isFormatUnsupported(err) {
+			return chatResp, err
+		}
+
+		slog.WarnContext(ctx, "ollama_schema_format_unsupported",
+			slog.String("error", err.Error()),
+		)
+
+		c.legacyFormat.Store(true)
 	}
 
-	req, err := http.NewRequestWithContext(ctx, "POST", c.baseURL+"/api/chat", bytes.NewBuffer(reqBody))
+	schemaJSON, err := json.Marshal(schema)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
+		return nil, fmt.Errorf("failed to marshal schema: %w", err)
 	}
 
-	req.Header.Set("Content-Type", "application/json")
+	chatReq.Format = "json"
+	chatReq.Messages[0].Content = persona + "\n\nYou must respond with valid JSON that matches the following schema:\n" + string(schemaJSON)
 
-	resp, err := c.httpClient.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("failed to send request: %w", err)
+	return c.complete(ctx, chatReq)
+}
+
+// complete sends a non-streaming request and decodes the response. The
+// permit is taken for this round-trip only, so tool handlers run without one
+// and may call back into the client.
+func (c *Client) complete(ctx context.Context, chatReq chatRequest) (*chatResponse, error) {
+	if err := c.semaphore.Acquire(ctx); err != nil {
+		return nil, err
 	}
-	defer resp.Body.Close()
+	defer c.semaphore.Release()
 
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("ollama API returned status %d: %s", resp.StatusCode, string(body))
+	resp, err := c.sendChat(ctx, chatReq)
+	if err != nil {
+		return nil, err
 	}
+	defer resp.Body.Close()
 
 	var chatResp chatResponse
 	if err := json.NewDecoder(resp.Body).Decode(&chatResp); err != nil {
 		return nil, fmt.Errorf("failed to decode response: %w", err)
 	}
 
-	responseText := strings.TrimSpace(chatResp.Message.Content)
+	c.recordUsage(ctx, chatReq.Model, chatResp)
 
-	// Remove markdown code blocks if present
-	responseText = strings.TrimPrefix(responseText, "```json")
-	responseText = strings.TrimPrefix(responseText, "```")
-	responseText = strings.TrimSuffix(responseText, "```")
-	responseText = strings.TrimSpace(responseText)
+	if err := checkDoneReason(chatResp.DoneReason, chatResp.Message.Content); err != nil {
+		return nil, err
+	}
 
-	var responseJSON json.RawMessage
-	if err := json.Unmarshal([]byte(responseText), &responseJSON); err != nil {
-		return nil, fmt.Errorf("failed to parse JSON response: %w", err)
+	return &chatResp, nil
+}
+
+// checkDoneReason returns a *models.TruncatedError when the response hit the
+// num_predict limit.
+func checkDoneReason(doneReason string, text string) error {
+	if doneReason == "length" {
+		return &models.TruncatedError{FinishReason: doneReason, Text: text}
+	}
+
+	return nil
+}
+
+// sendChat posts the request, retrying connection errors and responses that
+// say the server is busy or still loading the model.
+func (c *Client) sendChat(ctx context.Context, chatReq chatRequest) (*http.Response, error) {
+	if err := usage.Check(ctx); err != nil {
+		return nil, err
+	}
+
+	applyOptions(ctx, &chatReq)
+
+	if err := applyAttachments(ctx, &chatReq); err != nil {
+		return nil, err
 	}
 
-	return responseJSON, nil
+	return c.poster.Post(ctx, chatReq.Model, chatReq)
+}
+
+// isRetryableError reports whether a request may succeed if sent again: the
+// server could not be reached, failed, was overloaded or was still loading
+// the model. Timeouts are not retried, the model is just slow.
+func isRetryableError(err error) bool {
+	var statusErr *chatapi.StatusError
+	if errors.As(err, &statusErr) {
+		return retry.IsRetryableStatus(statusErr.Status) || strings.Contains(statusErr.Body, "loading model")
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return false
+	}
+
+	return retry.IsTransient(err)
+}
+
+// applyAttachments sends the attachments set on the context as images of
+// the last user message. Ollama only accepts images, so any other kind of
+// attachment fails the call.
+func applyAttachments(ctx context.Context, chatReq *chatRequest) error {
+	attachments := models.AttachmentsFromContext(ctx)
+	if len(attachments) == 0 {
+		return nil
+	}
+
+	images := make([][]byte, 0, len(attachments))
+
+	for _, attachment := range attachments {
+		if !attachment.IsImage() {
+			return fmt.Errorf("%w: ollama only accepts images, not %s", models.ErrAttachmentsUnsupported, attachment.MIMEType)
+		}
+
+		images = append(images, attachment.Data)
+	}
+
+	// The messages are copied so the caller's conversation is left alone
+	chatReq.Messages = slices.Clone(chatReq.Messages)
+
+	for i := len(chatReq.Messages) - 1; i >= 0; i-- {
+		if chatReq.Messages[i].Role == "user" {
+			chatReq.Messages[i].Images = images
+			break
+		}
+	}
+
+	return nil
+}
+
+// applyOptions copies the generation options set on the context into the
+// request. Ollama has no thinking budget, so any budget enables thinking and
+// a budget of zero disables it.
+func applyOptions(ctx context.Context, chatReq *chatRequest) {
+	generationOptions := models.GenerationOptionsFromContext(ctx)
+
+	if generationOptions.ThinkingBudget != nil {
+		think := *generationOptions.ThinkingBudget > 0
+		chatReq.Think = &think
+	}
+
+	if generationOptions.Temperature == nil && generationOptions.TopP == nil &&
+		generationOptions.MaxOutputTokens == nil && generationOptions.Seed == nil &&
+		generationOptions.StopSequences == nil {
+		return
+	}
+
+	chatReq.Options = &options{
+		Temperature: generationOptions.Temperature,
+		TopP:        generationOptions.TopP,
+		NumPredict:  generationOptions.MaxOutputTokens,
+		Seed:        generationOptions.Seed,
+		Stop:        generationOptions.StopSequences,
+	}
+}
+
+// isFormatUnsupported reports whether the server rejected a request because
+// it only accepts "json" as the format, which Ollama did before 0.5.
+func isFormatUnsupported(err error) bool {
+	var statusErr *chatapi.StatusError
+	if !errors.As(err, &statusErr) || statusErr.Status != http.StatusBadRequest {
+		return false
+	}
+
+	return strings.Contains(statusErr.Body, "format") && strings.Contains(statusErr.Body, "cannot unmarshal")
+}
+
+// recordUsage records token usage.
+func (c *Client) recordUsage(ctx context.Context, model string, chatResp chatResponse) {
+	c.poster.RecordUsage(ctx, usage.Usage{
+		Model:          model,
+		PromptTokens:   chatResp.PromptEvalCount,
+		ResponseTokens: chatResp.EvalCount,
+	})
 }
 
 // WithModel returns a context with the specified model set.
 func (c *Client) WithModel(ctx context.Context, model string) context.Context {
 	return context.WithValue(ctx, modelKey, model)
 }
+
+// DefaultModel returns the model used when none is set with WithModel.
+func (c *Client) DefaultModel() string {
+	return defaultModel
+}
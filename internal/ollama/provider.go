@@ -0,0 +1,25 @@
+package ollama
+
+import (
+	"context"
+
+	"github.com/schraf/assistant/pkg/models"
+	"github.com/schraf/assistant/pkg/providers"
+)
+
+// Local servers usually run a single GPU, so requests are serialised unless
+// configured otherwise.
+const defaultConcurrency = 1
+
+func init() {
+	providers.MustRegister("ollama", newProvider)
+}
+
+func newProvider(ctx context.Context, config providers.Config) (models.Assistant, error) {
+	concurrency, err := config.Int("concurrency", defaultConcurrency)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewClient(ctx, concurrency)
+}
@@ -0,0 +1,63 @@
+package ollama
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/schraf/assistant/internal/chatapi"
+	"github.com/schraf/assistant/pkg/models"
+)
+
+type toolCall struct {
+	Function struct {
+		Name      string          `json:"name"`
+		Arguments json.RawMessage `json:"arguments"`
+	} `json:"function"`
+}
+
+// chat sends the messages and, for as long as the model calls the tools set
+// on the context, answers the calls with tool messages until the model gives
+// a final response.
+func (c *Client) chat(ctx context.Context, model string, messages []message) (*chatResponse, error) {
+	tools := models.ToolsFromContext(ctx)
+	maxIterations := models.MaxToolIterationsFromContext(ctx)
+
+	for iteration := 0; ; iteration++ {
+		chatReq := chatRequest{
+			Model:    model,
+			Messages: messages,
+			Tools:    chatapi.NewTools(tools),
+			Stream:   false,
+		}
+
+		chatResp, err := c.complete(ctx, chatReq)
+		if err != nil {
+			return nil, err
+		}
+
+		calls := chatResp.Message.ToolCalls
+		if len(tools) == 0 || len(calls) == 0 {
+			return chatResp, nil
+		}
+
+		if iteration >= maxIterations {
+			return nil, models.ErrToolIterationsExceeded
+		}
+
+		messages = append(messages, chatResp.Message)
+
+		for _, call := range calls {
+			result, err := json.Marshal(models.CallTool(ctx, tools, call.Function.Name, call.Function.Arguments))
+			if err != nil {
+				return nil, fmt.Errorf("failed to marshal result of tool '%s': %w", call.Function.Name, err)
+			}
+
+			messages = append(messages, message{
+				Role:     "tool",
+				Content:  string(result),
+				ToolName: call.Function.Name,
+			})
+		}
+	}
+}
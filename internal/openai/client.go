@@ -0,0 +1,410 @@
+package openai
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/schraf/assistant/internal/chatapi"
+	"github.com/schraf/assistant/internal/ratelimit"
+	"github.com/schraf/assistant/internal/structured"
+	"github.com/schraf/assistant/internal/usage"
+	"github.com/schraf/assistant/pkg/models"
+	"github.com/schraf/syncext"
+)
+
+const (
+	defaultBaseURL    = "https://api.openai.com/v1"
+	defaultMaxRetries = 3
+)
+
+// Client implements models.Assistant against any server that speaks the
+// OpenAI chat completions protocol (OpenAI, vLLM, LM Studio, llama.cpp, ...).
+type Client struct {
+	poster    *chatapi.Poster
+	semaphore *syncext.Semaphore
+}
+
+type chatRequest struct {
+	Model          string          `json:"model"`
+	Messages       []message       `json:"messages"`
+	Tools          []chatapi.Tool  `json:"tools,omitempty"`
+	ResponseFormat *responseFormat `json:"response_format,omitempty"`
+	Stream         bool            `json:"stream"`
+	StreamOptions  *streamOptions  `json:"stream_options,omitempty"`
+	Temperature    *float64        `json:"temperature,omitempty"`
+	TopP           *float64        `json:"top_p,omitempty"`
+	MaxTokens      *int            `json:"max_tokens,omitempty"`
+	Seed           *int            `json:"seed,omitempty"`
+	Stop           []string        `json:"stop,omitempty"`
+}
+
+type streamOptions struct {
+	IncludeUsage bool `json:"include_usage"`
+}
+
+type message = chatapi.Message[toolCall]
+
+type responseFormat struct {
+	Type       string      `json:"type"`
+	JSONSchema *jsonSchema `json:"json_schema,omitempty"`
+}
+
+type jsonSchema struct {
+	Name   string         `json:"name"`
+	Schema map[string]any `json:"schema"`
+}
+
+type chatResponse struct {
+	Choices []struct {
+		Message      message `json:"message"`
+		FinishReason string  `json:"finish_reason"`
+	} `json:"choices"`
+	Usage *usageResponse `json:"usage"`
+}
+
+type usageResponse struct {
+	PromptTokens            int `json:"prompt_tokens"`
+	CompletionTokens        int `json:"completion_tokens"`
+	CompletionTokensDetails struct {
+		ReasoningTokens int `json:"reasoning_tokens"`
+	} `json:"completion_tokens_details"`
+}
+
+type streamResponse struct {
+	Choices []struct {
+		Delta struct {
+			Content string `json:"content"`
+		} `json:"delta"`
+		FinishReason string `json:"finish_reason"`
+	} `json:"choices"`
+	Usage *usageResponse `json:"usage"`
+	Error *struct {
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// NewClient creates a new Client making at most concurrency requests at a
+// time. The endpoint is read from OPENAI_BASE_URL
+// (defaulting to the OpenAI API) and the bearer token from OPENAI_API_KEY,
+// which may be left empty for local servers that do not authenticate.
+// OPENAI_MAX_RETRIES sets how often failed requests are retried, and
+// OPENAI_REQUESTS_PER_MINUTE and OPENAI_TOKENS_PER_MINUTE optionally keep
+// each model under its quota.
+func NewClient(ctx context.Context, concurrency int) (*Client, error) {
+	sem, err := syncext.NewSemaphore(concurrency)
+	if err != nil {
+		return nil, err
+	}
+
+	baseURL := os.Getenv("OPENAI_BASE_URL")
+	if baseURL == "" {
+		baseURL = defaultBaseURL
+	}
+
+	maxRetries := defaultMaxRetries
+	if value := os.Getenv("OPENAI_MAX_RETRIES"); value != "" {
+		var err error
+
+		maxRetries, err = strconv.Atoi(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid OPENAI_MAX_RETRIES: %w", err)
+		}
+	}
+
+	limiter, err := ratelimit.FromEnv("OPENAI")
+	if err != nil {
+		return nil, err
+	}
+
+	header := http.Header{}
+
+	if apiKey := os.Getenv("OPENAI_API_KEY"); apiKey != "" {
+		header.Set("Authorization", "Bearer "+apiKey)
+	}
+
+	return &Client{
+		poster: &chatapi.Poster{
+			Provider:   "openai",
+			URL:        strings.TrimSuffix(baseURL, "/") + "/chat/completions",
+			Header:     header,
+			HTTPClient: &http.Client{},
+			MaxRetries: maxRetries,
+			Limiter:    limiter,
+		},
+		semaphore: sem,
+	}, nil
+}
+
+func (c *Client) Ask(ctx context.Context, persona string, request string) (*string, error) {
+	chatReq := chatRequest{
+		Model:    modelFromContext(ctx),
+		Messages: chatapi.NewMessages[toolCall](persona, request),
+		Stream:   false,
+	}
+
+	responseText, err := c.chat(ctx, chatReq)
+	if err != nil {
+		return nil, err
+	}
+
+	return &responseText, nil
+}
+
+// AskWithSources returns the response from Ask. There is no grounding, so
+// it never has references.
+func (c *Client) AskWithSources(ctx context.Context, persona string, request string) (*models.Answer, error) {
+	responseText, err := c.Ask(ctx, persona, request)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.Answer{Text: *responseText}, nil
+}
+
+func (c *Client) AskStream(ctx context.Context, persona string, request string, handler models.StreamHandler) (*string, error) {
+	chatReq := chatRequest{
+		Model:    modelFromContext(ctx),
+		Messages: chatapi.NewMessages[toolCall](persona, request),
+		Stream:   true,
+		StreamOptions: &streamOptions{
+			IncludeUsage: true,
+		},
+	}
+
+	// The permit is held until the stream ends
+	if err := c.semaphore.Acquire(ctx); err != nil {
+		return nil, err
+	}
+	defer c.semaphore.Release()
+
+	resp, err := c.sendChat(ctx, chatReq)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var response strings.Builder
+	var finishReason string
+	var done bool
+
+	// Streamed responses are server-sent events, each carrying a delta of
+	// the message, terminated by a [DONE] event. A server that fails part
+	// way sends an error object instead, or just closes the connection.
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	for scanner.Scan() {
+		data, ok := strings.CutPrefix(scanner.Text(), "data:")
+		if !ok {
+			continue
+		}
+
+		data = strings.TrimSpace(data)
+		if data == "[DONE]" {
+			done = true
+			break
+		}
+
+		var chunkResp streamResponse
+		if err := json.Unmarshal([]byte(data), &chunkResp); err != nil {
+			return nil, fmt.Errorf("failed to decode stream chunk: %w", err)
+		}
+
+		if chunkResp.Error != nil {
+			return nil, fmt.Errorf("stream failed: %s", chunkResp.Error.Message)
+		}
+
+		// With include_usage set the final chunk carries usage and no choices
+		if chunkResp.Usage != nil {
+			c.recordUsage(ctx, chatReq.Model, chunkResp.Usage)
+		}
+
+		if len(chunkResp.Choices) == 0 {
+			continue
+		}
+
+		if chunk := chunkResp.Choices[0].Delta.Content; chunk != "" {
+			response.WriteString(chunk)
+
+			if err := handler(chunk); err != nil {
+				return nil, err
+			}
+		}
+
+		if reason := chunkResp.Choices[0].FinishReason; reason != "" {
+			finishReason = reason
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read stream: %w", err)
+	}
+
+	if !done && finishReason == "" {
+		return nil, fmt.Errorf("stream ended before the response was done: %w", io.ErrUnexpectedEOF)
+	}
+
+	// Checked once the stream ends so the usage chunk is still recorded
+	if err := checkFinishReason(finishReason, response.String()); err != nil {
+		return nil, err
+	}
+
+	responseText := strings.TrimSpace(response.String())
+	return &responseText, nil
+}
+
+func (c *Client) Chat(ctx context.Context, persona string, messages []models.Message) (*string, error) {
+	if err := models.ValidateMessages(messages); err != nil {
+		return nil, err
+	}
+
+	chatReq := chatRequest{
+		Model:    modelFromContext(ctx),
+		Messages: chatapi.NewConversation[toolCall](persona, messages),
+		Stream:   false,
+	}
+
+	responseText, err := c.chat(ctx, chatReq)
+	if err != nil {
+		return nil, err
+	}
+
+	return &responseText, nil
+}
+
+func (c *Client) StructuredAsk(ctx context.Context, persona string, request string, schema map[string]any) (json.RawMessage, error) {
+	chatReq := chatRequest{
+		Model:    modelFromContext(ctx),
+		Messages: chatapi.NewMessages[toolCall](persona, request),
+		ResponseFormat: &responseFormat{
+			Type: "json_schema",
+			JSONSchema: &jsonSchema{
+				Name:   "response",
+				Schema: schema,
+			},
+		},
+		Stream: false,
+	}
+
+	return structured.Ask(ctx, request, schema, func(ctx context.Context, request string) (string, error) {
+		chatReq.Messages = chatapi.NewMessages[toolCall](persona, request)
+
+		response, err := c.chatCompletion(ctx, chatReq)
+		if err != nil {
+			return "", err
+		}
+
+		return response.Content, nil
+	})
+}
+
+// WithModel returns a context with the specified model set.
+func (c *Client) WithModel(ctx context.Context, model string) context.Context {
+	return context.WithValue(ctx, modelKey, model)
+}
+
+// DefaultModel returns the model used when none is set with WithModel.
+func (c *Client) DefaultModel() string {
+	return defaultModel
+}
+
+// chatCompletion sends a non-streaming request and returns the message of
+// the first choice. The permit is taken for this round-trip only, so tool
+// handlers run without one and may call back into the client.
+func (c *Client) chatCompletion(ctx context.Context, chatReq chatRequest) (*message, error) {
+	if err := c.semaphore.Acquire(ctx); err != nil {
+		return nil, err
+	}
+	defer c.semaphore.Release()
+
+	resp, err := c.sendChat(ctx, chatReq)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var chatResp chatResponse
+	if err := json.NewDecoder(resp.Body).Decode(&chatResp); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	if chatResp.Usage != nil {
+		c.recordUsage(ctx, chatReq.Model, chatResp.Usage)
+	}
+
+	if len(chatResp.Choices) == 0 {
+		return nil, fmt.Errorf("openai API returned no choices")
+	}
+
+	choice := chatResp.Choices[0]
+
+	if err := checkFinishReason(choice.FinishReason, choice.Message.Content); err != nil {
+		return nil, err
+	}
+
+	return &choice.Message, nil
+}
+
+// checkFinishReason returns a *models.TruncatedError when the response hit
+// the token limit and a *models.ContentBlockedError when it was filtered.
+func checkFinishReason(finishReason string, text string) error {
+	switch finishReason {
+	case "length":
+		return &models.TruncatedError{FinishReason: finishReason, Text: text}
+	case "content_filter":
+		return &models.ContentBlockedError{FinishReason: finishReason}
+	}
+
+	return nil
+}
+
+// sendChat posts the request, retrying rate limits, server errors and
+// dropped connections.
+func (c *Client) sendChat(ctx context.Context, chatReq chatRequest) (*http.Response, error) {
+	if err := usage.Check(ctx); err != nil {
+		return nil, err
+	}
+
+	// Attachments are only implemented for Gemini and Ollama. Failing the
+	// call is better than answering without the file.
+	if len(models.AttachmentsFromContext(ctx)) > 0 {
+		return nil, fmt.Errorf("openai: %w", models.ErrAttachmentsUnsupported)
+	}
+
+	applyOptions(ctx, &chatReq)
+
+	return c.poster.Post(ctx, chatReq.Model, chatReq)
+}
+
+// applyOptions copies the generation options set on the context into the
+// request. The chat completions protocol has no thinking budget, so that
+// option is ignored.
+func applyOptions(ctx context.Context, chatReq *chatRequest) {
+	options := models.GenerationOptionsFromContext(ctx)
+
+	chatReq.Temperature = options.Temperature
+	chatReq.TopP = options.TopP
+	chatReq.MaxTokens = options.MaxOutputTokens
+	chatReq.Seed = options.Seed
+	chatReq.Stop = options.StopSequences
+}
+
+// recordUsage records token usage. Reasoning tokens are reported as part of
+// the completion tokens, so they are split out here.
+func (c *Client) recordUsage(ctx context.Context, model string, u *usageResponse) {
+	reasoning := u.CompletionTokensDetails.ReasoningTokens
+
+	c.poster.RecordUsage(ctx, usage.Usage{
+		Model:          model,
+		PromptTokens:   u.PromptTokens,
+		ResponseTokens: u.CompletionTokens - reasoning,
+		ThinkingTokens: reasoning,
+	})
+}
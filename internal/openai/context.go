@@ -0,0 +1,17 @@
+package openai
+
+import "context"
+
+type contextKey struct{}
+
+var modelKey contextKey
+
+const defaultModel = "gpt-4o-mini"
+
+func modelFromContext(ctx context.Context) string {
+	if model, ok := ctx.Value(modelKey).(string); ok {
+		return model
+	}
+
+	return defaultModel
+}
@@ -0,0 +1,61 @@
+package openai
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/schraf/assistant/internal/chatapi"
+	"github.com/schraf/assistant/pkg/models"
+)
+
+type toolCall struct {
+	ID       string `json:"id"`
+	Type     string `json:"type"`
+	Function struct {
+		Name      string `json:"name"`
+		Arguments string `json:"arguments"`
+	} `json:"function"`
+}
+
+// chat sends the request and, for as long as the model calls the tools set
+// on the context, answers the calls with tool messages until the model gives
+// a final response.
+func (c *Client) chat(ctx context.Context, chatReq chatRequest) (string, error) {
+	tools := models.ToolsFromContext(ctx)
+	maxIterations := models.MaxToolIterationsFromContext(ctx)
+
+	chatReq.Tools = chatapi.NewTools(tools)
+
+	for iteration := 0; ; iteration++ {
+		response, err := c.chatCompletion(ctx, chatReq)
+		if err != nil {
+			return "", err
+		}
+
+		if len(tools) == 0 || len(response.ToolCalls) == 0 {
+			return strings.TrimSpace(response.Content), nil
+		}
+
+		if iteration >= maxIterations {
+			return "", models.ErrToolIterationsExceeded
+		}
+
+		chatReq.Messages = append(chatReq.Messages, *response)
+
+		for _, call := range response.ToolCalls {
+			// Arguments arrive as a JSON encoded string
+			result, err := json.Marshal(models.CallTool(ctx, tools, call.Function.Name, json.RawMessage(call.Function.Arguments)))
+			if err != nil {
+				return "", fmt.Errorf("failed to marshal result of tool '%s': %w", call.Function.Name, err)
+			}
+
+			chatReq.Messages = append(chatReq.Messages, message{
+				Role:       "tool",
+				Content:    string(result),
+				ToolCallID: call.ID,
+			})
+		}
+	}
+}
@@ -0,0 +1,248 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Limits are per-minute limits by model. The entry for the empty model is
+// the default for models without their own entry. Zero means unlimited.
+type Limits map[string]int
+
+// For returns the limit for model.
+func (l Limits) For(model string) int {
+	if limit, ok := l[model]; ok {
+		return limit
+	}
+
+	return l[""]
+}
+
+// ParseLimits parses a comma separated list of limits, where a bare number
+// is the default and model=number sets the limit of one model, e.g.
+// "60,gemini-pro-latest=5".
+func ParseLimits(value string) (Limits, error) {
+	limits := Limits{}
+
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		model, limit, found := strings.Cut(entry, "=")
+		if !found {
+			model, limit = "", entry
+		}
+
+		n, err := strconv.Atoi(strings.TrimSpace(limit))
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("invalid limit '%s'", entry)
+		}
+
+		limits[strings.TrimSpace(model)] = n
+	}
+
+	return limits, nil
+}
+
+// Limiter keeps a requests-per-minute and a tokens-per-minute token bucket
+// for each model. Buckets start full, so a burst of up to a minute's quota
+// goes through at once. It is safe for concurrent use, and a nil *Limiter
+// does not limit anything.
+type Limiter struct {
+	requests Limits
+	tokens   Limits
+
+	lock    sync.Mutex
+	buckets map[string]*buckets
+}
+
+type buckets struct {
+	requests *bucket
+	tokens   *bucket
+}
+
+// New returns a Limiter enforcing the given limits.
+func New(requests Limits, tokens Limits) *Limiter {
+	return &Limiter{
+		requests: requests,
+		tokens:   tokens,
+		buckets:  make(map[string]*buckets),
+	}
+}
+
+// FromEnv returns a Limiter configured by the <prefix>_REQUESTS_PER_MINUTE
+// and <prefix>_TOKENS_PER_MINUTE environment variables, or nil when neither
+// is set.
+func FromEnv(prefix string) (*Limiter, error) {
+	var requests, tokens Limits
+
+	for _, setting := range []struct {
+		name   string
+		limits *Limits
+	}{
+		{prefix + "_REQUESTS_PER_MINUTE", &requests},
+		{prefix + "_TOKENS_PER_MINUTE", &tokens},
+	} {
+		value := os.Getenv(setting.name)
+		if value == "" {
+			continue
+		}
+
+		limits, err := ParseLimits(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s: %w", setting.name, err)
+		}
+
+		*setting.limits = limits
+	}
+
+	if requests == nil && tokens == nil {
+		return nil, nil
+	}
+
+	return New(requests, tokens), nil
+}
+
+// Wait blocks until model may send another request: a request is left in
+// the minute's quota and the tokens used so far have not run over it. Token
+// counts are only known once a response arrives, so Record charges them
+// afterwards and an overrun delays the following requests.
+func (l *Limiter) Wait(ctx context.Context, model string) error {
+	if l == nil {
+		return nil
+	}
+
+	logged := false
+
+	for {
+		wait := l.reserve(model)
+		if wait == 0 {
+			return nil
+		}
+
+		if !logged {
+			slog.InfoContext(ctx, "rate_limited",
+				slog.String("model", model),
+				slog.Duration("wait", wait),
+			)
+			logged = true
+		}
+
+		timer := time.NewTimer(wait)
+
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// Record charges the tokens a request used to the model's quota.
+func (l *Limiter) Record(model string, tokens int) {
+	if l == nil || tokens <= 0 {
+		return
+	}
+
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	if b := l.bucketsFor(model).tokens; b != nil {
+		b.take(time.Now(), float64(tokens))
+	}
+}
+
+// reserve takes a request from the model's quota and returns zero, or
+// returns how long to wait before trying again.
+func (l *Limiter) reserve(model string) time.Duration {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	now := time.Now()
+	b := l.bucketsFor(model)
+
+	// A request needs a request and at least one token left
+	wait := max(b.requests.waitFor(now, 1), b.tokens.waitFor(now, 1))
+	if wait > 0 {
+		return wait
+	}
+
+	b.requests.take(now, 1)
+
+	return 0
+}
+
+func (l *Limiter) bucketsFor(model string) *buckets {
+	b, ok := l.buckets[model]
+	if !ok {
+		b = &buckets{
+			requests: newBucket(l.requests.For(model)),
+			tokens:   newBucket(l.tokens.For(model)),
+		}
+		l.buckets[model] = b
+	}
+
+	return b
+}
+
+// bucket is a token bucket holding up to a minute's quota and refilling
+// continuously. Its level can go negative when more is taken than it holds.
+// A nil *bucket is unlimited.
+type bucket struct {
+	capacity float64
+	level    float64
+	updated  time.Time
+}
+
+func newBucket(perMinute int) *bucket {
+	if perMinute <= 0 {
+		return nil
+	}
+
+	return &bucket{
+		capacity: float64(perMinute),
+		level:    float64(perMinute),
+		updated:  time.Now(),
+	}
+}
+
+func (b *bucket) refill(now time.Time) {
+	elapsed := now.Sub(b.updated).Minutes()
+	b.level = min(b.capacity, b.level+elapsed*b.capacity)
+	b.updated = now
+}
+
+// waitFor returns how long until the level reaches need.
+func (b *bucket) waitFor(now time.Time, need float64) time.Duration {
+	if b == nil {
+		return 0
+	}
+
+	b.refill(now)
+
+	if b.level >= need {
+		return 0
+	}
+
+	missing := need - b.level
+
+	return max(time.Duration(missing/b.capacity*float64(time.Minute)), time.Millisecond)
+}
+
+func (b *bucket) take(now time.Time, amount float64) {
+	if b == nil {
+		return
+	}
+
+	b.refill(now)
+	b.level -= amount
+}
@@ -0,0 +1,164 @@
+package ratelimit
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestParseLimits(t *testing.T) {
+	limits, err := ParseLimits("60, gemini-pro-latest=5")
+	if err != nil {
+		t.Fatalf("ParseLimits() error = %v", err)
+	}
+
+	if n := limits.For("gemini-pro-latest"); n != 5 {
+		t.Errorf("For(gemini-pro-latest) = %d, want 5", n)
+	}
+
+	if n := limits.For("gemini-flash-latest"); n != 60 {
+		t.Errorf("other models should use the default, got %d", n)
+	}
+
+	limits, err = ParseLimits("gpt-4o=10")
+	if err != nil {
+		t.Fatalf("ParseLimits() error = %v", err)
+	}
+
+	if n := limits.For("gpt-4o-mini"); n != 0 {
+		t.Errorf("models without a limit should be unlimited, got %d", n)
+	}
+
+	for _, value := range []string{"fast", "gpt-4o=-1"} {
+		if _, err := ParseLimits(value); err == nil {
+			t.Errorf("ParseLimits(%q) should fail", value)
+		}
+	}
+}
+
+// waitWithTimeout reports whether the limiter let a request through within
+// a short time.
+func waitWithTimeout(limiter *Limiter, model string) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	return limiter.Wait(ctx, model) == nil
+}
+
+func TestLimiter_RequestsPerMinute(t *testing.T) {
+	limiter := New(Limits{"": 2, "pro": 1}, nil)
+
+	if !waitWithTimeout(limiter, "flash") || !waitWithTimeout(limiter, "flash") {
+		t.Error("the bucket should allow a burst of the minute's quota")
+	}
+
+	if waitWithTimeout(limiter, "flash") {
+		t.Error("the third request should wait")
+	}
+
+	if !waitWithTimeout(limiter, "pro") {
+		t.Error("models should have their own buckets")
+	}
+
+	if waitWithTimeout(limiter, "pro") {
+		t.Error("the second pro request should wait")
+	}
+}
+
+func TestLimiter_Refills(t *testing.T) {
+	// 1200 a minute is one every 50ms
+	limiter := New(Limits{"": 1200}, nil)
+
+	for range 1200 {
+		if err := limiter.Wait(context.Background(), "model"); err != nil {
+			t.Fatalf("Wait() error = %v", err)
+		}
+	}
+
+	start := time.Now()
+	if err := limiter.Wait(context.Background(), "model"); err != nil {
+		t.Fatalf("Wait() error = %v", err)
+	}
+
+	if elapsed := time.Since(start); elapsed < 30*time.Millisecond {
+		t.Errorf("an empty bucket should wait for a refill, waited %v", elapsed)
+	}
+}
+
+func TestLimiter_TokensPerMinute(t *testing.T) {
+	limiter := New(nil, Limits{"": 100})
+
+	if !waitWithTimeout(limiter, "model") {
+		t.Error("the first request should go through")
+	}
+
+	limiter.Record("model", 40)
+	if !waitWithTimeout(limiter, "model") {
+		t.Error("tokens are left")
+	}
+
+	limiter.Record("model", 150)
+	if waitWithTimeout(limiter, "model") {
+		t.Error("an overrun should delay the next request")
+	}
+
+	if !waitWithTimeout(limiter, "other") {
+		t.Error("other models should not be delayed")
+	}
+}
+
+func TestLimiter_Nil(t *testing.T) {
+	var limiter *Limiter
+
+	limiter.Record("model", 1000)
+	if !waitWithTimeout(limiter, "model") {
+		t.Error("a nil limiter should never wait")
+	}
+}
+
+func TestLimiter_SharedAcrossGoroutines(t *testing.T) {
+	limiter := New(Limits{"": 5}, nil)
+
+	var (
+		allowed atomic.Int64
+		wg      sync.WaitGroup
+	)
+
+	for range 20 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if waitWithTimeout(limiter, "model") {
+				allowed.Add(1)
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	if n := allowed.Load(); n != 5 {
+		t.Errorf("%d requests went through, want 5", n)
+	}
+}
+
+func TestFromEnv(t *testing.T) {
+	t.Setenv("TEST_REQUESTS_PER_MINUTE", "")
+	t.Setenv("TEST_TOKENS_PER_MINUTE", "")
+
+	limiter, err := FromEnv("TEST")
+	if err != nil {
+		t.Fatalf("FromEnv() error = %v", err)
+	}
+
+	if limiter != nil {
+		t.Error("no limiter should be created without limits")
+	}
+
+	t.Setenv("TEST_TOKENS_PER_MINUTE", "lots")
+	if _, err := FromEnv("TEST"); err == nil || !strings.Contains(err.Error(), "invalid TEST_TOKENS_PER_MINUTE") {
+		t.Errorf("FromEnv() error = %v, want invalid TEST_TOKENS_PER_MINUTE", err)
+	}
+}
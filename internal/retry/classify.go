@@ -0,0 +1,131 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+var ErrAttemptTimeout = errors.New("attempt timed out")
+
+// IsTransient reports whether an error is likely to go away on its own:
+// rate limits, 5xx responses, gRPC Unavailable and ResourceExhausted, attempt
+// timeouts, network timeouts and dropped or refused connections.
+// Cancellation by the caller is never transient.
+func IsTransient(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) {
+		return false
+	}
+
+	if errors.Is(err, ErrAttemptTimeout) {
+		return true
+	}
+
+	if code, ok := HTTPStatus(err); ok {
+		return IsRetryableStatus(code)
+	}
+
+	if st, ok := status.FromError(err); ok && st.Code() != codes.Unknown {
+		switch st.Code() {
+		case codes.Unavailable, codes.ResourceExhausted, codes.Aborted:
+			return true
+		}
+
+		return false
+	}
+
+	if errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, syscall.EPIPE) {
+		return true
+	}
+
+	// Other network errors, such as unknown hosts or unsupported schemes,
+	// fail the same way every time
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+
+	return false
+}
+
+// IsRetryableStatus reports whether an HTTP status is a rate limit or a
+// server error.
+func IsRetryableStatus(code int) bool {
+	return code == http.StatusTooManyRequests || code == http.StatusRequestTimeout || code >= http.StatusInternalServerError
+}
+
+// HTTPStatus returns the HTTP status carried by an error that exposes one
+// through an HTTPCode or StatusCode method.
+func HTTPStatus(err error) (int, bool) {
+	var httpCoder interface{ HTTPCode() int }
+	if errors.As(err, &httpCoder) {
+		return httpCoder.HTTPCode(), true
+	}
+
+	var statusCoder interface{ StatusCode() int }
+	if errors.As(err, &statusCoder) {
+		return statusCoder.StatusCode(), true
+	}
+
+	return 0, false
+}
+
+// RetryAfterHint returns the wait requested by an error that exposes one
+// through a RetryAfter method.
+func RetryAfterHint(err error) (time.Duration, bool) {
+	var hinter interface{ RetryAfter() (time.Duration, bool) }
+	if errors.As(err, &hinter) {
+		return hinter.RetryAfter()
+	}
+
+	return 0, false
+}
+
+// ParseRetryAfter parses a Retry-After header, which is either a number of
+// seconds or an HTTP date.
+func ParseRetryAfter(value string) (time.Duration, bool) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, false
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+
+	if date, err := http.ParseTime(value); err == nil {
+		return max(time.Until(date), 0), true
+	}
+
+	return 0, false
+}
+
+// RetryInfoDelay finds the retryDelay of a google.rpc.RetryInfo entry in the
+// details of a Google API error, such as "30s" or "1.5s".
+func RetryInfoDelay(details []map[string]any) (time.Duration, bool) {
+	for _, detail := range details {
+		if kind, _ := detail["@type"].(string); !strings.HasSuffix(kind, "google.rpc.RetryInfo") {
+			continue
+		}
+
+		if delay, ok := detail["retryDelay"].(string); ok {
+			if d, err := time.ParseDuration(delay); err == nil {
+				return d, true
+			}
+		}
+	}
+
+	return 0, false
+}
@@ -0,0 +1,101 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"syscall"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type statusError struct {
+	code       int
+	retryAfter time.Duration
+}
+
+func (e statusError) Error() string {
+	return fmt.Sprintf("status %d", e.code)
+}
+
+func (e statusError) StatusCode() int {
+	return e.code
+}
+
+func (e statusError) RetryAfter() (time.Duration, bool) {
+	return e.retryAfter, e.retryAfter > 0
+}
+
+func TestIsTransient(t *testing.T) {
+	tests := []struct {
+		name      string
+		err       error
+		transient bool
+	}{
+		{"rate limited", statusError{code: http.StatusTooManyRequests}, true},
+		{"server error", statusError{code: http.StatusBadGateway}, true},
+		{"wrapped server error", fmt.Errorf("failed: %w", statusError{code: http.StatusServiceUnavailable}), true},
+		{"client error", statusError{code: http.StatusBadRequest}, false},
+		{"grpc unavailable", status.Error(codes.Unavailable, "down"), true},
+		{"grpc resource exhausted", status.Error(codes.ResourceExhausted, "quota"), true},
+		{"grpc invalid argument", status.Error(codes.InvalidArgument, "bad"), false},
+		{"connection reset", fmt.Errorf("read: %w", syscall.ECONNRESET), true},
+		{"connection refused", fmt.Errorf("dial: %w", syscall.ECONNREFUSED), true},
+		{"refused behind url error", &url.Error{Op: "Post", URL: "http://localhost:1", Err: &net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNREFUSED}}, true},
+		{"bad scheme", &url.Error{Op: "Post", URL: "htp://example.com", Err: errors.New(`unsupported protocol scheme "htp"`)}, false},
+		{"unknown host", &url.Error{Op: "Post", URL: "http://no.such.host", Err: &net.DNSError{Err: "no such host", Name: "no.such.host", IsNotFound: true}}, false},
+		{"dns timeout", &net.DNSError{Err: "i/o timeout", Name: "example.com", IsTimeout: true}, true},
+		{"attempt timeout", errors.Join(ErrAttemptTimeout, context.DeadlineExceeded), true},
+		{"canceled", context.Canceled, false},
+		{"other", errors.New("bad request"), false},
+		{"nil", nil, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if transient := IsTransient(tt.err); transient != tt.transient {
+				t.Errorf("IsTransient(%v) = %v, want %v", tt.err, transient, tt.transient)
+			}
+		})
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	delay, ok := ParseRetryAfter("120")
+	if !ok || delay != 2*time.Minute {
+		t.Errorf("ParseRetryAfter(120) = %v, %v, want %v, true", delay, ok, 2*time.Minute)
+	}
+
+	delay, ok = ParseRetryAfter(time.Now().Add(time.Hour).UTC().Format(http.TimeFormat))
+	if !ok || delay < time.Hour-2*time.Second || delay > time.Hour {
+		t.Errorf("ParseRetryAfter(date) = %v, %v, want about an hour", delay, ok)
+	}
+
+	for _, value := range []string{"", "soon"} {
+		if _, ok := ParseRetryAfter(value); ok {
+			t.Errorf("ParseRetryAfter(%q) should not parse", value)
+		}
+	}
+}
+
+func TestRetryInfoDelay(t *testing.T) {
+	details := []map[string]any{
+		{"@type": "type.googleapis.com/google.rpc.QuotaFailure"},
+		{"@type": "type.googleapis.com/google.rpc.RetryInfo", "retryDelay": "1.5s"},
+	}
+
+	delay, ok := RetryInfoDelay(details)
+	if !ok || delay != 1500*time.Millisecond {
+		t.Errorf("RetryInfoDelay() = %v, %v, want %v, true", delay, ok, 1500*time.Millisecond)
+	}
+
+	if _, ok := RetryInfoDelay(details[:1]); ok {
+		t.Error("RetryInfoDelay() without RetryInfo should not find a delay")
+	}
+}
@@ -0,0 +1,41 @@
+package retry
+
+import (
+	"context"
+	"time"
+)
+
+// Hooks observe retries, for example to count them. Either function may be
+// nil.
+type Hooks struct {
+	// OnAttempt is called after every attempt with its error, nil on
+	// success, and how long it took.
+	OnAttempt func(attempt int, err error, duration time.Duration)
+	// OnRetry is called before waiting to retry a failed attempt.
+	OnRetry func(attempt int, err error, backoff time.Duration)
+}
+
+type hooksKey struct{}
+
+// WithHooks returns a context whose retries report to hooks.
+func WithHooks(ctx context.Context, hooks *Hooks) context.Context {
+	return context.WithValue(ctx, hooksKey{}, hooks)
+}
+
+// HooksFromContext returns the hooks set on the context, or nil.
+func HooksFromContext(ctx context.Context) *Hooks {
+	hooks, _ := ctx.Value(hooksKey{}).(*Hooks)
+	return hooks
+}
+
+func (h *Hooks) attempt(attempt int, err error, duration time.Duration) {
+	if h != nil && h.OnAttempt != nil {
+		h.OnAttempt(attempt, err, duration)
+	}
+}
+
+func (h *Hooks) retry(attempt int, err error, backoff time.Duration) {
+	if h != nil && h.OnRetry != nil {
+		h.OnRetry(attempt, err, backoff)
+	}
+}
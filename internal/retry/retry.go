@@ -2,60 +2,174 @@ package retry
 
 import (
 	"context"
+	"errors"
 	"log/slog"
 	"math"
+	"math/rand/v2"
 	"time"
 )
 
+// Jitter randomises backoffs so clients that failed together do not retry
+// together.
+type Jitter int
+
+const (
+	// NoJitter waits exactly the exponential backoff.
+	NoJitter Jitter = iota
+	// FullJitter waits a random time between zero and the backoff.
+	FullJitter
+	// EqualJitter waits half the backoff plus a random time up to the other
+	// half.
+	EqualJitter
+	// DecorrelatedJitter waits a random time between the initial backoff and
+	// three times the previous wait.
+	DecorrelatedJitter
+)
+
 type Retryer struct {
-	MaxRetries       int
-	InitialBackoff   time.Duration
-	MaxBackoff       time.Duration
+	MaxRetries     int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	Jitter         Jitter
+
+	// AttemptTimeout bounds each attempt and MaxElapsed bounds all attempts
+	// and backoffs together. Zero means no limit.
+	AttemptTimeout time.Duration
+	MaxElapsed     time.Duration
+
+	// IsRetryableError defaults to IsTransient.
 	IsRetryableError func(error) bool
-	Attempt          func(context.Context) error
+
+	// RetryAfter returns the wait the server asked for, if any, which is
+	// used instead of the backoff. A server asking for longer than
+	// MaxBackoff ends the retries. It defaults to RetryAfterHint.
+	RetryAfter func(error) (time.Duration, bool)
+
+	// Hooks default to the hooks set on the context.
+	Hooks *Hooks
+
+	Attempt func(context.Context) error
 }
 
 func (r Retryer) Try(ctx context.Context) error {
-	attempt := 0
+	start := time.Now()
+	hooks := r.hooks(ctx)
+	previous := r.InitialBackoff
+
+	if r.MaxElapsed > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, r.MaxElapsed)
+		defer cancel()
+	}
+
+	for attempt := 0; ; attempt++ {
+		attemptStart := time.Now()
+		err := r.try(ctx)
+		hooks.attempt(attempt, err, time.Since(attemptStart))
 
-	for {
-		err := r.Attempt(ctx)
 		if err == nil {
 			return nil
 		}
 
-		if !r.IsRetryableError(err) {
+		if ctx.Err() != nil || !r.isRetryable(err) || attempt >= r.MaxRetries {
 			return err
 		}
 
-		if attempt < r.MaxRetries {
-			backoff := r.calculateBackoff(attempt)
-
-			slog.Warn("retry_attempt_failed",
-				slog.Int("attempt", attempt),
-				slog.Int("max_retries", r.MaxRetries),
-				slog.String("error", err.Error()),
-				slog.Duration("backoff", backoff),
-			)
+		backoff := r.calculateBackoff(attempt, previous)
+		previous = backoff
 
-			select {
-			case <-ctx.Done():
-				return ctx.Err()
-			case <-time.After(backoff):
+		if hint, ok := r.retryAfter(err); ok {
+			// Waiting out a long hint would stall the caller, so it is
+			// better to fail and let it decide
+			if hint > r.MaxBackoff {
+				return err
 			}
-		} else {
+
+			backoff = hint
+		}
+
+		// Give up now rather than wait past the deadline
+		if deadline, ok := ctx.Deadline(); ok && time.Now().Add(backoff).After(deadline) {
 			return err
 		}
 
-		attempt++
+		slog.Warn("retry_attempt_failed",
+			slog.Int("attempt", attempt),
+			slog.Int("max_retries", r.MaxRetries),
+			slog.String("error", err.Error()),
+			slog.Duration("backoff", backoff),
+			slog.Duration("elapsed", time.Since(start)),
+		)
+
+		hooks.retry(attempt, err, backoff)
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
 	}
 }
 
-func (r Retryer) calculateBackoff(attempt int) time.Duration {
+// try runs one attempt. An attempt that runs out of its own time is reported
+// as ErrAttemptTimeout, which is retryable, unlike the caller's context
+// expiring.
+func (r Retryer) try(ctx context.Context) error {
+	if r.AttemptTimeout <= 0 {
+		return r.Attempt(ctx)
+	}
+
+	attemptCtx, cancel := context.WithTimeout(ctx, r.AttemptTimeout)
+	defer cancel()
+
+	err := r.Attempt(attemptCtx)
+	if err != nil && ctx.Err() == nil && errors.Is(attemptCtx.Err(), context.DeadlineExceeded) {
+		return errors.Join(ErrAttemptTimeout, err)
+	}
+
+	return err
+}
+
+func (r Retryer) isRetryable(err error) bool {
+	if r.IsRetryableError != nil {
+		return r.IsRetryableError(err)
+	}
+
+	return IsTransient(err)
+}
+
+func (r Retryer) retryAfter(err error) (time.Duration, bool) {
+	if r.RetryAfter != nil {
+		return r.RetryAfter(err)
+	}
+
+	return RetryAfterHint(err)
+}
+
+func (r Retryer) hooks(ctx context.Context) *Hooks {
+	if r.Hooks != nil {
+		return r.Hooks
+	}
+
+	return HooksFromContext(ctx)
+}
+
+func (r Retryer) calculateBackoff(attempt int, previous time.Duration) time.Duration {
 	backoff := float64(r.InitialBackoff) * math.Pow(2, float64(attempt))
 	if backoff > float64(r.MaxBackoff) {
 		backoff = float64(r.MaxBackoff)
 	}
 
+	switch r.Jitter {
+	case FullJitter:
+		backoff = rand.Float64() * backoff
+	case EqualJitter:
+		backoff = backoff/2 + rand.Float64()*backoff/2
+	case DecorrelatedJitter:
+		low := float64(r.InitialBackoff)
+		high := math.Max(low, 3*float64(previous))
+		backoff = math.Min(float64(r.MaxBackoff), low+rand.Float64()*(high-low))
+	}
+
 	return time.Duration(backoff)
 }
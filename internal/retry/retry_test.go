@@ -0,0 +1,198 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestRetryer_Jitter(t *testing.T) {
+	for _, jitter := range []Jitter{NoJitter, FullJitter, EqualJitter, DecorrelatedJitter} {
+		var backoffs []time.Duration
+
+		retryer := Retryer{
+			MaxRetries:     4,
+			InitialBackoff: time.Millisecond,
+			MaxBackoff:     4 * time.Millisecond,
+			Jitter:         jitter,
+			Hooks: &Hooks{
+				OnRetry: func(attempt int, err error, backoff time.Duration) {
+					backoffs = append(backoffs, backoff)
+				},
+			},
+			Attempt: func(ctx context.Context) error {
+				return statusError{code: http.StatusServiceUnavailable}
+			},
+		}
+
+		if err := retryer.Try(context.Background()); err == nil {
+			t.Fatalf("jitter %d: Try() should fail", jitter)
+		}
+
+		if len(backoffs) != 4 {
+			t.Fatalf("jitter %d: got %d retries, want 4", jitter, len(backoffs))
+		}
+
+		for i, backoff := range backoffs {
+			if backoff < 0 || backoff > 4*time.Millisecond {
+				t.Errorf("jitter %d attempt %d: backoff %v outside [0, 4ms]", jitter, i, backoff)
+			}
+		}
+
+		if jitter == NoJitter {
+			expected := []time.Duration{time.Millisecond, 2 * time.Millisecond, 4 * time.Millisecond, 4 * time.Millisecond}
+			if !reflect.DeepEqual(backoffs, expected) {
+				t.Errorf("backoffs = %v, want %v", backoffs, expected)
+			}
+		}
+	}
+}
+
+func TestRetryer_HonoursRetryAfter(t *testing.T) {
+	var backoffs []time.Duration
+	attempts := 0
+
+	retryer := Retryer{
+		MaxRetries:     3,
+		InitialBackoff: time.Hour,
+		MaxBackoff:     time.Hour,
+		Hooks: &Hooks{
+			OnRetry: func(attempt int, err error, backoff time.Duration) {
+				backoffs = append(backoffs, backoff)
+			},
+		},
+		Attempt: func(ctx context.Context) error {
+			attempts++
+			if attempts < 3 {
+				return statusError{code: http.StatusTooManyRequests, retryAfter: time.Millisecond}
+			}
+			return nil
+		},
+	}
+
+	if err := retryer.Try(context.Background()); err != nil {
+		t.Fatalf("Try() error = %v", err)
+	}
+
+	// The server hint should replace the backoff
+	expected := []time.Duration{time.Millisecond, time.Millisecond}
+	if !reflect.DeepEqual(backoffs, expected) {
+		t.Errorf("backoffs = %v, want %v", backoffs, expected)
+	}
+}
+
+func TestRetryer_GivesUpOnLongRetryAfter(t *testing.T) {
+	attempts := 0
+
+	retryer := Retryer{
+		MaxRetries:     3,
+		InitialBackoff: time.Millisecond,
+		MaxBackoff:     time.Second,
+		Attempt: func(ctx context.Context) error {
+			attempts++
+			return statusError{code: http.StatusTooManyRequests, retryAfter: time.Hour}
+		},
+	}
+
+	start := time.Now()
+
+	var statusErr statusError
+	if err := retryer.Try(context.Background()); !errors.As(err, &statusErr) {
+		t.Fatalf("the server's error should be returned, got %v", err)
+	}
+
+	if elapsed := time.Since(start); elapsed >= time.Second {
+		t.Errorf("Try() took %v, a hint over MaxBackoff should not be waited out", elapsed)
+	}
+
+	if attempts != 1 {
+		t.Errorf("got %d attempts, want 1", attempts)
+	}
+}
+
+func TestRetryer_AttemptTimeout(t *testing.T) {
+	attempts := 0
+
+	retryer := Retryer{
+		MaxRetries:     2,
+		InitialBackoff: time.Millisecond,
+		MaxBackoff:     time.Millisecond,
+		AttemptTimeout: 10 * time.Millisecond,
+		Attempt: func(ctx context.Context) error {
+			attempts++
+			if attempts < 2 {
+				<-ctx.Done()
+				return ctx.Err()
+			}
+			return nil
+		},
+	}
+
+	if err := retryer.Try(context.Background()); err != nil {
+		t.Fatalf("a timed out attempt should be retried, got %v", err)
+	}
+
+	if attempts != 2 {
+		t.Errorf("got %d attempts, want 2", attempts)
+	}
+}
+
+func TestRetryer_MaxElapsed(t *testing.T) {
+	attempts := 0
+
+	retryer := Retryer{
+		MaxRetries:     10,
+		InitialBackoff: 20 * time.Millisecond,
+		MaxBackoff:     20 * time.Millisecond,
+		MaxElapsed:     50 * time.Millisecond,
+		Attempt: func(ctx context.Context) error {
+			attempts++
+			return statusError{code: http.StatusServiceUnavailable}
+		},
+	}
+
+	start := time.Now()
+	err := retryer.Try(context.Background())
+
+	var statusErr statusError
+	if !errors.As(err, &statusErr) {
+		t.Fatalf("the last error should be returned, got %v", err)
+	}
+
+	if elapsed := time.Since(start); elapsed >= 100*time.Millisecond {
+		t.Errorf("Try() took %v, want under 100ms", elapsed)
+	}
+
+	if attempts >= 5 {
+		t.Errorf("got %d attempts, retries should stop at the deadline", attempts)
+	}
+}
+
+func TestRetryer_DoesNotRetryPermanentErrors(t *testing.T) {
+	var outcomes []error
+
+	retryer := Retryer{
+		MaxRetries:     3,
+		InitialBackoff: time.Millisecond,
+		MaxBackoff:     time.Millisecond,
+		Hooks: &Hooks{
+			OnAttempt: func(attempt int, err error, duration time.Duration) {
+				outcomes = append(outcomes, err)
+			},
+		},
+		Attempt: func(ctx context.Context) error {
+			return statusError{code: http.StatusBadRequest}
+		},
+	}
+
+	if err := retryer.Try(context.Background()); err == nil {
+		t.Fatal("Try() should fail")
+	}
+
+	if len(outcomes) != 1 {
+		t.Errorf("got %d attempts, want 1", len(outcomes))
+	}
+}
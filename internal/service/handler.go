@@ -2,9 +2,15 @@ package service
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"log/slog"
+	"mime"
+	"mime/multipart"
 	"net/http"
 	"os"
+	"path/filepath"
 	"strings"
 
 	"github.com/google/uuid"
@@ -13,6 +19,10 @@ import (
 	"github.com/schraf/assistant/pkg/models"
 )
 
+// maxRequestBytes caps a multipart request, matching the largest request
+// Cloud Run accepts over HTTP/1.
+const maxRequestBytes = 32 << 20
+
 // Handler handles HTTP requests for the assistant service.
 type Handler struct {
 	scheduler internal_models.JobScheduler
@@ -115,8 +125,23 @@ func (h *Handler) HandleRequest(w http.ResponseWriter, r *http.Request) {
 	//--==================================================================--
 
 	var body map[string]any
+	var attachments []models.Attachment
+
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+
+	if mediaType == "multipart/form-data" {
+		var err error
 
-	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		body, attachments, err = readMultipart(http.MaxBytesReader(w, r.Body, maxRequestBytes), r.Header.Get("Content-Type"))
+		if err != nil {
+			logger.WarnContext(ctx, "invalid_multipart_payload",
+				slog.String("error", err.Error()),
+			)
+
+			http.Error(w, "invalid multipart payload", http.StatusBadRequest)
+			return
+		}
+	} else if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
 		logger.WarnContext(ctx, "invalid_json_payload",
 			slog.String("error", err.Error()),
 		)
@@ -129,13 +154,22 @@ func (h *Handler) HandleRequest(w http.ResponseWriter, r *http.Request) {
 		slog.Any("body", body),
 	)
 
+	for _, attachment := range attachments {
+		logger.InfoContext(ctx, "request_attachment",
+			slog.String("name", attachment.Name),
+			slog.String("mime_type", attachment.MIMEType),
+			slog.Int("size", len(attachment.Data)),
+		)
+	}
+
 	//--==================================================================--
 	//--== START THE CLOUD RUN JOB
 	//--==================================================================--
 
 	req := models.ContentRequest{
-		Id:   requestId,
-		Body: body,
+		Id:          requestId,
+		Body:        body,
+		Attachments: attachments,
 	}
 
 	if err := h.scheduler.ScheduleJob(ctx, contentType, config, req); err != nil {
@@ -166,3 +200,84 @@ func (h *Handler) HandleRequest(w http.ResponseWriter, r *http.Request) {
 		)
 	}
 }
+
+// readMultipart decodes a multipart/form-data request. The "body" field
+// holds the JSON body, and every file is handed to the generator as an
+// attachment, typed by its Content-Type or, failing that, its name and
+// content.
+func readMultipart(r io.Reader, contentType string) (map[string]any, []models.Attachment, error) {
+	_, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var body map[string]any
+	var attachments []models.Attachment
+
+	reader := multipart.NewReader(r, params["boundary"])
+
+	for {
+		part, err := reader.NextPart()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if part.FileName() == "" {
+			if part.FormName() != "body" {
+				return nil, nil, fmt.Errorf("unexpected field %q", part.FormName())
+			}
+
+			if err := json.NewDecoder(part).Decode(&body); err != nil {
+				return nil, nil, fmt.Errorf("invalid JSON in the body field: %w", err)
+			}
+
+			continue
+		}
+
+		data, err := io.ReadAll(part)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		attachment := models.Attachment{
+			Name:     part.FileName(),
+			MIMEType: attachmentType(part.FileName(), part.Header.Get("Content-Type"), data),
+			Data:     data,
+		}
+
+		if err := attachment.Validate(); err != nil {
+			return nil, nil, err
+		}
+
+		attachments = append(attachments, attachment)
+	}
+
+	if body == nil {
+		return nil, nil, fmt.Errorf("missing body field")
+	}
+
+	return body, attachments, nil
+}
+
+// attachmentType returns the MIME type of a file without its parameters.
+// Clients that do not know the type send application/octet-stream, so it is
+// guessed from the file's extension and then from its content.
+func attachmentType(name string, contentType string, data []byte) string {
+	if contentType == "" || contentType == "application/octet-stream" {
+		contentType = mime.TypeByExtension(filepath.Ext(name))
+	}
+
+	if contentType == "" {
+		contentType = http.DetectContentType(data)
+	}
+
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return contentType
+	}
+
+	return mediaType
+}
@@ -9,6 +9,7 @@ import (
 
 	run "cloud.google.com/go/run/apiv2"
 	"cloud.google.com/go/run/apiv2/runpb"
+	"github.com/schraf/assistant/internal/staging"
 	"github.com/schraf/assistant/pkg/models"
 	"google.golang.org/api/option"
 )
@@ -53,6 +54,26 @@ func (s *CloudRunJobScheduler) ScheduleJob(ctx context.Context, contentType stri
 
 	encodedRequestBody := base64.StdEncoding.EncodeToString(requestBodyJson)
 
+	//--==================================================================--
+	//--== STAGE THE ATTACHMENTS
+	//--==================================================================--
+
+	// Job overrides are too small for files, so the job gets a reference to
+	// them instead
+	var attachmentsURI string
+
+	if len(request.Attachments) > 0 {
+		bucket := os.Getenv("ATTACHMENTS_BUCKET")
+		if bucket == "" {
+			return fmt.Errorf("ATTACHMENTS_BUCKET environment variable is not set")
+		}
+
+		attachmentsURI, err = staging.Upload(ctx, bucket, request.Id.String()+".json", request.Attachments)
+		if err != nil {
+			return fmt.Errorf("failed to stage attachments: %w", err)
+		}
+	}
+
 	//--==================================================================--
 	//--== ENCODE THE CONFIG
 	//--==================================================================--
@@ -108,6 +129,12 @@ func (s *CloudRunJobScheduler) ScheduleJob(ctx context.Context, contentType stri
 								Value: encodedRequestBody,
 							},
 						},
+						{
+							Name: "REQUEST_ATTACHMENTS_URI",
+							Values: &runpb.EnvVar_Value{
+								Value: attachmentsURI,
+							},
+						},
 					},
 				},
 			},
@@ -0,0 +1,103 @@
+// Package staging keeps the attachments of a request out of the job's
+// environment. The service writes them to a Cloud Storage bucket and hands
+// the job a URI, which the job loads them back from.
+package staging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/schraf/assistant/pkg/models"
+	"google.golang.org/api/storage/v1"
+)
+
+// Upload writes the attachments to the named object in the bucket and
+// returns its gs:// URI.
+func Upload(ctx context.Context, bucket string, object string, attachments []models.Attachment) (string, error) {
+	data, err := json.Marshal(attachments)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal attachments: %w", err)
+	}
+
+	service, err := storage.NewService(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to create Cloud Storage client: %w", err)
+	}
+
+	_, err = service.Objects.Insert(bucket, &storage.Object{
+		Name:        object,
+		ContentType: "application/json",
+	}).Media(bytes.NewReader(data)).Context(ctx).Do()
+	if err != nil {
+		return "", fmt.Errorf("failed to upload attachments to gs://%s/%s: %w", bucket, object, err)
+	}
+
+	return "gs://" + bucket + "/" + object, nil
+}
+
+// WriteFile writes the attachments to path and returns its file:// URI, for
+// running the job locally.
+func WriteFile(path string, attachments []models.Attachment) (string, error) {
+	data, err := json.Marshal(attachments)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal attachments: %w", err)
+	}
+
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		return "", fmt.Errorf("failed to write attachments: %w", err)
+	}
+
+	return (&url.URL{Scheme: "file", Path: path}).String(), nil
+}
+
+// Load reads the attachments staged at a gs:// or file:// URI.
+func Load(ctx context.Context, uri string) ([]models.Attachment, error) {
+	location, err := url.Parse(uri)
+	if err != nil {
+		return nil, fmt.Errorf("invalid attachments URI %q: %w", uri, err)
+	}
+
+	var data []byte
+
+	switch location.Scheme {
+	case "gs":
+		data, err = download(ctx, location.Host, strings.TrimPrefix(location.Path, "/"))
+	case "file":
+		data, err = os.ReadFile(location.Path)
+	default:
+		return nil, fmt.Errorf("unsupported attachments URI %q", uri)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to read attachments from %s: %w", uri, err)
+	}
+
+	var attachments []models.Attachment
+
+	if err := json.Unmarshal(data, &attachments); err != nil {
+		return nil, fmt.Errorf("failed to parse attachments from %s: %w", uri, err)
+	}
+
+	return attachments, nil
+}
+
+func download(ctx context.Context, bucket string, object string) ([]byte, error) {
+	service, err := storage.NewService(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Cloud Storage client: %w", err)
+	}
+
+	response, err := service.Objects.Get(bucket, object).Context(ctx).Download()
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	return io.ReadAll(response.Body)
+}
@@ -0,0 +1,47 @@
+package staging
+
+import (
+	"context"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/schraf/assistant/pkg/models"
+)
+
+func TestWriteFileAndLoad(t *testing.T) {
+	attachments := []models.Attachment{
+		{Name: "paper.pdf", MIMEType: "application/pdf", Data: []byte("%PDF-1.4 test")},
+	}
+
+	uri, err := WriteFile(filepath.Join(t.TempDir(), "attachments.json"), attachments)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.HasPrefix(uri, "file://") {
+		t.Errorf("WriteFile() = %q, want a file:// URI", uri)
+	}
+
+	loaded, err := Load(context.Background(), uri)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(loaded, attachments) {
+		t.Errorf("Load() = %v, want %v", loaded, attachments)
+	}
+}
+
+func TestLoad_Errors(t *testing.T) {
+	for _, uri := range []string{
+		"https://example.com/attachments.json",
+		"file://" + filepath.Join(t.TempDir(), "missing.json"),
+		"://",
+	} {
+		if _, err := Load(context.Background(), uri); err == nil {
+			t.Errorf("Load(%q) should fail", uri)
+		}
+	}
+}
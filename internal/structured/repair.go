@@ -0,0 +1,91 @@
+package structured
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/schraf/assistant/pkg/models"
+)
+
+// GenerateFunc sends a request to the model and returns its raw reply.
+type GenerateFunc func(ctx context.Context, request string) (string, error)
+
+// Ask generates a response and validates it against schema. While it does
+// not parse or match, the request is sent again along with the previous
+// response and what was wrong with it, up to models.SchemaRepairsFromContext
+// times. Errors from generate are returned as they are.
+func Ask(ctx context.Context, request string, schema map[string]any, generate GenerateFunc) (json.RawMessage, error) {
+	repairs := models.SchemaRepairsFromContext(ctx)
+	prompt := request
+
+	for attempt := 0; ; attempt++ {
+		responseText, err := generate(ctx, prompt)
+		if err != nil {
+			return nil, err
+		}
+
+		response, errs := parse(responseText, schema)
+		if len(errs) == 0 {
+			return response, nil
+		}
+
+		if attempt >= repairs {
+			return nil, &models.SchemaValidationError{
+				Errors:   errs,
+				Response: json.RawMessage(responseText),
+				Attempts: attempt + 1,
+			}
+		}
+
+		slog.WarnContext(ctx, "schema_validation_failed",
+			slog.Int("attempt", attempt),
+			slog.Int("max_repairs", repairs),
+			slog.Any("errors", errs),
+		)
+
+		prompt = repairPrompt(request, responseText, errs)
+	}
+}
+
+// parse decodes the response, tolerating markdown code fences, and validates
+// it against the schema.
+func parse(responseText string, schema map[string]any) (json.RawMessage, []string) {
+	responseText = strings.TrimSpace(responseText)
+	responseText = strings.TrimPrefix(responseText, "```json")
+	responseText = strings.TrimPrefix(responseText, "```")
+	responseText = strings.TrimSuffix(responseText, "```")
+	responseText = strings.TrimSpace(responseText)
+
+	var value any
+	if err := json.Unmarshal([]byte(responseText), &value); err != nil {
+		return nil, []string{fmt.Sprintf("invalid JSON: %s", err)}
+	}
+
+	if errs := Validate(schema, value); len(errs) > 0 {
+		return nil, errs
+	}
+
+	return json.RawMessage(responseText), nil
+}
+
+func repairPrompt(request string, responseText string, errs []string) string {
+	var prompt strings.Builder
+
+	prompt.WriteString(request)
+	prompt.WriteString("\n\nYour previous response was:\n")
+	prompt.WriteString(responseText)
+	prompt.WriteString("\n\nIt did not match the required JSON schema:\n")
+
+	for _, err := range errs {
+		prompt.WriteString("- ")
+		prompt.WriteString(err)
+		prompt.WriteString("\n")
+	}
+
+	prompt.WriteString("\nRespond again with only corrected JSON that matches the schema.")
+
+	return prompt.String()
+}
@@ -0,0 +1,26 @@
+package structured
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/schraf/assistant/pkg/models"
+)
+
+func TestAsk_GenerateErrorNotRepaired(t *testing.T) {
+	calls := 0
+
+	_, err := Ask(context.Background(), "request", articleSchema, func(ctx context.Context, request string) (string, error) {
+		calls++
+		return "", models.ErrContentBlocked
+	})
+
+	if !errors.Is(err, models.ErrContentBlocked) {
+		t.Errorf("Ask() error = %v, want %v", err, models.ErrContentBlocked)
+	}
+
+	if calls != 1 {
+		t.Errorf("generate called %d times, want 1", calls)
+	}
+}
@@ -0,0 +1,190 @@
+package structured
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"slices"
+	"strings"
+)
+
+// Validate checks value, as decoded by encoding/json, against the subset of
+// JSON schema used for structured output: type, properties, required, items,
+// enum, minItems and maxItems. It returns one message per violation.
+func Validate(schema map[string]any, value any) []string {
+	var errs []string
+	validate(schema, value, "$", &errs)
+	return errs
+}
+
+func validate(schema map[string]any, value any, path string, errs *[]string) {
+	if schema == nil {
+		return
+	}
+
+	if types := schemaTypes(schema["type"]); len(types) > 0 {
+		if !slices.ContainsFunc(types, func(t string) bool { return hasType(value, t) }) {
+			*errs = append(*errs, fmt.Sprintf("%s: expected %s, got %s", path, strings.Join(types, " or "), typeName(value)))
+			return
+		}
+	}
+
+	if enum := list(schema["enum"]); enum != nil {
+		if !slices.ContainsFunc(enum, func(allowed any) bool { return equal(allowed, value) }) {
+			*errs = append(*errs, fmt.Sprintf("%s: %v is not one of %v", path, value, enum))
+		}
+	}
+
+	switch v := value.(type) {
+	case map[string]any:
+		for _, name := range stringList(schema["required"]) {
+			if _, ok := v[name]; !ok {
+				*errs = append(*errs, fmt.Sprintf("%s: missing required property %q", path, name))
+			}
+		}
+
+		if properties, ok := schema["properties"].(map[string]any); ok {
+			names := make([]string, 0, len(properties))
+			for name := range properties {
+				names = append(names, name)
+			}
+			slices.Sort(names)
+
+			for _, name := range names {
+				property, ok := v[name]
+				if !ok {
+					continue
+				}
+
+				if propertySchema, ok := properties[name].(map[string]any); ok {
+					validate(propertySchema, property, path+"."+name, errs)
+				}
+			}
+		}
+
+	case []any:
+		if minItems, ok := number(schema["minItems"]); ok && float64(len(v)) < minItems {
+			*errs = append(*errs, fmt.Sprintf("%s: expected at least %v items, got %d", path, minItems, len(v)))
+		}
+
+		if maxItems, ok := number(schema["maxItems"]); ok && float64(len(v)) > maxItems {
+			*errs = append(*errs, fmt.Sprintf("%s: expected at most %v items, got %d", path, maxItems, len(v)))
+		}
+
+		if items, ok := schema["items"].(map[string]any); ok {
+			for i, item := range v {
+				validate(items, item, fmt.Sprintf("%s[%d]", path, i), errs)
+			}
+		}
+	}
+}
+
+// schemaTypes accepts "type" as a string or a list of strings.
+func schemaTypes(value any) []string {
+	if t, ok := value.(string); ok {
+		return []string{t}
+	}
+
+	return stringList(value)
+}
+
+func stringList(value any) []string {
+	var values []string
+
+	for _, v := range list(value) {
+		if s, ok := v.(string); ok {
+			values = append(values, s)
+		}
+	}
+
+	return values
+}
+
+func hasType(value any, t string) bool {
+	switch t {
+	case "object":
+		_, ok := value.(map[string]any)
+		return ok
+	case "array":
+		_, ok := value.([]any)
+		return ok
+	case "string":
+		_, ok := value.(string)
+		return ok
+	case "number":
+		_, ok := value.(float64)
+		return ok
+	case "integer":
+		n, ok := value.(float64)
+		return ok && n == math.Trunc(n)
+	case "boolean":
+		_, ok := value.(bool)
+		return ok
+	case "null":
+		return value == nil
+	}
+
+	// Unknown types are not enforced
+	return true
+}
+
+func typeName(value any) string {
+	switch value.(type) {
+	case map[string]any:
+		return "object"
+	case []any:
+		return "array"
+	case string:
+		return "string"
+	case float64:
+		return "number"
+	case bool:
+		return "boolean"
+	case nil:
+		return "null"
+	}
+
+	return fmt.Sprintf("%T", value)
+}
+
+// list reads a schema keyword holding a list, which may be []any when the
+// schema was decoded from JSON or a typed slice when it was built in Go.
+func list(value any) []any {
+	v := reflect.ValueOf(value)
+	if v.Kind() != reflect.Slice {
+		return nil
+	}
+
+	values := make([]any, v.Len())
+	for i := range values {
+		values[i] = v.Index(i).Interface()
+	}
+
+	return values
+}
+
+// number reads a schema keyword that may be an int when the schema was built
+// in Go or a float64 when it was decoded from JSON.
+func number(value any) (float64, bool) {
+	switch v := value.(type) {
+	case int:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case float64:
+		return v, true
+	}
+
+	return 0, false
+}
+
+// equal compares an enum value from the schema with a decoded value, where
+// numbers in a schema built in Go may be ints.
+func equal(allowed any, value any) bool {
+	if a, ok := number(allowed); ok {
+		b, ok := value.(float64)
+		return ok && a == b
+	}
+
+	return reflect.DeepEqual(allowed, value)
+}
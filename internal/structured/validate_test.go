@@ -0,0 +1,122 @@
+package structured
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+var articleSchema = map[string]any{
+	"type": "object",
+	"properties": map[string]any{
+		"title": map[string]any{"type": "string"},
+		"tone":  map[string]any{"type": "string", "enum": []string{"formal", "casual"}},
+		"sections": map[string]any{
+			"type":     "array",
+			"minItems": 1,
+			"maxItems": 3,
+			"items": map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"heading": map[string]any{"type": "string"},
+					"words":   map[string]any{"type": "integer"},
+				},
+				"required": []string{"heading"},
+			},
+		},
+	},
+	"required": []string{"title", "sections"},
+}
+
+func decode(t *testing.T, data string) any {
+	t.Helper()
+
+	var value any
+	if err := json.Unmarshal([]byte(data), &value); err != nil {
+		t.Fatalf("failed to decode %s: %v", data, err)
+	}
+
+	return value
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name     string
+		data     string
+		expected []string
+	}{
+		{
+			name:     "valid",
+			data:     `{"title":"T","tone":"formal","sections":[{"heading":"H","words":100}]}`,
+			expected: nil,
+		},
+		{
+			name:     "missing required",
+			data:     `{"sections":[{"heading":"H"}]}`,
+			expected: []string{`$: missing required property "title"`},
+		},
+		{
+			name:     "wrong type",
+			data:     `{"title":1,"sections":[{"heading":"H","words":1.5}]}`,
+			expected: []string{"$.sections[0].words: expected integer, got number", "$.title: expected string, got number"},
+		},
+		{
+			name:     "enum",
+			data:     `{"title":"T","tone":"angry","sections":[{"heading":"H"}]}`,
+			expected: []string{"$.tone: angry is not one of [formal casual]"},
+		},
+		{
+			name:     "min items",
+			data:     `{"title":"T","sections":[]}`,
+			expected: []string{"$.sections: expected at least 1 items, got 0"},
+		},
+		{
+			name:     "max items",
+			data:     `{"title":"T","sections":[{"heading":"1"},{"heading":"2"},{"heading":"3"},{"heading":"4"}]}`,
+			expected: []string{"$.sections: expected at most 3 items, got 4"},
+		},
+		{
+			name:     "nested required",
+			data:     `{"title":"T","sections":[{}]}`,
+			expected: []string{`$.sections[0]: missing required property "heading"`},
+		},
+		{
+			name:     "not an object",
+			data:     `["T"]`,
+			expected: []string{"$: expected object, got array"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := Validate(articleSchema, decode(t, tt.data))
+			if !reflect.DeepEqual(result, tt.expected) {
+				t.Errorf("Validate() = %q, want %q", result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestValidate_DecodedSchema(t *testing.T) {
+	// Schemas loaded from JSON use []any and float64 rather than Go types
+	schema := decode(t, `{"type":"array","maxItems":1,"items":{"type":["string","null"],"enum":["a",null]}}`).(map[string]any)
+
+	tests := []struct {
+		data     string
+		expected []string
+	}{
+		{data: `["a"]`},
+		{data: `[null]`},
+		{
+			data:     `["a","b"]`,
+			expected: []string{"$: expected at most 1 items, got 2", "$[1]: b is not one of [a <nil>]"},
+		},
+	}
+
+	for _, tt := range tests {
+		result := Validate(schema, decode(t, tt.data))
+		if !reflect.DeepEqual(result, tt.expected) {
+			t.Errorf("Validate(%s) = %q, want %q", tt.data, result, tt.expected)
+		}
+	}
+}
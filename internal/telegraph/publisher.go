@@ -17,8 +17,13 @@ type Publisher struct {
 
 // NewPublisher creates a new Publisher.
 func NewPublisher() internal_models.Publisher {
+	return NewPublisherWithClient(NewDefaultClient())
+}
+
+// NewPublisherWithClient creates a new Publisher that uses the given client.
+func NewPublisherWithClient(client Client) internal_models.Publisher {
 	return &Publisher{
-		client: NewDefaultClient(),
+		client: client,
 	}
 }
 
@@ -49,6 +54,8 @@ func (p *Publisher) PublishDocument(ctx context.Context, doc *models.Document) (
 		}
 	}
 
+	content = append(content, referenceNodes(doc.References)...)
+
 	returnContent := false
 
 	// Use author from doc, or fall back to environment variable if blank
@@ -82,3 +89,42 @@ func (p *Publisher) PublishDocument(ctx context.Context, doc *models.Document) (
 
 	return pageURL, nil
 }
+
+// referenceNodes renders the references as a numbered list of links under a
+// "Sources" heading.
+func referenceNodes(references []models.Reference) Nodes {
+	if len(references) == 0 {
+		return nil
+	}
+
+	items := Nodes{}
+
+	for _, reference := range references {
+		title := reference.Title
+		if title == "" {
+			title = reference.URL
+		}
+
+		items = append(items, NodeElement{
+			Tag: "li",
+			Children: Nodes{
+				NodeElement{
+					Tag:      "a",
+					Attrs:    map[string]string{"href": reference.URL},
+					Children: Nodes{title},
+				},
+			},
+		})
+	}
+
+	return Nodes{
+		NodeElement{
+			Tag:      "h3",
+			Children: Nodes{"Sources"},
+		},
+		NodeElement{
+			Tag:      "ol",
+			Children: items,
+		},
+	}
+}
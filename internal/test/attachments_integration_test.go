@@ -0,0 +1,410 @@
+package test
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/schraf/assistant/internal/job"
+	"github.com/schraf/assistant/internal/log"
+	"github.com/schraf/assistant/internal/mocks"
+	"github.com/schraf/assistant/internal/ollama"
+	"github.com/schraf/assistant/internal/openai"
+	"github.com/schraf/assistant/internal/service"
+	"github.com/schraf/assistant/internal/staging"
+	"github.com/schraf/assistant/internal/transcript"
+	"github.com/schraf/assistant/pkg/models"
+	"github.com/schraf/assistant/pkg/providers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	screenshot = models.Attachment{Name: "screen.png", MIMEType: "image/png", Data: []byte("png bytes")}
+	paper      = models.Attachment{Name: "paper.pdf", MIMEType: "application/pdf", Data: []byte("%PDF-1.7")}
+)
+
+func TestAttachment_Validate(t *testing.T) {
+	assert.NoError(t, screenshot.Validate())
+	assert.Error(t, models.Attachment{Name: "untyped", Data: []byte("data")}.Validate())
+	assert.Error(t, models.Attachment{Name: "empty", MIMEType: "image/png"}.Validate())
+
+	assert.True(t, screenshot.IsImage())
+	assert.False(t, paper.IsImage())
+}
+
+func TestWithAttachments(t *testing.T) {
+	ctx := context.Background()
+	assert.Empty(t, models.AttachmentsFromContext(ctx))
+
+	ctx = models.WithAttachments(ctx, screenshot, paper)
+	assert.Equal(t, []models.Attachment{screenshot, paper}, models.AttachmentsFromContext(ctx))
+
+	assert.Empty(t, models.AttachmentsFromContext(models.WithAttachments(ctx)), "no attachments should clear them")
+}
+
+func TestAttachment_JSON(t *testing.T) {
+	data, err := json.Marshal(screenshot)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"name":"screen.png","mime_type":"image/png","data":"`+base64.StdEncoding.EncodeToString(screenshot.Data)+`"}`, string(data))
+}
+
+// inlineParts returns the inline data parts of the last user turn of a
+// Gemini request.
+func inlineParts(t *testing.T, body map[string]any) []map[string]any {
+	t.Helper()
+
+	contents := body["contents"].([]any)
+	turn := contents[len(contents)-1].(map[string]any)
+	assert.Equal(t, "user", turn["role"])
+
+	var inline []map[string]any
+
+	for _, part := range turn["parts"].([]any) {
+		if data, ok := part.(map[string]any)["inlineData"]; ok {
+			inline = append(inline, data.(map[string]any))
+		}
+	}
+
+	return inline
+}
+
+func TestGeminiClient_AskSendsAttachments(t *testing.T) {
+	var captured map[string]any
+
+	newGeminiServer(t, func(body map[string]any) (int, any) {
+		captured = body
+		return http.StatusOK, candidate("A screenshot and a paper")
+	})
+
+	ctx := models.WithAttachments(context.Background(), screenshot, paper)
+
+	response, err := newGeminiClient(t).Ask(ctx, "persona", "Describe these")
+	require.NoError(t, err)
+	assert.Equal(t, "A screenshot and a paper", *response)
+
+	parts := captured["contents"].([]any)[0].(map[string]any)["parts"].([]any)
+	require.Len(t, parts, 3)
+	assert.Equal(t, "Describe these", parts[0].(map[string]any)["text"], "the text should come first")
+
+	inline := inlineParts(t, captured)
+	require.Len(t, inline, 2)
+	assert.Equal(t, "image/png", inline[0]["mimeType"])
+	assert.Equal(t, base64.StdEncoding.EncodeToString(screenshot.Data), inline[0]["data"])
+	assert.Equal(t, "application/pdf", inline[1]["mimeType"])
+}
+
+func TestGeminiClient_StructuredAskSendsAttachments(t *testing.T) {
+	var captured map[string]any
+
+	newGeminiServer(t, func(body map[string]any) (int, any) {
+		captured = body
+		return http.StatusOK, candidate(`{"summary":"A short paper"}`)
+	})
+
+	ctx := models.WithAttachments(context.Background(), paper)
+
+	_, err := newGeminiClient(t).StructuredAsk(ctx, "persona", "Summarise the paper", map[string]any{"type": "object"})
+	require.NoError(t, err)
+
+	inline := inlineParts(t, captured)
+	require.Len(t, inline, 1)
+	assert.Equal(t, "application/pdf", inline[0]["mimeType"])
+}
+
+func TestGeminiClient_ChatAttachesToLastMessage(t *testing.T) {
+	var captured map[string]any
+
+	newGeminiServer(t, func(body map[string]any) (int, any) {
+		captured = body
+		return http.StatusOK, candidate("It shows a login page")
+	})
+
+	ctx := models.WithAttachments(context.Background(), screenshot)
+	messages := []models.Message{
+		{Role: models.RoleUser, Content: "Hello"},
+		{Role: models.RoleModel, Content: "Hi"},
+		{Role: models.RoleUser, Content: "What does this show?"},
+	}
+
+	_, err := newGeminiClient(t).Chat(ctx, "persona", messages)
+	require.NoError(t, err)
+
+	contents := captured["contents"].([]any)
+	require.Len(t, contents, 3)
+	assert.Len(t, contents[0].(map[string]any)["parts"], 1, "earlier messages should not carry attachments")
+	assert.Len(t, inlineParts(t, captured), 1)
+}
+
+func newOllamaServer(t *testing.T, handler func(body map[string]any)) {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]any
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body), "request body should be JSON")
+
+		handler(body)
+		ollamaReply(w, "A login page")
+	}))
+
+	t.Cleanup(server.Close)
+	t.Setenv("OLLAMA_BASE_URL", server.URL)
+}
+
+func TestOllamaClient_AskSendsImages(t *testing.T) {
+	var captured map[string]any
+
+	newOllamaServer(t, func(body map[string]any) {
+		captured = body
+	})
+
+	client, err := ollama.NewClient(context.Background(), 1)
+	require.NoError(t, err)
+
+	ctx := models.WithAttachments(context.Background(), screenshot)
+
+	response, err := client.Ask(ctx, "persona", "What does this show?")
+	require.NoError(t, err)
+	assert.Equal(t, "A login page", *response)
+
+	messages := captured["messages"].([]any)
+	require.Len(t, messages, 2)
+	assert.Nil(t, messages[0].(map[string]any)["images"], "the system prompt should not carry images")
+
+	user := messages[1].(map[string]any)
+	assert.Equal(t, "user", user["role"])
+	assert.Equal(t, []any{base64.StdEncoding.EncodeToString(screenshot.Data)}, user["images"])
+}
+
+func TestOllamaClient_ChatAttachesToLastUserMessage(t *testing.T) {
+	var captured map[string]any
+
+	newOllamaServer(t, func(body map[string]any) {
+		captured = body
+	})
+
+	client, err := ollama.NewClient(context.Background(), 1)
+	require.NoError(t, err)
+
+	messages := []models.Message{
+		{Role: models.RoleUser, Content: "Hello"},
+		{Role: models.RoleModel, Content: "Hi"},
+		{Role: models.RoleUser, Content: "What does this show?"},
+	}
+
+	_, err = client.Chat(models.WithAttachments(context.Background(), screenshot), "persona", messages)
+	require.NoError(t, err)
+
+	sent := captured["messages"].([]any)
+	require.Len(t, sent, 4)
+	assert.Nil(t, sent[1].(map[string]any)["images"])
+	assert.NotNil(t, sent[3].(map[string]any)["images"])
+}
+
+func TestOllamaClient_RejectsDocuments(t *testing.T) {
+	var calls atomic.Int32
+
+	newOllamaServer(t, func(body map[string]any) {
+		calls.Add(1)
+	})
+
+	client, err := ollama.NewClient(context.Background(), 1)
+	require.NoError(t, err)
+
+	_, err = client.Ask(models.WithAttachments(context.Background(), paper), "persona", "Summarise the paper")
+	assert.ErrorIs(t, err, models.ErrAttachmentsUnsupported)
+	assert.Contains(t, err.Error(), "application/pdf")
+	assert.Zero(t, calls.Load(), "nothing should be sent")
+}
+
+func TestOpenAIClient_RejectsAttachments(t *testing.T) {
+	var calls atomic.Int32
+
+	newOpenAIServer(t, func(body map[string]any) (int, any) {
+		calls.Add(1)
+		return http.StatusOK, completion("Hello")
+	})
+
+	client, err := openai.NewClient(context.Background(), 1)
+	require.NoError(t, err)
+
+	_, err = client.Ask(models.WithAttachments(context.Background(), screenshot), "persona", "What does this show?")
+	assert.ErrorIs(t, err, models.ErrAttachmentsUnsupported)
+	assert.Zero(t, calls.Load(), "nothing should be sent")
+}
+
+func TestCache_KeysOnAttachments(t *testing.T) {
+	calls := 0
+	assistant := newCache(t, countingAssistant(&calls), providers.CacheOptions{Provider: "mock"})
+	ctx := context.Background()
+
+	first, err := assistant.Ask(models.WithAttachments(ctx, screenshot), "persona", "Describe this")
+	require.NoError(t, err)
+
+	other := screenshot
+	other.Data = []byte("other png bytes")
+
+	second, err := assistant.Ask(models.WithAttachments(ctx, other), "persona", "Describe this")
+	require.NoError(t, err)
+	assert.NotEqual(t, *first, *second, "a different file should not be served from the cache")
+
+	again, err := assistant.Ask(models.WithAttachments(ctx, screenshot), "persona", "Describe this")
+	require.NoError(t, err)
+	assert.Equal(t, *first, *again)
+	assert.Equal(t, 2, calls)
+}
+
+func TestHandler_Attachments(t *testing.T) {
+	t.Setenv("API_TOKEN", "test-api-token")
+
+	var scheduled models.ContentRequest
+
+	handler := service.NewHandler(&mocks.MockJobScheduler{
+		ScheduleJobFunc: func(ctx context.Context, contentType string, config map[string]any, request models.ContentRequest) error {
+			scheduled = request
+			return nil
+		},
+	})
+
+	type file struct {
+		name        string
+		contentType string
+		data        []byte
+	}
+
+	post := func(body string, files ...file) int {
+		var payload bytes.Buffer
+		writer := multipart.NewWriter(&payload)
+
+		if body != "" {
+			require.NoError(t, writer.WriteField("body", body))
+		}
+
+		for _, f := range files {
+			header := textproto.MIMEHeader{}
+			header.Set("Content-Disposition", `form-data; name="file"; filename="`+f.name+`"`)
+			if f.contentType != "" {
+				header.Set("Content-Type", f.contentType)
+			}
+
+			part, err := writer.CreatePart(header)
+			require.NoError(t, err)
+			_, err = part.Write(f.data)
+			require.NoError(t, err)
+		}
+
+		require.NoError(t, writer.Close())
+
+		req := httptest.NewRequest("POST", "/content", &payload)
+		req.Header.Set("Content-Type", writer.FormDataContentType())
+		req.Header.Set("X-API-Token", "test-api-token")
+		req.Header.Set("X-Content-Type", "summary")
+
+		w := httptest.NewRecorder()
+		handler.HandleRequest(w, req)
+
+		return w.Code
+	}
+
+	code := post(`{"topic":"AI","attachments":"kept"}`,
+		file{name: paper.Name, contentType: paper.MIMEType, data: paper.Data},
+		file{name: "notes.txt", contentType: "application/octet-stream", data: []byte("notes")},
+		file{name: "untyped", data: []byte("plain text")},
+	)
+	require.Equal(t, http.StatusOK, code)
+
+	assert.Equal(t, []models.Attachment{
+		paper,
+		{Name: "notes.txt", MIMEType: "text/plain", Data: []byte("notes")},
+		{Name: "untyped", MIMEType: "text/plain", Data: []byte("plain text")},
+	}, scheduled.Attachments, "unknown types should be guessed from the name and content")
+	assert.Equal(t, map[string]any{"topic": "AI", "attachments": "kept"}, scheduled.Body, "the body should be left to the generator")
+
+	assert.Equal(t, http.StatusBadRequest, post("", file{name: paper.Name, contentType: paper.MIMEType, data: paper.Data}), "the body field is required")
+	assert.Equal(t, http.StatusBadRequest, post("not json"))
+	assert.Equal(t, http.StatusBadRequest, post(`{"topic":"AI"}`, file{name: "empty.pdf", contentType: "application/pdf"}))
+}
+
+// stageAttachments writes the attachments to a file and points the job at it.
+func stageAttachments(t *testing.T, attachments []models.Attachment) {
+	t.Helper()
+
+	uri, err := staging.WriteFile(filepath.Join(t.TempDir(), "attachments.json"), attachments)
+	require.NoError(t, err)
+
+	t.Setenv("REQUEST_ATTACHMENTS_URI", uri)
+}
+
+func TestProcessor_PassesAttachments(t *testing.T) {
+	bodyJSON, _ := json.Marshal(map[string]any{"topic": "AI"})
+
+	t.Setenv("REQUEST_ID", uuid.New().String())
+	t.Setenv("REQUEST_BODY", base64.StdEncoding.EncodeToString(bodyJSON))
+	t.Setenv("CONTENT_TYPE", "test-attachment-generator")
+	stageAttachments(t, []models.Attachment{paper})
+
+	var received []models.Attachment
+
+	mockAssistant := &mocks.MockAssistant{
+		AskFunc: func(ctx context.Context, persona string, request string) (*string, error) {
+			received = models.AttachmentsFromContext(ctx)
+			response := "The paper describes a test. It is short."
+			return &response, nil
+		},
+	}
+
+	processor := job.NewProcessor("gemini", mockAssistant, &mocks.MockPublisher{}, &mocks.MockNotifier{}, log.NewLogger())
+	require.NoError(t, processor.Process(context.Background()))
+
+	assert.Equal(t, []models.Attachment{paper}, received)
+}
+
+func TestProcessor_InvalidAttachments(t *testing.T) {
+	bodyJSON, _ := json.Marshal(map[string]any{"topic": "AI"})
+
+	t.Setenv("REQUEST_ID", uuid.New().String())
+	t.Setenv("REQUEST_BODY", base64.StdEncoding.EncodeToString(bodyJSON))
+	t.Setenv("CONTENT_TYPE", "test-attachment-generator")
+
+	processor := job.NewProcessor("gemini", &mocks.MockAssistant{}, &mocks.MockPublisher{}, &mocks.MockNotifier{}, log.NewLogger())
+
+	t.Setenv("REQUEST_ATTACHMENTS_URI", "file://"+filepath.Join(t.TempDir(), "missing.json"))
+	assert.Error(t, processor.Process(context.Background()))
+
+	stageAttachments(t, []models.Attachment{{Name: "untyped", Data: []byte("data")}})
+	assert.Error(t, processor.Process(context.Background()))
+}
+
+func TestReplay_RestoresAttachments(t *testing.T) {
+	var buf bytes.Buffer
+	record := transcript.New(&buf, uuid.New().String())
+	require.NoError(t, record.WriteRequest("gemini", "test-attachment-generator", nil, models.ContentRequest{
+		Body:        map[string]any{"topic": "AI"},
+		Attachments: []models.Attachment{paper},
+	}))
+
+	path := filepath.Join(t.TempDir(), "transcript.jsonl")
+	require.NoError(t, os.WriteFile(path, buf.Bytes(), 0o644))
+
+	recorded, err := job.LoadReplay(path)
+	require.NoError(t, err)
+	assert.Equal(t, []models.Attachment{paper}, recorded.Request.Attachments)
+
+	t.Setenv("TMPDIR", t.TempDir())
+	t.Setenv("REQUEST_ATTACHMENTS_URI", "")
+	require.NoError(t, recorded.SetEnv())
+
+	attachments, err := staging.Load(context.Background(), os.Getenv("REQUEST_ATTACHMENTS_URI"))
+	require.NoError(t, err)
+	assert.Equal(t, []models.Attachment{paper}, attachments)
+}
@@ -0,0 +1,152 @@
+package test
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"os"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/schraf/assistant/internal/job"
+	"github.com/schraf/assistant/internal/log"
+	"github.com/schraf/assistant/internal/mocks"
+	"github.com/schraf/assistant/internal/openai"
+	"github.com/schraf/assistant/pkg/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestContentBlockedError(t *testing.T) {
+	err := error(&models.ContentBlockedError{
+		BlockReason: "SAFETY",
+		SafetyRatings: []models.SafetyRating{
+			{Source: "prompt", Category: "HARM_CATEGORY_DANGEROUS_CONTENT", Probability: "HIGH", Blocked: true},
+			{Source: "prompt", Category: "HARM_CATEGORY_HARASSMENT", Probability: "NEGLIGIBLE"},
+		},
+	})
+
+	assert.ErrorIs(t, err, models.ErrContentBlocked)
+	assert.Equal(t, "content blocked: block reason SAFETY, prompt HARM_CATEGORY_DANGEROUS_CONTENT blocked", err.Error())
+
+	assert.Equal(t, "content blocked", (&models.ContentBlockedError{}).Error())
+}
+
+func TestTruncatedError(t *testing.T) {
+	err := error(&models.TruncatedError{FinishReason: "MAX_TOKENS", Text: "partial"})
+
+	assert.ErrorIs(t, err, models.ErrResponseTruncated)
+	assert.NotErrorIs(t, err, models.ErrContentBlocked)
+	assert.Equal(t, "response truncated: finish reason MAX_TOKENS after 7 characters", err.Error())
+}
+
+func TestGeminiClient_PromptBlocked(t *testing.T) {
+	newGeminiServer(t, func(body map[string]any) (int, any) {
+		return http.StatusOK, map[string]any{
+			"promptFeedback": map[string]any{
+				"blockReason": "SAFETY",
+				"safetyRatings": []any{
+					map[string]any{"category": "HARM_CATEGORY_DANGEROUS_CONTENT", "probability": "HIGH", "blocked": true},
+				},
+			},
+		}
+	})
+
+	_, err := newGeminiClient(t).Ask(context.Background(), "You are helpful", "Something dangerous")
+	require.ErrorIs(t, err, models.ErrContentBlocked)
+
+	var blocked *models.ContentBlockedError
+	require.ErrorAs(t, err, &blocked)
+	assert.Equal(t, "SAFETY", blocked.BlockReason)
+	assert.Equal(t, []models.SafetyRating{
+		{Source: "prompt", Category: "HARM_CATEGORY_DANGEROUS_CONTENT", Probability: "HIGH", Blocked: true},
+	}, blocked.SafetyRatings)
+}
+
+func TestGeminiClient_ResponseBlocked(t *testing.T) {
+	newGeminiServer(t, func(body map[string]any) (int, any) {
+		return http.StatusOK, map[string]any{
+			"candidates": []any{
+				map[string]any{
+					"finishReason": "RECITATION",
+					"safetyRatings": []any{
+						map[string]any{"category": "HARM_CATEGORY_HARASSMENT", "probability": "LOW"},
+					},
+				},
+			},
+		}
+	})
+
+	_, err := newGeminiClient(t).StructuredAsk(context.Background(), "You are helpful", "Quote a book", map[string]any{"type": "object"})
+
+	var blocked *models.ContentBlockedError
+	require.ErrorAs(t, err, &blocked)
+	assert.Equal(t, "RECITATION", blocked.FinishReason)
+	assert.Empty(t, blocked.BlockReason)
+	require.Len(t, blocked.SafetyRatings, 1)
+	assert.Equal(t, "response", blocked.SafetyRatings[0].Source)
+}
+
+func TestGeminiClient_ResponseTruncated(t *testing.T) {
+	newGeminiServer(t, func(body map[string]any) (int, any) {
+		response := candidate("The beginning of a long")
+		response["candidates"].([]any)[0].(map[string]any)["finishReason"] = "MAX_TOKENS"
+		return http.StatusOK, response
+	})
+
+	_, err := newGeminiClient(t).Ask(context.Background(), "You are helpful", "Write a long story")
+	require.ErrorIs(t, err, models.ErrResponseTruncated)
+
+	var truncated *models.TruncatedError
+	require.ErrorAs(t, err, &truncated)
+	assert.Equal(t, "MAX_TOKENS", truncated.FinishReason)
+	assert.Equal(t, "The beginning of a long", truncated.Text)
+}
+
+func TestOpenAIClient_ResponseTruncated(t *testing.T) {
+	newOpenAIServer(t, func(body map[string]any) (int, any) {
+		response := completion("The beginning")
+		response["choices"].([]any)[0].(map[string]any)["finish_reason"] = "length"
+		return http.StatusOK, response
+	})
+
+	client, err := openai.NewClient(context.Background(), 1)
+	require.NoError(t, err)
+
+	_, err = client.Ask(context.Background(), "You are helpful", "Write a long story")
+
+	var truncated *models.TruncatedError
+	require.ErrorAs(t, err, &truncated)
+	assert.Equal(t, "length", truncated.FinishReason)
+	assert.Equal(t, "The beginning", truncated.Text)
+}
+
+func TestProcessor_Integration_ContentBlocked(t *testing.T) {
+	bodyJSON, _ := json.Marshal(map[string]any{"topic": "AI"})
+	configJSON, _ := json.Marshal(map[string]any{})
+
+	os.Setenv("REQUEST_ID", uuid.New().String())
+	os.Setenv("REQUEST_BODY", base64.StdEncoding.EncodeToString(bodyJSON))
+	os.Setenv("CONTENT_CONFIG", base64.StdEncoding.EncodeToString(configJSON))
+	os.Setenv("CONTENT_TYPE", "test-assistant-generator")
+	defer func() {
+		os.Unsetenv("REQUEST_ID")
+		os.Unsetenv("REQUEST_BODY")
+		os.Unsetenv("CONTENT_CONFIG")
+		os.Unsetenv("CONTENT_TYPE")
+	}()
+
+	mockAssistant := &mocks.MockAssistant{
+		AskFunc: func(ctx context.Context, persona string, request string) (*string, error) {
+			return nil, &models.ContentBlockedError{FinishReason: "SAFETY"}
+		},
+	}
+
+	processor := job.NewProcessor("gemini", mockAssistant, &mocks.MockPublisher{}, &mocks.MockNotifier{}, log.NewLogger())
+
+	err := processor.Process(context.Background())
+	require.Error(t, err)
+	assert.ErrorIs(t, err, models.ErrContentBlocked)
+	assert.Contains(t, err.Error(), "finish reason SAFETY")
+}
@@ -0,0 +1,134 @@
+package test
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/schraf/assistant/internal/job"
+	"github.com/schraf/assistant/internal/log"
+	"github.com/schraf/assistant/internal/mocks"
+	"github.com/schraf/assistant/internal/ollama"
+	"github.com/schraf/assistant/internal/usage"
+	"github.com/schraf/assistant/pkg/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBudget_Check(t *testing.T) {
+	tracker := usage.NewTracker(usage.PriceTable{
+		"model": {InputPerMillion: 1_000_000, OutputPerMillion: 1_000_000},
+	})
+	tracker.SetBudget(usage.Budget{MaxTokens: 100})
+
+	ctx := usage.WithTracker(context.Background(), tracker)
+	require.NoError(t, usage.Check(ctx), "fresh tracker should be within budget")
+
+	tracker.Add(usage.Usage{Model: "model", PromptTokens: 60, ResponseTokens: 40})
+
+	err := usage.Check(ctx)
+	require.Error(t, err, "budget should be exceeded")
+	assert.ErrorIs(t, err, models.ErrBudgetExceeded)
+
+	var budgetErr *usage.BudgetExceededError
+	require.True(t, errors.As(err, &budgetErr))
+	assert.Equal(t, 100, budgetErr.Used.TotalTokens())
+
+	tracker.SetBudget(usage.Budget{MaxCost: 200})
+	require.NoError(t, usage.Check(ctx), "cost of $100 should be within a $200 budget")
+
+	tracker.SetBudget(usage.Budget{MaxCost: 50})
+	assert.ErrorIs(t, usage.Check(ctx), models.ErrBudgetExceeded)
+}
+
+func TestOllamaClient_BudgetExceeded(t *testing.T) {
+	requests := 0
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		json.NewEncoder(w).Encode(map[string]any{
+			"message":           map[string]any{"content": "hi"},
+			"done":              true,
+			"prompt_eval_count": 80,
+			"eval_count":        40,
+		})
+	}))
+	defer server.Close()
+
+	t.Setenv("OLLAMA_BASE_URL", server.URL)
+
+	client, err := ollama.NewClient(context.Background(), 1)
+	require.NoError(t, err)
+
+	tracker := usage.NewTracker(usage.DefaultPrices)
+	tracker.SetBudget(usage.Budget{MaxTokens: 100})
+	ctx := usage.WithTracker(context.Background(), tracker)
+
+	_, err = client.Ask(ctx, "persona", "request")
+	require.NoError(t, err, "first call should be within budget")
+
+	_, err = client.Ask(ctx, "persona", "request")
+	assert.ErrorIs(t, err, models.ErrBudgetExceeded, "second call should be refused")
+	assert.Equal(t, 1, requests, "refused calls should not reach the server")
+}
+
+func TestProcessor_Integration_BudgetExceeded(t *testing.T) {
+	bodyJSON, _ := json.Marshal(map[string]any{"topic": "AI"})
+	configJSON, _ := json.Marshal(map[string]any{"Max-Tokens": "10"})
+
+	os.Setenv("REQUEST_ID", uuid.New().String())
+	os.Setenv("REQUEST_BODY", base64.StdEncoding.EncodeToString(bodyJSON))
+	os.Setenv("CONTENT_CONFIG", base64.StdEncoding.EncodeToString(configJSON))
+	os.Setenv("CONTENT_TYPE", "test-assistant-generator")
+	defer func() {
+		os.Unsetenv("REQUEST_ID")
+		os.Unsetenv("REQUEST_BODY")
+		os.Unsetenv("CONTENT_CONFIG")
+		os.Unsetenv("CONTENT_TYPE")
+	}()
+
+	mockAssistant := &mocks.MockAssistant{
+		AskFunc: func(ctx context.Context, persona string, request string) (*string, error) {
+			tracker := usage.FromContext(ctx)
+			require.NotNil(t, tracker, "processor should attach a usage tracker")
+
+			tracker.Add(usage.Usage{Model: "model", PromptTokens: 20})
+			return nil, usage.Check(ctx)
+		},
+	}
+
+	processor := job.NewProcessor("gemini", mockAssistant, &mocks.MockPublisher{}, &mocks.MockNotifier{}, log.NewLogger())
+
+	err := processor.Process(context.Background())
+	require.Error(t, err, "processor should fail when the budget is exceeded")
+	assert.ErrorIs(t, err, models.ErrBudgetExceeded)
+	assert.Contains(t, err.Error(), "budget exceeded")
+}
+
+func TestProcessor_Integration_InvalidBudget(t *testing.T) {
+	bodyJSON, _ := json.Marshal(map[string]any{"topic": "AI"})
+	configJSON, _ := json.Marshal(map[string]any{"max-cost": "lots"})
+
+	os.Setenv("REQUEST_ID", uuid.New().String())
+	os.Setenv("REQUEST_BODY", base64.StdEncoding.EncodeToString(bodyJSON))
+	os.Setenv("CONTENT_CONFIG", base64.StdEncoding.EncodeToString(configJSON))
+	os.Setenv("CONTENT_TYPE", "test-assistant-generator")
+	defer func() {
+		os.Unsetenv("REQUEST_ID")
+		os.Unsetenv("REQUEST_BODY")
+		os.Unsetenv("CONTENT_CONFIG")
+		os.Unsetenv("CONTENT_TYPE")
+	}()
+
+	processor := job.NewProcessor("gemini", &mocks.MockAssistant{}, &mocks.MockPublisher{}, &mocks.MockNotifier{}, log.NewLogger())
+
+	err := processor.Process(context.Background())
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid budget")
+}
@@ -0,0 +1,242 @@
+package test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/schraf/assistant/internal/mocks"
+	"github.com/schraf/assistant/pkg/models"
+	"github.com/schraf/assistant/pkg/providers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// countingAssistant answers every call with the call number, so a cached
+// response can be told apart from a fresh one.
+func countingAssistant(calls *int) *mocks.MockAssistant {
+	return &mocks.MockAssistant{
+		AskFunc: func(ctx context.Context, persona string, request string) (*string, error) {
+			*calls++
+			response := fmt.Sprintf("response %d", *calls)
+			return &response, nil
+		},
+		ChatFunc: func(ctx context.Context, persona string, messages []models.Message) (*string, error) {
+			*calls++
+			response := fmt.Sprintf("reply %d", *calls)
+			return &response, nil
+		},
+		StructuredAskFunc: func(ctx context.Context, persona string, request string, schema map[string]any) (json.RawMessage, error) {
+			*calls++
+			return json.RawMessage(fmt.Sprintf(`{"call":%d}`, *calls)), nil
+		},
+		WithModelFunc: func(ctx context.Context, model string) context.Context {
+			return ctx
+		},
+	}
+}
+
+func newCache(t *testing.T, assistant models.Assistant, options providers.CacheOptions) models.Assistant {
+	t.Helper()
+
+	if options.Dir == "" {
+		options.Dir = t.TempDir()
+	}
+
+	cached, err := providers.WithCache(assistant, options)
+	require.NoError(t, err)
+
+	return cached
+}
+
+func TestCache_Ask(t *testing.T) {
+	calls := 0
+	assistant := newCache(t, countingAssistant(&calls), providers.CacheOptions{Provider: "mock"})
+	ctx := context.Background()
+
+	first, err := assistant.Ask(ctx, "persona", "request")
+	require.NoError(t, err)
+
+	second, err := assistant.Ask(ctx, "persona", "request")
+	require.NoError(t, err)
+
+	assert.Equal(t, *first, *second)
+	assert.Equal(t, 1, calls, "the second call should be answered from the cache")
+
+	_, err = assistant.Ask(ctx, "other persona", "request")
+	require.NoError(t, err)
+	assert.Equal(t, 2, calls, "a different persona should miss")
+}
+
+func TestCache_KeyIncludesModelAndOptions(t *testing.T) {
+	calls := 0
+	assistant := newCache(t, countingAssistant(&calls), providers.CacheOptions{Provider: "mock"})
+	ctx := context.Background()
+
+	contexts := []context.Context{
+		ctx,
+		assistant.WithModel(ctx, "pro"),
+		assistant.WithModel(ctx, "cheap"),
+		models.WithTemperature(ctx, 0.2),
+		models.WithBuiltinTools(ctx),
+	}
+
+	for _, ctx := range contexts {
+		_, err := assistant.Ask(ctx, "persona", "request")
+		require.NoError(t, err)
+	}
+
+	assert.Equal(t, len(contexts), calls, "each variation should have its own entry")
+
+	_, err := assistant.Ask(models.WithTemperature(ctx, 0.2), "persona", "request")
+	require.NoError(t, err)
+	assert.Equal(t, len(contexts), calls)
+}
+
+func TestCache_KeyIncludesToolDefinitions(t *testing.T) {
+	calls := 0
+	assistant := newCache(t, countingAssistant(&calls), providers.CacheOptions{Provider: "mock"})
+	ctx := context.Background()
+
+	search := models.Tool{
+		Name:        "search",
+		Description: "Searches the web",
+		Parameters:  map[string]any{"type": "object", "properties": map[string]any{"query": map[string]any{"type": "string"}}},
+	}
+
+	described := search
+	described.Description = "Searches the news"
+
+	parameterised := search
+	parameterised.Parameters = map[string]any{"type": "object", "properties": map[string]any{"terms": map[string]any{"type": "string"}}}
+
+	for _, tool := range []models.Tool{search, described, parameterised, search} {
+		_, err := assistant.Ask(models.WithTools(ctx, tool), "persona", "request")
+		require.NoError(t, err)
+	}
+
+	assert.Equal(t, 3, calls, "a changed tool definition should miss, the same one should hit")
+}
+
+func TestCache_ProvidersShareDirectory(t *testing.T) {
+	dir := t.TempDir()
+
+	var geminiCalls, ollamaCalls int
+	gemini := newCache(t, countingAssistant(&geminiCalls), providers.CacheOptions{Dir: dir, Provider: "gemini"})
+	ollama := newCache(t, countingAssistant(&ollamaCalls), providers.CacheOptions{Dir: dir, Provider: "ollama"})
+
+	_, err := gemini.Ask(context.Background(), "persona", "request")
+	require.NoError(t, err)
+
+	_, err = ollama.Ask(context.Background(), "persona", "request")
+	require.NoError(t, err)
+
+	assert.Equal(t, 1, geminiCalls)
+	assert.Equal(t, 1, ollamaCalls, "providers should not answer for each other")
+
+	// A new decorator over the same directory, as in a second run
+	rerun := newCache(t, countingAssistant(&geminiCalls), providers.CacheOptions{Dir: dir, Provider: "gemini"})
+	_, err = rerun.Ask(context.Background(), "persona", "request")
+	require.NoError(t, err)
+	assert.Equal(t, 1, geminiCalls, "responses should persist on disk")
+}
+
+func TestCache_StructuredAskAndChat(t *testing.T) {
+	calls := 0
+	assistant := newCache(t, countingAssistant(&calls), providers.CacheOptions{})
+	ctx := context.Background()
+
+	first, err := assistant.StructuredAsk(ctx, "persona", "request", articleSchema)
+	require.NoError(t, err)
+
+	second, err := assistant.StructuredAsk(ctx, "persona", "request", articleSchema)
+	require.NoError(t, err)
+	assert.JSONEq(t, string(first), string(second))
+
+	_, err = assistant.StructuredAsk(ctx, "persona", "request", map[string]any{"type": "object"})
+	require.NoError(t, err)
+	assert.Equal(t, 2, calls, "a different schema should miss")
+
+	messages := []models.Message{{Role: models.RoleUser, Content: "Hello"}}
+
+	for range 2 {
+		_, err = assistant.Chat(ctx, "persona", messages)
+		require.NoError(t, err)
+	}
+	assert.Equal(t, 3, calls)
+}
+
+func TestCache_AskStream(t *testing.T) {
+	calls := 0
+	assistant := newCache(t, countingAssistant(&calls), providers.CacheOptions{})
+
+	for range 2 {
+		var chunks []string
+
+		response, err := assistant.AskStream(context.Background(), "persona", "request", func(chunk string) error {
+			chunks = append(chunks, chunk)
+			return nil
+		})
+		require.NoError(t, err)
+		assert.Equal(t, []string{*response}, chunks)
+	}
+
+	assert.Equal(t, 1, calls)
+}
+
+func TestCache_TTLAndBypass(t *testing.T) {
+	calls := 0
+	assistant := newCache(t, countingAssistant(&calls), providers.CacheOptions{TTL: 50 * time.Millisecond})
+	ctx := context.Background()
+
+	_, err := assistant.Ask(ctx, "persona", "request")
+	require.NoError(t, err)
+
+	response, err := assistant.Ask(providers.WithCacheBypass(ctx), "persona", "request")
+	require.NoError(t, err)
+	assert.Equal(t, "response 2", *response, "bypass should skip the cache")
+
+	response, err = assistant.Ask(ctx, "persona", "request")
+	require.NoError(t, err)
+	assert.Equal(t, "response 2", *response, "the bypassed response should replace the cached one")
+
+	time.Sleep(60 * time.Millisecond)
+
+	response, err = assistant.Ask(ctx, "persona", "request")
+	require.NoError(t, err)
+	assert.Equal(t, "response 3", *response, "expired responses should not be used")
+}
+
+func TestCache_DoesNotCacheErrors(t *testing.T) {
+	calls := 0
+
+	mockAssistant := &mocks.MockAssistant{
+		AskFunc: func(ctx context.Context, persona string, request string) (*string, error) {
+			calls++
+			return nil, errors.New("unavailable")
+		},
+	}
+
+	dir := t.TempDir()
+	assistant := newCache(t, mockAssistant, providers.CacheOptions{Dir: dir})
+
+	for range 2 {
+		_, err := assistant.Ask(context.Background(), "persona", "request")
+		require.Error(t, err)
+	}
+
+	assert.Equal(t, 2, calls)
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+}
+
+func TestCache_RequiresDirectory(t *testing.T) {
+	_, err := providers.WithCache(&mocks.MockAssistant{}, providers.CacheOptions{})
+	assert.Error(t, err)
+}
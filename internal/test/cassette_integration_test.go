@@ -0,0 +1,211 @@
+package test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/schraf/assistant/internal/mocks"
+	"github.com/schraf/assistant/pkg/cassette"
+	"github.com/schraf/assistant/pkg/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordCassette records a few calls against a mock provider and returns
+// the cassette path.
+func recordCassette(t *testing.T) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "cassettes", "generator.json")
+	calls := 0
+
+	provider := &mocks.MockAssistant{
+		AskFunc: func(ctx context.Context, persona string, request string) (*string, error) {
+			calls++
+			if request == "fail" {
+				return nil, errors.New("provider unavailable")
+			}
+			response := "answer to " + request
+			if calls > 1 {
+				response += " again"
+			}
+			return &response, nil
+		},
+		StructuredAskFunc: func(ctx context.Context, persona string, request string, schema map[string]any) (json.RawMessage, error) {
+			return json.RawMessage(`{"title":"Recorded","sections":["one"]}`), nil
+		},
+		WithModelFunc: func(ctx context.Context, model string) context.Context {
+			return ctx
+		},
+	}
+
+	recorder := cassette.NewRecorder(provider, path)
+	ctx := recorder.WithModel(context.Background(), "pro")
+
+	_, err := recorder.Ask(ctx, "writer", "outline")
+	require.NoError(t, err)
+
+	_, err = recorder.StructuredAsk(ctx, "writer", "article", articleSchema)
+	require.NoError(t, err)
+
+	_, err = recorder.Ask(ctx, "writer", "outline")
+	require.NoError(t, err)
+
+	_, err = recorder.Ask(ctx, "writer", "fail")
+	require.Error(t, err)
+
+	_, err = recorder.AskStream(ctx, "writer", "story", func(chunk string) error { return nil })
+	require.NoError(t, err)
+
+	assert.Len(t, recorder.Interactions(), 5)
+
+	return path
+}
+
+func TestCassette_RecordAndReplay(t *testing.T) {
+	path := recordCassette(t)
+
+	loaded, err := cassette.Load(path)
+	require.NoError(t, err)
+	require.Len(t, loaded.Interactions, 5)
+	assert.Equal(t, "pro", loaded.Interactions[0].Model)
+	assert.Equal(t, "provider unavailable", loaded.Interactions[3].Error)
+
+	replayer, err := cassette.NewReplayer(path, cassette.MatchStrict)
+	require.NoError(t, err)
+
+	ctx := replayer.WithModel(context.Background(), "pro")
+
+	response, err := replayer.Ask(ctx, "writer", "outline")
+	require.NoError(t, err)
+	assert.Equal(t, "answer to outline", *response)
+
+	response, err = replayer.Ask(ctx, "writer", "outline")
+	require.NoError(t, err)
+	assert.Equal(t, "answer to outline again", *response, "repeated calls should get their own recordings")
+
+	article, err := replayer.StructuredAsk(ctx, "writer", "article", articleSchema)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"title":"Recorded","sections":["one"]}`, string(article))
+
+	_, err = replayer.Ask(ctx, "writer", "fail")
+	assert.EqualError(t, err, "provider unavailable", "recorded errors should be replayed")
+
+	var chunks []string
+	_, err = replayer.AskStream(ctx, "writer", "story", func(chunk string) error {
+		chunks = append(chunks, chunk)
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"answer to story again"}, chunks)
+
+	assert.Empty(t, replayer.Unused())
+
+	_, err = replayer.Ask(ctx, "writer", "outline")
+	assert.ErrorIs(t, err, cassette.ErrNoInteraction, "used interactions should not be served twice")
+}
+
+func TestCassette_ReplaysErrorSentinels(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "errors.json")
+
+	failures := map[string]error{
+		"blocked":   &models.ContentBlockedError{FinishReason: "SAFETY"},
+		"truncated": &models.TruncatedError{FinishReason: "MAX_TOKENS"},
+		"invalid":   &models.SchemaValidationError{Errors: []string{"$: missing title"}},
+		"budget":    fmt.Errorf("gemini: %w", models.ErrBudgetExceeded),
+		"other":     errors.New("provider unavailable"),
+	}
+
+	recorder := cassette.NewRecorder(&mocks.MockAssistant{
+		AskFunc: func(ctx context.Context, persona string, request string) (*string, error) {
+			return nil, failures[request]
+		},
+	}, path)
+
+	for request := range failures {
+		_, err := recorder.Ask(context.Background(), "writer", request)
+		require.Error(t, err)
+	}
+
+	replayer, err := cassette.NewReplayer(path, cassette.MatchRequest)
+	require.NoError(t, err)
+
+	for request, failure := range failures {
+		_, err := replayer.Ask(context.Background(), "writer", request)
+		require.Error(t, err)
+		assert.Equal(t, failure.Error(), err.Error(), "the message should be kept")
+
+		for _, sentinel := range []error{models.ErrContentBlocked, models.ErrResponseTruncated, models.ErrSchemaValidation, models.ErrBudgetExceeded} {
+			assert.Equal(t, errors.Is(failure, sentinel), errors.Is(err, sentinel), "%s should match %v as recorded", request, sentinel)
+		}
+	}
+}
+
+func TestCassette_MatchStrictness(t *testing.T) {
+	path := recordCassette(t)
+
+	strict, err := cassette.NewReplayer(path, cassette.MatchStrict)
+	require.NoError(t, err)
+
+	_, err = strict.Ask(context.Background(), "writer", "outline")
+	assert.ErrorIs(t, err, cassette.ErrNoInteraction, "strict matching should compare the model")
+
+	_, err = strict.Ask(strict.WithModel(context.Background(), "pro"), "editor", "outline")
+	assert.ErrorIs(t, err, cassette.ErrNoInteraction, "strict matching should compare the persona")
+
+	loose, err := cassette.NewReplayer(path, cassette.MatchRequest)
+	require.NoError(t, err)
+
+	response, err := loose.Ask(context.Background(), "editor", "outline")
+	require.NoError(t, err)
+	assert.Equal(t, "answer to outline", *response)
+
+	_, err = loose.StructuredAsk(context.Background(), "editor", "article", map[string]any{"type": "object"})
+	assert.ErrorIs(t, err, cassette.ErrNoInteraction, "the schema should still be compared")
+
+	sequence, err := cassette.NewReplayer(path, cassette.MatchSequence)
+	require.NoError(t, err)
+
+	response, err = sequence.Ask(context.Background(), "anyone", "anything")
+	require.NoError(t, err)
+	assert.Equal(t, "answer to outline", *response)
+
+	_, err = sequence.StructuredAsk(context.Background(), "anyone", "anything", nil)
+	require.NoError(t, err, "methods should still be matched")
+
+	assert.Len(t, sequence.Unused(), 3)
+}
+
+func TestCassette_ReplaysGenerator(t *testing.T) {
+	recorded := &cassette.Cassette{
+		Interactions: []cassette.Interaction{
+			{
+				Method:   cassette.MethodChat,
+				Persona:  "writer",
+				Messages: []models.Message{{Role: models.RoleUser, Content: "Draft"}},
+				Response: json.RawMessage(`"A draft"`),
+			},
+		},
+	}
+
+	path := filepath.Join(t.TempDir(), "chat.json")
+	require.NoError(t, recorded.Save(path))
+
+	replayer, err := cassette.NewReplayer(path, cassette.MatchRequest)
+	require.NoError(t, err)
+
+	conversation := models.NewConversation(replayer, "writer")
+
+	response, err := conversation.Send(context.Background(), "Draft")
+	require.NoError(t, err)
+	assert.Equal(t, "A draft", *response)
+}
+
+func TestCassette_MissingFile(t *testing.T) {
+	_, err := cassette.NewReplayer(filepath.Join(t.TempDir(), "missing.json"), cassette.MatchStrict)
+	assert.Error(t, err)
+}
@@ -0,0 +1,139 @@
+package test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/schraf/assistant/internal/mocks"
+	"github.com/schraf/assistant/internal/ollama"
+	"github.com/schraf/assistant/internal/openai"
+	"github.com/schraf/assistant/pkg/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConversation_KeepsHistory(t *testing.T) {
+	var received [][]models.Message
+
+	mockAssistant := &mocks.MockAssistant{
+		ChatFunc: func(ctx context.Context, persona string, messages []models.Message) (*string, error) {
+			assert.Equal(t, "Editor", persona)
+			received = append(received, messages)
+			reply := fmt.Sprintf("reply %d", len(received))
+			return &reply, nil
+		},
+	}
+
+	conversation := models.NewConversation(mockAssistant, "Editor")
+
+	_, err := conversation.Send(context.Background(), "Draft an intro")
+	require.NoError(t, err)
+
+	reply, err := conversation.Send(context.Background(), "Critique it")
+	require.NoError(t, err)
+	assert.Equal(t, "reply 2", *reply)
+
+	require.Len(t, received, 2)
+	assert.Equal(t, []models.Message{
+		{Role: models.RoleUser, Content: "Draft an intro"},
+		{Role: models.RoleModel, Content: "reply 1"},
+		{Role: models.RoleUser, Content: "Critique it"},
+	}, received[1], "later turns should carry the full history")
+
+	assert.Len(t, conversation.History(), 4)
+}
+
+func TestConversation_FailedSendKeepsHistory(t *testing.T) {
+	fail := false
+
+	mockAssistant := &mocks.MockAssistant{
+		ChatFunc: func(ctx context.Context, persona string, messages []models.Message) (*string, error) {
+			if fail {
+				return nil, assert.AnError
+			}
+			reply := "ok"
+			return &reply, nil
+		},
+	}
+
+	conversation := models.NewConversation(mockAssistant, "persona")
+
+	_, err := conversation.Send(context.Background(), "first")
+	require.NoError(t, err)
+
+	fail = true
+	_, err = conversation.Send(context.Background(), "second")
+	require.Error(t, err)
+
+	assert.Len(t, conversation.History(), 2, "a failed turn should not be added to the history")
+}
+
+func TestMockAssistant_ChatRequiresUserTurn(t *testing.T) {
+	mockAssistant := &mocks.MockAssistant{}
+
+	_, err := mockAssistant.Chat(context.Background(), "persona", []models.Message{
+		{Role: models.RoleModel, Content: "hello"},
+	})
+	assert.ErrorIs(t, err, models.ErrInvalidConversation)
+}
+
+func TestOllamaClient_Chat(t *testing.T) {
+	var captured map[string]any
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&captured))
+		json.NewEncoder(w).Encode(map[string]any{"message": map[string]any{"content": "revised"}, "done": true})
+	}))
+	defer server.Close()
+
+	t.Setenv("OLLAMA_BASE_URL", server.URL)
+
+	client, err := ollama.NewClient(context.Background(), 1)
+	require.NoError(t, err)
+
+	reply, err := client.Chat(context.Background(), "Editor", []models.Message{
+		{Role: models.RoleUser, Content: "draft"},
+		{Role: models.RoleModel, Content: "a draft"},
+		{Role: models.RoleUser, Content: "revise"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "revised", *reply)
+
+	messages := captured["messages"].([]any)
+	require.Len(t, messages, 4)
+
+	roles := []string{}
+	for _, m := range messages {
+		roles = append(roles, m.(map[string]any)["role"].(string))
+	}
+	assert.Equal(t, []string{"system", "user", "assistant", "user"}, roles)
+}
+
+func TestOpenAIClient_Chat(t *testing.T) {
+	var captured map[string]any
+
+	newOpenAIServer(t, func(body map[string]any) (int, any) {
+		captured = body
+		return http.StatusOK, completion("revised")
+	})
+
+	client, err := openai.NewClient(context.Background(), 1)
+	require.NoError(t, err)
+
+	reply, err := client.Chat(context.Background(), "Editor", []models.Message{
+		{Role: models.RoleUser, Content: "draft"},
+		{Role: models.RoleModel, Content: "a draft"},
+		{Role: models.RoleUser, Content: "revise"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "revised", *reply)
+
+	messages := captured["messages"].([]any)
+	require.Len(t, messages, 4)
+	assert.Equal(t, "assistant", messages[2].(map[string]any)["role"])
+	assert.Equal(t, "a draft", messages[2].(map[string]any)["content"])
+}
@@ -0,0 +1,320 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/schraf/assistant/internal/breaker"
+	"github.com/schraf/assistant/internal/mocks"
+	"github.com/schraf/assistant/pkg/models"
+	"github.com/schraf/assistant/pkg/providers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var errOutage = errors.New("service unavailable")
+
+// failingAssistant returns a mock that fails every Ask with err, counting
+// its calls.
+func failingAssistant(calls *int, err error) *mocks.MockAssistant {
+	return &mocks.MockAssistant{
+		AskFunc: func(ctx context.Context, persona string, request string) (*string, error) {
+			*calls++
+			return nil, err
+		},
+	}
+}
+
+func answeringAssistant(calls *int, response string) *mocks.MockAssistant {
+	return &mocks.MockAssistant{
+		AskFunc: func(ctx context.Context, persona string, request string) (*string, error) {
+			*calls++
+			return &response, nil
+		},
+	}
+}
+
+func TestFailover_FallsThroughOnFailure(t *testing.T) {
+	var proCalls, flashCalls int
+
+	assistant, err := providers.NewFailover([]providers.Link{
+		{Name: "pro", Assistant: failingAssistant(&proCalls, errOutage)},
+		{Name: "flash", Assistant: answeringAssistant(&flashCalls, "from flash")},
+	}, providers.FailoverOptions{})
+	require.NoError(t, err)
+
+	response, err := assistant.Ask(context.Background(), "persona", "request")
+	require.NoError(t, err)
+	assert.Equal(t, "from flash", *response)
+	assert.Equal(t, 1, proCalls)
+	assert.Equal(t, 1, flashCalls)
+}
+
+func TestFailover_OpenCircuitSkipsProvider(t *testing.T) {
+	var proCalls, flashCalls int
+
+	assistant, err := providers.NewFailover([]providers.Link{
+		{Name: "pro", Assistant: failingAssistant(&proCalls, errOutage)},
+		{Name: "flash", Assistant: answeringAssistant(&flashCalls, "from flash")},
+	}, providers.FailoverOptions{FailureThreshold: 2, Cooldown: time.Hour})
+	require.NoError(t, err)
+
+	for range 5 {
+		_, err := assistant.Ask(context.Background(), "persona", "request")
+		require.NoError(t, err)
+	}
+
+	assert.Equal(t, 2, proCalls, "the open circuit should skip the failing provider")
+	assert.Equal(t, 5, flashCalls)
+}
+
+func TestFailover_AllProvidersFail(t *testing.T) {
+	var proCalls, flashCalls int
+
+	assistant, err := providers.NewFailover([]providers.Link{
+		{Name: "pro", Assistant: failingAssistant(&proCalls, errOutage)},
+		{Name: "flash", Assistant: failingAssistant(&flashCalls, errOutage)},
+	}, providers.FailoverOptions{FailureThreshold: 1, Cooldown: time.Hour})
+	require.NoError(t, err)
+
+	_, err = assistant.Ask(context.Background(), "persona", "request")
+	require.ErrorIs(t, err, providers.ErrAllProvidersFailed)
+	assert.ErrorIs(t, err, errOutage)
+	assert.Contains(t, err.Error(), "pro: service unavailable")
+
+	_, err = assistant.Ask(context.Background(), "persona", "request")
+	assert.ErrorIs(t, err, breaker.ErrOpen, "open circuits should be reported")
+	assert.Equal(t, 1, proCalls)
+	assert.Equal(t, 1, flashCalls)
+}
+
+func TestFailover_ContentBlocked(t *testing.T) {
+	blocked := &models.ContentBlockedError{FinishReason: "SAFETY"}
+
+	var proCalls, localCalls int
+
+	links := []providers.Link{
+		{Name: "pro", Assistant: failingAssistant(&proCalls, blocked)},
+		{Name: "local", Assistant: answeringAssistant(&localCalls, "from local")},
+	}
+
+	assistant, err := providers.NewFailover(links, providers.FailoverOptions{})
+	require.NoError(t, err)
+
+	_, err = assistant.Ask(context.Background(), "persona", "request")
+	require.ErrorIs(t, err, models.ErrContentBlocked, "blocked content should be returned by default")
+	assert.Equal(t, 0, localCalls)
+
+	assistant, err = providers.NewFailover(links, providers.FailoverOptions{RetryBlocked: true})
+	require.NoError(t, err)
+
+	response, err := assistant.Ask(context.Background(), "persona", "request")
+	require.NoError(t, err)
+	assert.Equal(t, "from local", *response)
+}
+
+func TestFailover_DoesNotFailOverCallerErrors(t *testing.T) {
+	var proCalls, flashCalls int
+
+	assistant, err := providers.NewFailover([]providers.Link{
+		{Name: "pro", Assistant: failingAssistant(&proCalls, models.ErrBudgetExceeded)},
+		{Name: "flash", Assistant: answeringAssistant(&flashCalls, "from flash")},
+	}, providers.FailoverOptions{FailureThreshold: 1})
+	require.NoError(t, err)
+
+	for range 3 {
+		_, err = assistant.Ask(context.Background(), "persona", "request")
+		require.ErrorIs(t, err, models.ErrBudgetExceeded)
+	}
+
+	assert.Equal(t, 3, proCalls, "caller errors should not open the circuit")
+	assert.Equal(t, 0, flashCalls)
+}
+
+func TestFailover_StreamDoesNotFailOverAfterOutput(t *testing.T) {
+	var flashCalls int
+
+	pro := &mocks.MockAssistant{
+		AskStreamFunc: func(ctx context.Context, persona string, request string, handler models.StreamHandler) (*string, error) {
+			if err := handler("partial"); err != nil {
+				return nil, err
+			}
+			return nil, errOutage
+		},
+	}
+
+	assistant, err := providers.NewFailover([]providers.Link{
+		{Name: "pro", Assistant: pro},
+		{Name: "flash", Assistant: answeringAssistant(&flashCalls, "from flash")},
+	}, providers.FailoverOptions{})
+	require.NoError(t, err)
+
+	var chunks []string
+
+	_, err = assistant.AskStream(context.Background(), "persona", "request", func(chunk string) error {
+		chunks = append(chunks, chunk)
+		return nil
+	})
+	require.ErrorIs(t, err, errOutage)
+	assert.Equal(t, []string{"partial"}, chunks)
+	assert.Equal(t, 0, flashCalls)
+}
+
+func TestFailover_StreamResponseErrorsKeepCircuitClosed(t *testing.T) {
+	var proCalls, flashCalls int
+
+	pro := &mocks.MockAssistant{
+		AskStreamFunc: func(ctx context.Context, persona string, request string, handler models.StreamHandler) (*string, error) {
+			proCalls++
+			if err := handler("partial"); err != nil {
+				return nil, err
+			}
+			return nil, &models.TruncatedError{FinishReason: "MAX_TOKENS", Text: "partial"}
+		},
+	}
+
+	assistant, err := providers.NewFailover([]providers.Link{
+		{Name: "pro", Assistant: pro},
+		{Name: "flash", Assistant: answeringAssistant(&flashCalls, "from flash")},
+	}, providers.FailoverOptions{FailureThreshold: 1, Cooldown: time.Hour})
+	require.NoError(t, err)
+
+	for range 3 {
+		_, err = assistant.AskStream(context.Background(), "persona", "request", func(chunk string) error { return nil })
+		require.ErrorIs(t, err, models.ErrResponseTruncated)
+	}
+
+	assert.Equal(t, 3, proCalls, "a truncated stream should not open the circuit")
+	assert.Equal(t, 0, flashCalls)
+}
+
+func TestFailover_AttachmentsUnsupported(t *testing.T) {
+	var localCalls, geminiCalls int
+
+	unsupported := fmt.Errorf("openai: %w", models.ErrAttachmentsUnsupported)
+
+	assistant, err := providers.NewFailover([]providers.Link{
+		{Name: "local", Assistant: failingAssistant(&localCalls, unsupported)},
+		{Name: "gemini", Assistant: answeringAssistant(&geminiCalls, "from gemini")},
+	}, providers.FailoverOptions{FailureThreshold: 1, Cooldown: time.Hour})
+	require.NoError(t, err)
+
+	for range 3 {
+		response, err := assistant.Ask(context.Background(), "persona", "request")
+		require.NoError(t, err)
+		assert.Equal(t, "from gemini", *response, "a provider that cannot read the files should be skipped")
+	}
+
+	assert.Equal(t, 3, localCalls, "unsupported attachments should not open the circuit")
+	assert.Equal(t, 3, geminiCalls)
+}
+
+func TestFailover_Models(t *testing.T) {
+	var selected []string
+
+	link := func(name string, model string) providers.Link {
+		return providers.Link{
+			Name:  name,
+			Model: model,
+			Assistant: &mocks.MockAssistant{
+				AskFunc: func(ctx context.Context, persona string, request string) (*string, error) {
+					return nil, errOutage
+				},
+				WithModelFunc: func(ctx context.Context, model string) context.Context {
+					selected = append(selected, name+"="+model)
+					return ctx
+				},
+			},
+		}
+	}
+
+	assistant, err := providers.NewFailover([]providers.Link{
+		link("pro", "gemini-pro-latest"),
+		link("local", ""),
+	}, providers.FailoverOptions{})
+	require.NoError(t, err)
+
+	ctx := assistant.WithModel(context.Background(), models.TierCheap)
+	_, err = assistant.Ask(ctx, "persona", "request")
+	require.Error(t, err)
+
+	assert.Equal(t, []string{"pro=gemini-pro-latest", "local=cheap"}, selected, "pinned links should keep their model")
+}
+
+func TestFailover_ResolveModel(t *testing.T) {
+	tables := providers.DefaultModelTables
+
+	assistant, err := providers.NewFailover([]providers.Link{
+		{Name: "gemini:pro", Assistant: providers.WithModelTable(&mocks.MockAssistant{}, tables["gemini"]), Model: "gemini-pro-latest"},
+		{Name: "gemini", Assistant: providers.WithModelTable(&mocks.MockAssistant{}, tables["gemini"])},
+		{Name: "ollama", Assistant: providers.WithModelTable(&mocks.MockAssistant{}, tables["ollama"])},
+	}, providers.FailoverOptions{})
+	require.NoError(t, err)
+
+	model, err := providers.ResolveModel(assistant, models.TierCheap)
+	require.NoError(t, err)
+	assert.Equal(t, models.TierCheap, model, "each link should resolve aliases itself")
+
+	_, err = providers.ResolveModel(assistant, "gemini-2.5-flash")
+	require.Error(t, err, "every unpinned link should know the model")
+	assert.Contains(t, err.Error(), "ollama: unknown model")
+
+	_, err = providers.ResolveModel(assistant, "not-a-model")
+	assert.Error(t, err)
+
+	// Wrapping the chain in its own empty table keeps the links' validation
+	_, err = providers.ResolveModel(providers.WithModelTable(assistant, tables["failover"]), "not-a-model")
+	assert.Error(t, err)
+}
+
+func TestParseChain(t *testing.T) {
+	links, names, err := providers.ParseChain("gemini:pro, gemini:basic,ollama:qwen3:32b,ollama")
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"gemini", "gemini", "ollama", "ollama"}, names)
+	assert.Equal(t, "pro", links[0].Model)
+	assert.Equal(t, "qwen3:32b", links[2].Model, "model tags may contain colons")
+	assert.Equal(t, "", links[3].Model)
+
+	_, _, err = providers.ParseChain(" , ")
+	assert.Error(t, err)
+}
+
+func TestFailover_FromEnv(t *testing.T) {
+	t.Setenv("ASSISTANT_PROVIDER", "failover")
+	t.Setenv("ASSISTANT_FAILOVER", "mock,mock:backup")
+	t.Setenv("ASSISTANT_FAILOVER_THRESHOLD", "5")
+	t.Setenv("ASSISTANT_FAILOVER_COOLDOWN", "30s")
+	t.Setenv("ASSISTANT_FAILOVER_RETRY_BLOCKED", "true")
+	t.Setenv("ASSISTANT_MODELS", "")
+
+	name, config := providers.FromEnv()
+	require.Equal(t, "failover", name)
+
+	cooldown, err := config.Duration("cooldown", 0)
+	require.NoError(t, err)
+	assert.Equal(t, 30*time.Second, cooldown)
+
+	retryBlocked, err := config.Bool("retry_blocked", false)
+	require.NoError(t, err)
+	assert.True(t, retryBlocked)
+
+	assistant, err := providers.Create(context.Background(), name, config)
+	require.NoError(t, err)
+
+	response, err := assistant.Ask(context.Background(), "persona", "request")
+	require.NoError(t, err)
+	assert.Equal(t, "Mock response", *response)
+
+	config["chain"] = "failover"
+	_, err = providers.Create(context.Background(), name, config)
+	assert.Error(t, err, "a chain should not contain itself")
+
+	config["chain"] = "mock"
+	config["cooldown"] = "soon"
+	_, err = providers.Create(context.Background(), name, config)
+	assert.Error(t, err)
+}
@@ -0,0 +1,225 @@
+package test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/schraf/assistant/internal/gemini"
+	"github.com/schraf/assistant/pkg/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newGeminiServer(t *testing.T, handler func(body map[string]any) (int, any)) {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.True(t, strings.HasSuffix(r.URL.Path, ":generateContent"), "request should generate content")
+
+		var body map[string]any
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body), "request body should be JSON")
+
+		status, response := handler(body)
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(response)
+	}))
+
+	t.Cleanup(server.Close)
+	t.Setenv("GOOGLE_GEMINI_BASE_URL", server.URL)
+	t.Setenv("GOOGLE_API_KEY", "test-key")
+	t.Setenv("GOOGLE_GENAI_USE_VERTEXAI", "false")
+}
+
+func candidate(text string) map[string]any {
+	return map[string]any{
+		"candidates": []any{
+			map[string]any{
+				"content": map[string]any{
+					"role":  "model",
+					"parts": []any{map[string]any{"text": text}},
+				},
+				"finishReason": "STOP",
+			},
+		},
+	}
+}
+
+func newGeminiClient(t *testing.T) *gemini.Client {
+	t.Helper()
+
+	client, err := gemini.NewClient(context.Background(), 1)
+	require.NoError(t, err)
+
+	return client
+}
+
+func TestGeminiClient_AskDefaultBuiltinTools(t *testing.T) {
+	var captured map[string]any
+
+	newGeminiServer(t, func(body map[string]any) (int, any) {
+		captured = body
+		return http.StatusOK, candidate("Hello")
+	})
+
+	response, err := newGeminiClient(t).Ask(context.Background(), "You are helpful", "Say hello")
+	require.NoError(t, err)
+	assert.Equal(t, "Hello", *response)
+
+	tools := captured["tools"].([]any)
+	require.Len(t, tools, 1)
+	assert.Contains(t, tools[0], "googleSearch", "Ask should search by default")
+	assert.Contains(t, tools[0], "urlContext", "Ask should read URLs by default")
+}
+
+func TestGeminiClient_AskWithoutBuiltinTools(t *testing.T) {
+	var captured map[string]any
+
+	newGeminiServer(t, func(body map[string]any) (int, any) {
+		captured = body
+		return http.StatusOK, candidate("Hello")
+	})
+
+	ctx := models.WithBuiltinTools(context.Background())
+
+	_, err := newGeminiClient(t).Ask(ctx, "You are helpful", "Say hello")
+	require.NoError(t, err)
+	assert.NotContains(t, captured, "tools", "no tools should be sent")
+}
+
+func TestGeminiClient_AskWithCodeExecution(t *testing.T) {
+	var captured map[string]any
+
+	newGeminiServer(t, func(body map[string]any) (int, any) {
+		captured = body
+		return http.StatusOK, candidate("42")
+	})
+
+	ctx := models.WithBuiltinTools(context.Background(), models.BuiltinCodeExecution)
+
+	_, err := newGeminiClient(t).Ask(ctx, "You are helpful", "Compute the answer")
+	require.NoError(t, err)
+
+	tools := captured["tools"].([]any)
+	require.Len(t, tools, 1)
+	assert.Contains(t, tools[0], "codeExecution")
+	assert.NotContains(t, tools[0], "googleSearch")
+}
+
+func TestGeminiClient_StructuredAskBuiltinTools(t *testing.T) {
+	var captured map[string]any
+
+	newGeminiServer(t, func(body map[string]any) (int, any) {
+		captured = body
+		return http.StatusOK, candidate(`{"answer":"yes"}`)
+	})
+
+	client := newGeminiClient(t)
+	schema := map[string]any{"type": "object"}
+
+	response, err := client.StructuredAsk(context.Background(), "You are helpful", "Answer", schema)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"answer":"yes"}`, string(response))
+	assert.NotContains(t, captured, "tools", "StructuredAsk should not be grounded by default")
+
+	ctx := models.WithBuiltinTools(context.Background(), models.BuiltinSearch)
+
+	_, err = client.StructuredAsk(ctx, "You are helpful", "Answer", schema)
+	require.NoError(t, err)
+
+	tools := captured["tools"].([]any)
+	require.Len(t, tools, 1)
+	assert.Contains(t, tools[0], "googleSearch", "StructuredAsk should be grounded when asked")
+}
+
+func TestGeminiClient_CustomToolsReplaceBuiltins(t *testing.T) {
+	var requests []map[string]any
+
+	newGeminiServer(t, func(body map[string]any) (int, any) {
+		requests = append(requests, body)
+
+		if len(requests) == 1 {
+			return http.StatusOK, map[string]any{
+				"candidates": []any{
+					map[string]any{
+						"content": map[string]any{
+							"role": "model",
+							"parts": []any{
+								map[string]any{
+									"functionCall": map[string]any{
+										"name": "get_weather",
+										"args": map[string]any{"city": "Paris"},
+									},
+								},
+							},
+						},
+					},
+				},
+			}
+		}
+
+		return http.StatusOK, candidate("It is sunny in Paris")
+	})
+
+	var calls []string
+	ctx := models.WithTools(context.Background(), weatherTool(&calls))
+
+	response, err := newGeminiClient(t).Ask(ctx, "You are helpful", "What is the weather in Paris?")
+	require.NoError(t, err)
+	assert.Equal(t, "It is sunny in Paris", *response)
+	assert.Equal(t, []string{"Paris"}, calls)
+
+	require.Len(t, requests, 2)
+
+	tools := requests[0]["tools"].([]any)
+	require.Len(t, tools, 1)
+	assert.Contains(t, tools[0], "functionDeclarations")
+	assert.NotContains(t, tools[0], "googleSearch")
+
+	contents := requests[1]["contents"].([]any)
+	require.Len(t, contents, 3, "follow up should carry the call and its response")
+}
+
+func TestGeminiClient_AskWithSources(t *testing.T) {
+	newGeminiServer(t, func(body map[string]any) (int, any) {
+		response := candidate("Paris is the capital of France.")
+		result := response["candidates"].([]any)[0].(map[string]any)
+
+		result["groundingMetadata"] = map[string]any{
+			"groundingChunks": []any{
+				map[string]any{"web": map[string]any{"uri": "https://example.com/paris", "title": "Paris"}},
+				map[string]any{"web": map[string]any{"uri": "https://example.com/france", "title": "France"}},
+			},
+			"groundingSupports": []any{
+				map[string]any{
+					"segment":               map[string]any{"text": "Paris is the capital of France."},
+					"groundingChunkIndices": []any{0, 1},
+				},
+			},
+		}
+		result["urlContextMetadata"] = map[string]any{
+			"urlMetadata": []any{
+				map[string]any{"retrievedUrl": "https://example.com/paris", "urlRetrievalStatus": "URL_RETRIEVAL_STATUS_SUCCESS"},
+				map[string]any{"retrievedUrl": "https://example.com/read", "urlRetrievalStatus": "URL_RETRIEVAL_STATUS_SUCCESS"},
+				map[string]any{"retrievedUrl": "https://example.com/failed", "urlRetrievalStatus": "URL_RETRIEVAL_STATUS_ERROR"},
+			},
+		}
+
+		return http.StatusOK, response
+	})
+
+	answer, err := newGeminiClient(t).AskWithSources(context.Background(), "You are helpful", "What is the capital of France?")
+	require.NoError(t, err)
+	assert.Equal(t, "Paris is the capital of France.", answer.Text)
+
+	assert.Equal(t, []models.Reference{
+		{Title: "Paris", URL: "https://example.com/paris", Supports: []string{"Paris is the capital of France."}},
+		{Title: "France", URL: "https://example.com/france", Supports: []string{"Paris is the capital of France."}},
+		{Title: "https://example.com/read", URL: "https://example.com/read"},
+	}, answer.References)
+}
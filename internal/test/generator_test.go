@@ -9,6 +9,8 @@ import (
 
 func init() {
 	generators.MustRegister("test-generator", factory)
+	generators.MustRegister("test-assistant-generator", assistantFactory)
+	generators.MustRegister("test-attachment-generator", attachmentFactory)
 }
 
 func factory(generators.Config) (models.ContentGenerator, error) {
@@ -31,3 +33,51 @@ func (g *generator) Generate(ctx context.Context, request models.ContentRequest,
 		},
 	}, nil
 }
+
+func assistantFactory(generators.Config) (models.ContentGenerator, error) {
+	return &assistantGenerator{}, nil
+}
+
+// assistantGenerator writes its single section with the assistant, so tests
+// can observe how the processor drives assistant calls.
+type assistantGenerator struct{}
+
+func (g *assistantGenerator) Generate(ctx context.Context, request models.ContentRequest, assistant models.Assistant) (*models.Document, error) {
+	body, err := assistant.Ask(ctx, "Test persona", "Write a test paragraph")
+	if err != nil {
+		return nil, err
+	}
+
+	doc := &models.Document{
+		Title:  "Test Document",
+		Author: "Some person",
+	}
+
+	doc.AddSection("Test Section", *body)
+
+	return doc, nil
+}
+
+func attachmentFactory(generators.Config) (models.ContentGenerator, error) {
+	return &attachmentGenerator{}, nil
+}
+
+// attachmentGenerator summarises the request's attachments, so tests can
+// follow attachments from the request to the assistant.
+type attachmentGenerator struct{}
+
+func (g *attachmentGenerator) Generate(ctx context.Context, request models.ContentRequest, assistant models.Assistant) (*models.Document, error) {
+	body, err := assistant.Ask(models.WithAttachments(ctx, request.Attachments...), "Test persona", "Summarise the attachments")
+	if err != nil {
+		return nil, err
+	}
+
+	doc := &models.Document{
+		Title:  "Attachment Summary",
+		Author: "Some person",
+	}
+
+	doc.AddSection("Summary", *body)
+
+	return doc, nil
+}
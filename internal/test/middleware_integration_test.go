@@ -0,0 +1,237 @@
+package test
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/schraf/assistant/internal/job"
+	"github.com/schraf/assistant/internal/mocks"
+	"github.com/schraf/assistant/pkg/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingInterceptor notes the order in which middleware runs.
+func recordingInterceptor(name string, events *[]string) models.Middleware {
+	return models.Intercept(func(ctx context.Context, call *models.Call, next func(ctx context.Context) error) error {
+		*events = append(*events, name+" before "+call.Method)
+		err := next(ctx)
+		*events = append(*events, name+" after "+call.Response)
+		return err
+	})
+}
+
+// logEntries decodes the JSON log lines written to buf.
+func logEntries(t *testing.T, buf *bytes.Buffer) []map[string]any {
+	t.Helper()
+
+	var entries []map[string]any
+
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		if line == "" {
+			continue
+		}
+
+		var entry map[string]any
+		require.NoError(t, json.Unmarshal([]byte(line), &entry))
+		entries = append(entries, entry)
+	}
+
+	return entries
+}
+
+func TestChain_Order(t *testing.T) {
+	var events []string
+
+	assistant := models.Chain(&mocks.MockAssistant{},
+		recordingInterceptor("outer", &events),
+		recordingInterceptor("inner", &events),
+	)
+
+	_, err := assistant.Ask(context.Background(), "persona", "request")
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{
+		"outer before ask",
+		"inner before ask",
+		"inner after Mock response",
+		"outer after Mock response",
+	}, events)
+}
+
+func TestIntercept_Call(t *testing.T) {
+	var calls []models.Call
+
+	mockAssistant := &mocks.MockAssistant{
+		WithModelFunc: func(ctx context.Context, model string) context.Context {
+			return ctx
+		},
+	}
+
+	assistant := models.Chain(mockAssistant, models.Intercept(func(ctx context.Context, call *models.Call, next func(ctx context.Context) error) error {
+		err := next(ctx)
+		calls = append(calls, *call)
+		return err
+	}))
+
+	ctx := assistant.WithModel(context.Background(), models.TierCheap)
+
+	_, err := assistant.StructuredAsk(ctx, "persona", "request", articleSchema)
+	require.NoError(t, err)
+
+	_, err = assistant.Chat(ctx, "persona", []models.Message{{Role: models.RoleUser, Content: "Hello"}})
+	require.NoError(t, err)
+
+	require.Len(t, calls, 2)
+	assert.Equal(t, models.MethodStructuredAsk, calls[0].Method)
+	assert.Equal(t, models.TierCheap, calls[0].Model, "the model should be recorded")
+	assert.Equal(t, articleSchema, calls[0].Schema)
+	assert.NotEmpty(t, calls[0].Response)
+
+	assert.Equal(t, models.MethodChat, calls[1].Method)
+	assert.Len(t, calls[1].Messages, 1)
+}
+
+func TestIntercept_CanReplaceError(t *testing.T) {
+	errWrapped := errors.New("wrapped")
+
+	assistant := models.Chain(&mocks.MockAssistant{}, models.Intercept(func(ctx context.Context, call *models.Call, next func(ctx context.Context) error) error {
+		if err := next(ctx); err != nil {
+			return err
+		}
+		return errWrapped
+	}))
+
+	response, err := assistant.Ask(context.Background(), "persona", "request")
+	assert.ErrorIs(t, err, errWrapped)
+	assert.Nil(t, response)
+}
+
+func TestLogging(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, nil))
+
+	mockAssistant := &mocks.MockAssistant{
+		AskFunc: func(ctx context.Context, persona string, request string) (*string, error) {
+			if request == "blocked" {
+				return nil, &models.ContentBlockedError{FinishReason: "SAFETY"}
+			}
+			response := "Hello"
+			return &response, nil
+		},
+	}
+
+	assistant := models.Chain(mockAssistant, models.Logging(logger))
+	ctx := models.WithRequestID(context.Background(), "request-1")
+
+	_, err := assistant.Ask(ctx, "persona", "request")
+	require.NoError(t, err)
+
+	_, err = assistant.Ask(ctx, "persona", "blocked")
+	require.Error(t, err)
+
+	entries := logEntries(t, &buf)
+	require.Len(t, entries, 2)
+
+	assert.Equal(t, "assistant_call", entries[0]["msg"])
+	assert.Equal(t, "INFO", entries[0]["level"])
+	assert.Equal(t, "ask", entries[0]["method"])
+	assert.Equal(t, "request-1", entries[0]["request_id"])
+	assert.Equal(t, "ok", entries[0]["outcome"])
+	assert.Equal(t, float64(len("persona")+len("request")), entries[0]["request_chars"])
+	assert.Equal(t, float64(len("Hello")), entries[0]["response_chars"])
+	assert.Contains(t, entries[0], "latency")
+
+	assert.Equal(t, "WARN", entries[1]["level"])
+	assert.Equal(t, "content_blocked", entries[1]["outcome"])
+	assert.Contains(t, entries[1]["error"], "SAFETY")
+}
+
+func TestOutcome(t *testing.T) {
+	assert.Equal(t, "ok", models.Outcome(nil))
+	assert.Equal(t, "truncated", models.Outcome(&models.TruncatedError{}))
+	assert.Equal(t, "budget_exceeded", models.Outcome(models.ErrBudgetExceeded))
+	assert.Equal(t, "schema_invalid", models.Outcome(&models.SchemaValidationError{}))
+	assert.Equal(t, "canceled", models.Outcome(context.Canceled))
+	assert.Equal(t, "error", models.Outcome(errors.New("boom")))
+}
+
+func TestProcessor_LogsAssistantCalls(t *testing.T) {
+	requestID := uuid.New()
+	bodyJSON, _ := json.Marshal(map[string]any{"topic": "AI"})
+	configJSON, _ := json.Marshal(map[string]any{})
+
+	t.Setenv("REQUEST_ID", requestID.String())
+	t.Setenv("REQUEST_BODY", base64.StdEncoding.EncodeToString(bodyJSON))
+	t.Setenv("CONTENT_CONFIG", base64.StdEncoding.EncodeToString(configJSON))
+	t.Setenv("CONTENT_TYPE", "test-assistant-generator")
+
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, nil))
+
+	processor := job.NewProcessor("gemini", &mocks.MockAssistant{}, &mocks.MockPublisher{}, &mocks.MockNotifier{}, logger)
+	require.NoError(t, processor.Process(context.Background()))
+
+	var calls []map[string]any
+	for _, entry := range logEntries(t, &buf) {
+		if entry["msg"] == "assistant_call" {
+			calls = append(calls, entry)
+		}
+	}
+
+	require.Len(t, calls, 1)
+	assert.Equal(t, requestID.String(), calls[0]["request_id"])
+	assert.Equal(t, "ok", calls[0]["outcome"])
+}
+
+// defaultModelAssistant is a mock reporting the model it uses by default.
+type defaultModelAssistant struct {
+	*mocks.MockAssistant
+}
+
+func (a defaultModelAssistant) DefaultModel() string {
+	return "gemini-flash-latest"
+}
+
+func TestProcessor_LogsDefaultModel(t *testing.T) {
+	bodyJSON, _ := json.Marshal(map[string]any{"topic": "AI"})
+	configJSON, _ := json.Marshal(map[string]any{})
+
+	t.Setenv("REQUEST_ID", uuid.New().String())
+	t.Setenv("REQUEST_BODY", base64.StdEncoding.EncodeToString(bodyJSON))
+	t.Setenv("CONTENT_CONFIG", base64.StdEncoding.EncodeToString(configJSON))
+	t.Setenv("CONTENT_TYPE", "test-assistant-generator")
+
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, nil))
+
+	var selected string
+
+	assistant := defaultModelAssistant{&mocks.MockAssistant{
+		WithModelFunc: func(ctx context.Context, model string) context.Context {
+			selected = model
+			return ctx
+		},
+	}}
+
+	processor := job.NewProcessor("gemini", assistant, &mocks.MockPublisher{}, &mocks.MockNotifier{}, logger)
+	require.NoError(t, processor.Process(context.Background()))
+	assert.Equal(t, "gemini-flash-latest", selected)
+
+	var calls []map[string]any
+	for _, entry := range logEntries(t, &buf) {
+		if entry["msg"] == "assistant_call" {
+			calls = append(calls, entry)
+		}
+	}
+
+	require.Len(t, calls, 1)
+	assert.Equal(t, "gemini-flash-latest", calls[0]["model"], "calls without a configured model should report the provider's default")
+}
@@ -0,0 +1,188 @@
+package test
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/schraf/assistant/internal/job"
+	"github.com/schraf/assistant/internal/log"
+	"github.com/schraf/assistant/internal/mocks"
+	"github.com/schraf/assistant/internal/openai"
+	"github.com/schraf/assistant/pkg/models"
+	"github.com/schraf/assistant/pkg/providers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestModelTable_Resolve(t *testing.T) {
+	table := providers.DefaultModelTables["gemini"]
+
+	model, err := table.Resolve(models.TierPro)
+	require.NoError(t, err)
+	assert.Equal(t, "gemini-pro-latest", model)
+
+	model, err = table.Resolve("gemini-2.5-flash")
+	require.NoError(t, err, "listed models should be accepted")
+	assert.Equal(t, "gemini-2.5-flash", model)
+
+	model, err = table.Resolve("gemini-flash-latest")
+	require.NoError(t, err, "alias targets should be accepted")
+	assert.Equal(t, "gemini-flash-latest", model)
+
+	_, err = table.Resolve(models.TierLocal)
+	assert.Error(t, err, "gemini has no local tier")
+
+	model, err = providers.ModelTable{}.Resolve("anything")
+	require.NoError(t, err, "an empty table should accept any model")
+	assert.Equal(t, "anything", model)
+}
+
+func TestLoadModelTables_Inline(t *testing.T) {
+	t.Setenv("ASSISTANT_MODELS", `{"ollama": {"aliases": {"pro": "qwen3:32b"}, "models": ["mistral"]}}`)
+
+	tables, err := providers.LoadModelTables()
+	require.NoError(t, err)
+
+	ollama := tables["ollama"]
+	assert.Equal(t, "qwen3:32b", ollama.Lookup(models.TierPro), "overrides should replace aliases")
+	assert.Equal(t, "llama3.2", ollama.Lookup(models.TierCheap), "other aliases should be kept")
+
+	_, err = ollama.Resolve("mistral")
+	assert.NoError(t, err, "additional models should be accepted")
+
+	assert.Equal(t, "gemini-pro-latest", tables["gemini"].Lookup(models.TierPro), "other providers should be unchanged")
+	assert.Equal(t, "llama3.2", providers.DefaultModelTables["ollama"].Lookup(models.TierPro), "defaults should not be modified")
+}
+
+func TestLoadModelTables_File(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "models.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{"custom": {"aliases": {"basic": "custom-model"}}}`), 0o600))
+	t.Setenv("ASSISTANT_MODELS", path)
+
+	tables, err := providers.LoadModelTables()
+	require.NoError(t, err)
+	assert.Equal(t, "custom-model", tables["custom"].Lookup(models.TierBasic))
+}
+
+func TestWithModelTable_ResolvesTiers(t *testing.T) {
+	var selected string
+
+	mockAssistant := &mocks.MockAssistant{
+		WithModelFunc: func(ctx context.Context, model string) context.Context {
+			selected = model
+			return ctx
+		},
+	}
+
+	assistant := providers.WithModelTable(mockAssistant, providers.DefaultModelTables["openai"])
+
+	assistant.WithModel(context.Background(), models.TierCheap)
+	assert.Equal(t, "gpt-4o-mini", selected, "tiers should resolve through the table")
+
+	assistant.WithModel(context.Background(), "gpt-4.1")
+	assert.Equal(t, "gpt-4.1", selected, "unknown names should pass through")
+}
+
+func TestDefaultModel(t *testing.T) {
+	client, err := openai.NewClient(context.Background(), 1)
+	require.NoError(t, err)
+
+	assert.Equal(t, "gpt-4o-mini", providers.DefaultModel(client))
+	assert.Equal(t, "gpt-4o-mini", providers.DefaultModel(providers.WithModelTable(client, providers.DefaultModelTables["openai"])), "model tables should report the wrapped default")
+	assert.Empty(t, providers.DefaultModel(&mocks.MockAssistant{}))
+}
+
+func TestProcessor_Integration_UnknownModel(t *testing.T) {
+	bodyJSON, _ := json.Marshal(map[string]any{"topic": "AI"})
+	configJSON, _ := json.Marshal(map[string]any{"Model": "not-a-model"})
+
+	os.Setenv("REQUEST_ID", uuid.New().String())
+	os.Setenv("REQUEST_BODY", base64.StdEncoding.EncodeToString(bodyJSON))
+	os.Setenv("CONTENT_CONFIG", base64.StdEncoding.EncodeToString(configJSON))
+	os.Setenv("CONTENT_TYPE", "test-assistant-generator")
+	defer func() {
+		os.Unsetenv("REQUEST_ID")
+		os.Unsetenv("REQUEST_BODY")
+		os.Unsetenv("CONTENT_CONFIG")
+		os.Unsetenv("CONTENT_TYPE")
+	}()
+
+	generated := false
+
+	mockAssistant := &mocks.MockAssistant{
+		AskFunc: func(ctx context.Context, persona string, request string) (*string, error) {
+			generated = true
+			response := "Generated content"
+			return &response, nil
+		},
+	}
+
+	processor := job.NewProcessor("gemini", mockAssistant, &mocks.MockPublisher{}, &mocks.MockNotifier{}, log.NewLogger())
+
+	err := processor.Process(context.Background())
+	require.Error(t, err, "unknown models should be rejected")
+	assert.Contains(t, err.Error(), "unknown model")
+	assert.False(t, generated, "generation should not start with an unknown model")
+}
+
+func TestProcessor_Integration_ProviderModelTable(t *testing.T) {
+	bodyJSON, _ := json.Marshal(map[string]any{"topic": "AI"})
+	configJSON, _ := json.Marshal(map[string]any{"Model": "local"})
+
+	os.Setenv("REQUEST_ID", uuid.New().String())
+	os.Setenv("REQUEST_BODY", base64.StdEncoding.EncodeToString(bodyJSON))
+	os.Setenv("CONTENT_CONFIG", base64.StdEncoding.EncodeToString(configJSON))
+	os.Setenv("CONTENT_TYPE", "test-assistant-generator")
+	defer func() {
+		os.Unsetenv("REQUEST_ID")
+		os.Unsetenv("REQUEST_BODY")
+		os.Unsetenv("CONTENT_CONFIG")
+		os.Unsetenv("CONTENT_TYPE")
+	}()
+
+	var selected string
+
+	mockAssistant := &mocks.MockAssistant{
+		WithModelFunc: func(ctx context.Context, model string) context.Context {
+			selected = model
+			return ctx
+		},
+	}
+
+	processor := job.NewProcessor("ollama", mockAssistant, &mocks.MockPublisher{}, &mocks.MockNotifier{}, log.NewLogger())
+
+	require.NoError(t, processor.Process(context.Background()))
+	assert.Equal(t, "llama3.2", selected, "the provider's table should resolve the alias")
+}
+
+func TestProcessor_Integration_FailoverUnknownModel(t *testing.T) {
+	bodyJSON, _ := json.Marshal(map[string]any{"topic": "AI"})
+
+	t.Setenv("REQUEST_ID", uuid.New().String())
+	t.Setenv("REQUEST_BODY", base64.StdEncoding.EncodeToString(bodyJSON))
+	t.Setenv("CONTENT_TYPE", "test-assistant-generator")
+	t.Setenv("ASSISTANT_MODELS", `{"mock": {"aliases": {"pro": "mock-pro"}}}`)
+
+	// The pinned link accepts anything, the unpinned one only its table
+	assistant, err := providers.Create(context.Background(), "failover", providers.Config{"chain": "mock:pro,mock"})
+	require.NoError(t, err)
+
+	configJSON, _ := json.Marshal(map[string]any{"Model": "not-a-model"})
+	t.Setenv("CONTENT_CONFIG", base64.StdEncoding.EncodeToString(configJSON))
+
+	processor := job.NewProcessor("failover", assistant, &mocks.MockPublisher{}, &mocks.MockNotifier{}, log.NewLogger())
+
+	err = processor.Process(context.Background())
+	require.Error(t, err, "the failover links' tables should reject unknown models")
+	assert.Contains(t, err.Error(), "unknown model")
+
+	configJSON, _ = json.Marshal(map[string]any{"Model": "pro"})
+	t.Setenv("CONTENT_CONFIG", base64.StdEncoding.EncodeToString(configJSON))
+
+	require.NoError(t, processor.Process(context.Background()))
+}
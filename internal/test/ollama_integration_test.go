@@ -0,0 +1,265 @@
+package test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/schraf/assistant/internal/ollama"
+	"github.com/schraf/assistant/internal/retry"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newOllamaStreamServer(t *testing.T, chunks []string) {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/chat", r.URL.Path)
+
+		var body map[string]any
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		assert.Equal(t, true, body["stream"], "stream should be requested")
+
+		encoder := json.NewEncoder(w)
+		for _, chunk := range chunks {
+			encoder.Encode(map[string]any{
+				"message": map[string]any{"role": "assistant", "content": chunk},
+				"done":    false,
+			})
+		}
+		encoder.Encode(map[string]any{"message": map[string]any{"content": ""}, "done": true})
+	}))
+
+	t.Cleanup(server.Close)
+	t.Setenv("OLLAMA_BASE_URL", server.URL)
+}
+
+func TestOllamaClient_AskStream(t *testing.T) {
+	newOllamaStreamServer(t, []string{"The ", "quick ", "fox"})
+
+	client, err := ollama.NewClient(context.Background(), 1)
+	require.NoError(t, err)
+
+	var chunks []string
+
+	response, err := client.AskStream(context.Background(), "persona", "request", func(chunk string) error {
+		chunks = append(chunks, chunk)
+		return nil
+	})
+	require.NoError(t, err, "AskStream should succeed")
+
+	assert.Equal(t, []string{"The ", "quick ", "fox"}, chunks, "handler should receive each chunk")
+	assert.Equal(t, "The quick fox", *response, "final response should join the chunks")
+}
+
+func TestOllamaClient_AskStream_HandlerError(t *testing.T) {
+	newOllamaStreamServer(t, []string{"one", "two", "three"})
+
+	client, err := ollama.NewClient(context.Background(), 1)
+	require.NoError(t, err)
+
+	stalled := errors.New("stalled")
+	calls := 0
+
+	_, err = client.AskStream(context.Background(), "persona", "request", func(chunk string) error {
+		calls++
+		return stalled
+	})
+
+	assert.ErrorIs(t, err, stalled, "handler error should abort the stream")
+	assert.Equal(t, 1, calls, "no chunks should be delivered after the handler fails")
+}
+
+// newOllamaBrokenStreamServer streams "Part one" and then the given lines,
+// without a final object that has done set.
+func newOllamaBrokenStreamServer(t *testing.T, lines ...map[string]any) {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		encoder := json.NewEncoder(w)
+		encoder.Encode(map[string]any{
+			"message": map[string]any{"role": "assistant", "content": "Part one"},
+			"done":    false,
+		})
+		for _, line := range lines {
+			encoder.Encode(line)
+		}
+	}))
+
+	t.Cleanup(server.Close)
+	t.Setenv("OLLAMA_BASE_URL", server.URL)
+}
+
+func TestOllamaClient_AskStream_Error(t *testing.T) {
+	newOllamaBrokenStreamServer(t, map[string]any{"error": "model runner has unexpectedly stopped"})
+
+	client, err := ollama.NewClient(context.Background(), 1)
+	require.NoError(t, err)
+
+	response, err := client.AskStream(context.Background(), "persona", "request", func(chunk string) error { return nil })
+	require.Error(t, err, "an error in the stream should fail the call")
+	assert.Contains(t, err.Error(), "model runner has unexpectedly stopped")
+	assert.Nil(t, response)
+}
+
+func TestOllamaClient_AskStream_EndsEarly(t *testing.T) {
+	newOllamaBrokenStreamServer(t)
+
+	client, err := ollama.NewClient(context.Background(), 1)
+	require.NoError(t, err)
+
+	response, err := client.AskStream(context.Background(), "persona", "request", func(chunk string) error { return nil })
+	require.ErrorIs(t, err, io.ErrUnexpectedEOF, "a stream without done should not be a response")
+	assert.Nil(t, response)
+}
+
+func ollamaReply(w http.ResponseWriter, content string) {
+	json.NewEncoder(w).Encode(map[string]any{
+		"message": map[string]any{"role": "assistant", "content": content},
+		"done":    true,
+	})
+}
+
+func TestOllamaClient_RetriesModelLoading(t *testing.T) {
+	var calls atomic.Int32
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			json.NewEncoder(w).Encode(map[string]any{"error": "loading model"})
+			return
+		}
+
+		ollamaReply(w, "Hello")
+	}))
+	defer server.Close()
+
+	t.Setenv("OLLAMA_BASE_URL", server.URL)
+
+	client, err := ollama.NewClient(context.Background(), 1)
+	require.NoError(t, err)
+
+	response, err := client.Ask(context.Background(), "persona", "request")
+	require.NoError(t, err, "the request should be retried")
+	assert.Equal(t, "Hello", *response)
+	assert.Equal(t, int32(2), calls.Load())
+}
+
+func TestOllamaClient_RetriesConnectionErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	server.Close()
+
+	t.Setenv("OLLAMA_BASE_URL", server.URL)
+	t.Setenv("OLLAMA_MAX_RETRIES", "1")
+
+	client, err := ollama.NewClient(context.Background(), 1)
+	require.NoError(t, err)
+
+	var retries int
+	ctx := retry.WithHooks(context.Background(), &retry.Hooks{
+		OnRetry: func(attempt int, err error, backoff time.Duration) {
+			retries++
+		},
+	})
+
+	_, err = client.Ask(ctx, "persona", "request")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to send request")
+	assert.Equal(t, 1, retries, "the request should be retried")
+}
+
+func TestOllamaClient_DoesNotRetryClientErrors(t *testing.T) {
+	var calls atomic.Int32
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]any{"error": "model not found"})
+	}))
+	defer server.Close()
+
+	t.Setenv("OLLAMA_BASE_URL", server.URL)
+
+	client, err := ollama.NewClient(context.Background(), 1)
+	require.NoError(t, err)
+
+	_, err = client.Ask(context.Background(), "persona", "request")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "ollama API returned status 404")
+	assert.Equal(t, int32(1), calls.Load())
+}
+
+func TestOllamaClient_Timeout(t *testing.T) {
+	var calls atomic.Int32
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		time.Sleep(200 * time.Millisecond)
+		ollamaReply(w, "Too late")
+	}))
+	defer server.Close()
+
+	t.Setenv("OLLAMA_BASE_URL", server.URL)
+	t.Setenv("OLLAMA_TIMEOUT", "50ms")
+
+	client, err := ollama.NewClient(context.Background(), 1)
+	require.NoError(t, err)
+
+	_, err = client.Ask(context.Background(), "persona", "request")
+	require.Error(t, err)
+	assert.Equal(t, int32(1), calls.Load(), "timeouts should not be retried")
+}
+
+func TestOllamaClient_InvalidConfig(t *testing.T) {
+	t.Setenv("OLLAMA_TIMEOUT", "soon")
+
+	_, err := ollama.NewClient(context.Background(), 1)
+	assert.ErrorContains(t, err, "invalid OLLAMA_TIMEOUT")
+}
+
+func TestOllamaClient_Concurrency(t *testing.T) {
+	var inFlight, maxInFlight atomic.Int32
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		current := inFlight.Add(1)
+		defer inFlight.Add(-1)
+
+		for {
+			observed := maxInFlight.Load()
+			if current <= observed || maxInFlight.CompareAndSwap(observed, current) {
+				break
+			}
+		}
+
+		time.Sleep(20 * time.Millisecond)
+		ollamaReply(w, "Hello")
+	}))
+	defer server.Close()
+
+	t.Setenv("OLLAMA_BASE_URL", server.URL)
+
+	client, err := ollama.NewClient(context.Background(), 2)
+	require.NoError(t, err)
+
+	var wg sync.WaitGroup
+
+	for range 6 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := client.Ask(context.Background(), "persona", "request")
+			assert.NoError(t, err)
+		}()
+	}
+
+	wg.Wait()
+	assert.Equal(t, int32(2), maxInFlight.Load(), "requests should be limited to the concurrency")
+}
@@ -0,0 +1,246 @@
+package test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/schraf/assistant/internal/openai"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newOpenAIServer(t *testing.T, handler func(body map[string]any) (int, any)) *httptest.Server {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/chat/completions", r.URL.Path, "request should target chat completions")
+		assert.Equal(t, "Bearer test-key", r.Header.Get("Authorization"), "request should carry the API key")
+
+		var body map[string]any
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body), "request body should be JSON")
+
+		status, response := handler(body)
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(response)
+	}))
+
+	t.Cleanup(server.Close)
+	t.Setenv("OPENAI_BASE_URL", server.URL+"/v1")
+	t.Setenv("OPENAI_API_KEY", "test-key")
+
+	return server
+}
+
+func completion(content string) map[string]any {
+	return map[string]any{
+		"choices": []any{
+			map[string]any{
+				"message":       map[string]any{"role": "assistant", "content": content},
+				"finish_reason": "stop",
+			},
+		},
+	}
+}
+
+func TestOpenAIClient_Ask(t *testing.T) {
+	var captured map[string]any
+
+	newOpenAIServer(t, func(body map[string]any) (int, any) {
+		captured = body
+		return http.StatusOK, completion("  Hello there  ")
+	})
+
+	client, err := openai.NewClient(context.Background(), 1)
+	require.NoError(t, err)
+
+	ctx := client.WithModel(context.Background(), "local-model")
+
+	response, err := client.Ask(ctx, "You are helpful", "Say hello")
+	require.NoError(t, err, "Ask should succeed")
+	assert.Equal(t, "Hello there", *response, "response should be trimmed")
+
+	assert.Equal(t, "local-model", captured["model"], "model should come from context")
+	assert.Nil(t, captured["response_format"], "Ask should not request a response format")
+
+	messages := captured["messages"].([]any)
+	require.Len(t, messages, 2)
+	assert.Equal(t, "system", messages[0].(map[string]any)["role"])
+	assert.Equal(t, "You are helpful", messages[0].(map[string]any)["content"])
+	assert.Equal(t, "user", messages[1].(map[string]any)["role"])
+	assert.Equal(t, "Say hello", messages[1].(map[string]any)["content"])
+}
+
+func TestOpenAIClient_StructuredAsk(t *testing.T) {
+	var captured map[string]any
+
+	newOpenAIServer(t, func(body map[string]any) (int, any) {
+		captured = body
+		return http.StatusOK, completion(`{"title": "A Title"}`)
+	})
+
+	client, err := openai.NewClient(context.Background(), 1)
+	require.NoError(t, err)
+
+	schema := map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"title": map[string]any{"type": "string"},
+		},
+	}
+
+	response, err := client.StructuredAsk(context.Background(), "persona", "request", schema)
+	require.NoError(t, err, "StructuredAsk should succeed")
+	assert.JSONEq(t, `{"title": "A Title"}`, string(response))
+
+	format := captured["response_format"].(map[string]any)
+	assert.Equal(t, "json_schema", format["type"])
+	assert.Equal(t, schema["type"], format["json_schema"].(map[string]any)["schema"].(map[string]any)["type"])
+}
+
+func TestOpenAIClient_StructuredAsk_InvalidJSON(t *testing.T) {
+	newOpenAIServer(t, func(body map[string]any) (int, any) {
+		return http.StatusOK, completion("not json")
+	})
+
+	client, err := openai.NewClient(context.Background(), 1)
+	require.NoError(t, err)
+
+	_, err = client.StructuredAsk(context.Background(), "persona", "request", map[string]any{"type": "object"})
+	require.Error(t, err, "StructuredAsk should fail on invalid JSON")
+}
+
+func TestOpenAIClient_ErrorStatus(t *testing.T) {
+	newOpenAIServer(t, func(body map[string]any) (int, any) {
+		return http.StatusInternalServerError, map[string]any{"error": map[string]any{"message": "boom"}}
+	})
+
+	t.Setenv("OPENAI_MAX_RETRIES", "0")
+
+	client, err := openai.NewClient(context.Background(), 1)
+	require.NoError(t, err)
+
+	_, err = client.Ask(context.Background(), "persona", "request")
+	require.Error(t, err, "Ask should fail on non-200 status")
+	assert.Contains(t, err.Error(), "status 500")
+}
+
+func TestOpenAIClient_AskStream(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]any
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		assert.Equal(t, true, body["stream"], "stream should be requested")
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, chunk := range []string{"Hel", "lo ", "world"} {
+			data, _ := json.Marshal(map[string]any{
+				"choices": []any{map[string]any{"delta": map[string]any{"content": chunk}}},
+			})
+			w.Write([]byte("data: " + string(data) + "\n\n"))
+		}
+		w.Write([]byte("data: [DONE]\n\n"))
+	}))
+	defer server.Close()
+
+	t.Setenv("OPENAI_BASE_URL", server.URL)
+	t.Setenv("OPENAI_API_KEY", "")
+
+	client, err := openai.NewClient(context.Background(), 1)
+	require.NoError(t, err)
+
+	var chunks []string
+
+	response, err := client.AskStream(context.Background(), "persona", "request", func(chunk string) error {
+		chunks = append(chunks, chunk)
+		return nil
+	})
+	require.NoError(t, err, "AskStream should succeed")
+
+	assert.Equal(t, []string{"Hel", "lo ", "world"}, chunks, "handler should receive each chunk")
+	assert.Equal(t, "Hello world", *response, "final response should join the chunks")
+}
+
+// newOpenAIBrokenStreamServer streams "Part one" and then the given events,
+// without a finish reason or a [DONE] event.
+func newOpenAIBrokenStreamServer(t *testing.T, events ...string) {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Write([]byte(`data: {"choices":[{"delta":{"content":"Part one"}}]}` + "\n\n"))
+		for _, event := range events {
+			w.Write([]byte("data: " + event + "\n\n"))
+		}
+	}))
+
+	t.Cleanup(server.Close)
+	t.Setenv("OPENAI_BASE_URL", server.URL)
+	t.Setenv("OPENAI_API_KEY", "")
+}
+
+func TestOpenAIClient_AskStream_Error(t *testing.T) {
+	newOpenAIBrokenStreamServer(t, `{"error":{"message":"boom"}}`)
+
+	client, err := openai.NewClient(context.Background(), 1)
+	require.NoError(t, err)
+
+	response, err := client.AskStream(context.Background(), "persona", "request", func(chunk string) error { return nil })
+	require.Error(t, err, "an error in the stream should fail the call")
+	assert.Contains(t, err.Error(), "boom")
+	assert.Nil(t, response)
+}
+
+func TestOpenAIClient_AskStream_EndsEarly(t *testing.T) {
+	newOpenAIBrokenStreamServer(t)
+
+	client, err := openai.NewClient(context.Background(), 1)
+	require.NoError(t, err)
+
+	response, err := client.AskStream(context.Background(), "persona", "request", func(chunk string) error { return nil })
+	require.ErrorIs(t, err, io.ErrUnexpectedEOF, "a stream without [DONE] or a finish reason should not be a response")
+	assert.Nil(t, response)
+}
+
+func TestOpenAIClient_Concurrency(t *testing.T) {
+	var inFlight, maxInFlight atomic.Int32
+
+	newOpenAIServer(t, func(body map[string]any) (int, any) {
+		current := inFlight.Add(1)
+		defer inFlight.Add(-1)
+
+		for {
+			observed := maxInFlight.Load()
+			if current <= observed || maxInFlight.CompareAndSwap(observed, current) {
+				break
+			}
+		}
+
+		time.Sleep(20 * time.Millisecond)
+		return http.StatusOK, completion("Hello")
+	})
+
+	client, err := openai.NewClient(context.Background(), 2)
+	require.NoError(t, err)
+
+	var wg sync.WaitGroup
+
+	for range 6 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := client.Ask(context.Background(), "persona", "request")
+			assert.NoError(t, err)
+		}()
+	}
+
+	wg.Wait()
+	assert.Equal(t, int32(2), maxInFlight.Load(), "requests should be limited to the concurrency")
+}
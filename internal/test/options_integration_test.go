@@ -0,0 +1,147 @@
+package test
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/schraf/assistant/internal/job"
+	"github.com/schraf/assistant/internal/log"
+	"github.com/schraf/assistant/internal/mocks"
+	"github.com/schraf/assistant/internal/ollama"
+	"github.com/schraf/assistant/internal/openai"
+	"github.com/schraf/assistant/pkg/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerationOptions_Merge(t *testing.T) {
+	ctx := models.WithTemperature(context.Background(), 0.2)
+	ctx = models.WithSeed(ctx, 7)
+	ctx = models.WithTemperature(ctx, 0.9)
+
+	options := models.GenerationOptionsFromContext(ctx)
+	require.NotNil(t, options.Temperature)
+	require.NotNil(t, options.Seed)
+	assert.Equal(t, 0.9, *options.Temperature, "later options should override earlier ones")
+	assert.Equal(t, 7, *options.Seed, "unrelated options should be kept")
+	assert.Nil(t, options.TopP, "unset options should stay nil")
+}
+
+func TestOllamaClient_GenerationOptions(t *testing.T) {
+	var captured map[string]any
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&captured))
+		json.NewEncoder(w).Encode(map[string]any{"message": map[string]any{"content": "ok"}, "done": true})
+	}))
+	defer server.Close()
+
+	t.Setenv("OLLAMA_BASE_URL", server.URL)
+
+	client, err := ollama.NewClient(context.Background(), 1)
+	require.NoError(t, err)
+
+	ctx := models.WithTemperature(context.Background(), 0.1)
+	ctx = models.WithMaxOutputTokens(ctx, 256)
+	ctx = models.WithStopSequences(ctx, "END")
+	ctx = models.WithThinkingBudget(ctx, 0)
+
+	_, err = client.Ask(ctx, "persona", "request")
+	require.NoError(t, err)
+
+	options := captured["options"].(map[string]any)
+	assert.Equal(t, 0.1, options["temperature"])
+	assert.Equal(t, float64(256), options["num_predict"])
+	assert.Equal(t, []any{"END"}, options["stop"])
+	assert.Nil(t, options["seed"], "unset options should be omitted")
+	assert.Equal(t, false, captured["think"], "a zero thinking budget should disable thinking")
+}
+
+func TestOllamaClient_NoGenerationOptions(t *testing.T) {
+	var captured map[string]any
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&captured))
+		json.NewEncoder(w).Encode(map[string]any{"message": map[string]any{"content": "ok"}, "done": true})
+	}))
+	defer server.Close()
+
+	t.Setenv("OLLAMA_BASE_URL", server.URL)
+
+	client, err := ollama.NewClient(context.Background(), 1)
+	require.NoError(t, err)
+
+	_, err = client.Ask(context.Background(), "persona", "request")
+	require.NoError(t, err)
+
+	assert.NotContains(t, captured, "options", "options should be omitted when none are set")
+	assert.NotContains(t, captured, "think")
+}
+
+func TestOpenAIClient_GenerationOptions(t *testing.T) {
+	var captured map[string]any
+
+	newOpenAIServer(t, func(body map[string]any) (int, any) {
+		captured = body
+		return http.StatusOK, completion("ok")
+	})
+
+	client, err := openai.NewClient(context.Background(), 1)
+	require.NoError(t, err)
+
+	ctx := models.WithTopP(context.Background(), 0.5)
+	ctx = models.WithSeed(ctx, 42)
+
+	_, err = client.Ask(ctx, "persona", "request")
+	require.NoError(t, err)
+
+	assert.Equal(t, 0.5, captured["top_p"])
+	assert.Equal(t, float64(42), captured["seed"])
+	assert.NotContains(t, captured, "temperature", "unset options should be omitted")
+}
+
+func TestProcessor_Integration_GenerationOptions(t *testing.T) {
+	bodyJSON, _ := json.Marshal(map[string]any{"topic": "AI"})
+	configJSON, _ := json.Marshal(map[string]any{
+		"Temperature":       "0.3",
+		"Max-Output-Tokens": "1024",
+		"Stop-Sequences":    []string{"###", "END"},
+	})
+
+	os.Setenv("REQUEST_ID", uuid.New().String())
+	os.Setenv("REQUEST_BODY", base64.StdEncoding.EncodeToString(bodyJSON))
+	os.Setenv("CONTENT_CONFIG", base64.StdEncoding.EncodeToString(configJSON))
+	os.Setenv("CONTENT_TYPE", "test-assistant-generator")
+	defer func() {
+		os.Unsetenv("REQUEST_ID")
+		os.Unsetenv("REQUEST_BODY")
+		os.Unsetenv("CONTENT_CONFIG")
+		os.Unsetenv("CONTENT_TYPE")
+	}()
+
+	var options models.GenerationOptions
+
+	mockAssistant := &mocks.MockAssistant{
+		AskFunc: func(ctx context.Context, persona string, request string) (*string, error) {
+			options = models.GenerationOptionsFromContext(ctx)
+			response := "Generated content"
+			return &response, nil
+		},
+	}
+
+	processor := job.NewProcessor("gemini", mockAssistant, &mocks.MockPublisher{}, &mocks.MockNotifier{}, log.NewLogger())
+	require.NoError(t, processor.Process(context.Background()))
+
+	require.NotNil(t, options.Temperature)
+	require.NotNil(t, options.MaxOutputTokens)
+	assert.Equal(t, 0.3, *options.Temperature)
+	assert.Equal(t, 1024, *options.MaxOutputTokens)
+	assert.Equal(t, []string{"###", "END"}, options.StopSequences)
+	assert.Nil(t, options.Seed)
+}
@@ -68,14 +68,14 @@ func TestProcessor_Integration(t *testing.T) {
 	var notifiedTitle string
 
 	mockNotifier := &mocks.MockNotifier{
-		SendPublishedURLNotificationFunc: func(publishedURL *url.URL, title string) error {
+		SendPublishedURLNotificationFunc: func(publishedURL *url.URL, title string, details string) error {
 			notifiedURL = publishedURL
 			notifiedTitle = title
 			return nil
 		},
 	}
 
-	processor := job.NewProcessor(mockAssistant, mockPublisher, mockNotifier, log.NewLogger())
+	processor := job.NewProcessor("gemini", mockAssistant, mockPublisher, mockNotifier, log.NewLogger())
 
 	ctx := context.Background()
 	err := processor.Process(ctx)
@@ -98,7 +98,7 @@ func TestProcessor_Integration_MissingRequestID(t *testing.T) {
 	mockPublisher := &mocks.MockPublisher{}
 	mockNotifier := &mocks.MockNotifier{}
 
-	processor := job.NewProcessor(mockAssistant, mockPublisher, mockNotifier, log.NewLogger())
+	processor := job.NewProcessor("gemini", mockAssistant, mockPublisher, mockNotifier, log.NewLogger())
 
 	ctx := context.Background()
 	err := processor.Process(ctx)
@@ -119,7 +119,7 @@ func TestProcessor_Integration_MissingRequestBody(t *testing.T) {
 	mockPublisher := &mocks.MockPublisher{}
 	mockNotifier := &mocks.MockNotifier{}
 
-	processor := job.NewProcessor(mockAssistant, mockPublisher, mockNotifier, log.NewLogger())
+	processor := job.NewProcessor("gemini", mockAssistant, mockPublisher, mockNotifier, log.NewLogger())
 
 	ctx := context.Background()
 	err := processor.Process(ctx)
@@ -163,7 +163,7 @@ func TestProcessor_Integration_PublisherError(t *testing.T) {
 
 	mockNotifier := &mocks.MockNotifier{}
 
-	processor := job.NewProcessor(mockAssistant, mockPublisher, mockNotifier, log.NewLogger())
+	processor := job.NewProcessor("gemini", mockAssistant, mockPublisher, mockNotifier, log.NewLogger())
 
 	ctx := context.Background()
 	err := processor.Process(ctx)
@@ -0,0 +1,76 @@
+package test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/schraf/assistant/internal/mocks"
+	"github.com/schraf/assistant/pkg/models"
+	"github.com/schraf/assistant/pkg/providers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProviders_Create(t *testing.T) {
+	assistant, err := providers.Create(context.Background(), "mock", providers.Config{})
+	require.NoError(t, err, "mock provider should be registered")
+	assert.IsType(t, &mocks.MockAssistant{}, assistant)
+}
+
+func TestProviders_CreateUnknown(t *testing.T) {
+	_, err := providers.Create(context.Background(), "does-not-exist", providers.Config{})
+	require.Error(t, err, "unknown providers should fail")
+	assert.Contains(t, err.Error(), "unknown assistant provider")
+}
+
+func TestProviders_RegisterDuplicate(t *testing.T) {
+	factory := func(ctx context.Context, config providers.Config) (models.Assistant, error) {
+		return &mocks.MockAssistant{}, nil
+	}
+
+	require.NoError(t, providers.Register("test-duplicate", factory))
+	assert.Error(t, providers.Register("test-duplicate", factory), "duplicate registration should fail")
+}
+
+func TestProviders_FromEnv(t *testing.T) {
+	t.Setenv("ASSISTANT_PROVIDER", "Ollama")
+	t.Setenv("ASSISTANT_CONCURRENCY", "5")
+
+	name, config := providers.FromEnv()
+	assert.Equal(t, "ollama", name)
+
+	concurrency, err := config.Int("concurrency", 1)
+	require.NoError(t, err)
+	assert.Equal(t, 5, concurrency)
+}
+
+func TestProviders_FromEnvDefaults(t *testing.T) {
+	t.Setenv("ASSISTANT_PROVIDER", "")
+	t.Setenv("ASSISTANT_CONCURRENCY", "")
+
+	name, config := providers.FromEnv()
+	assert.Equal(t, providers.DefaultProvider, name)
+
+	concurrency, err := config.Int("concurrency", 3)
+	require.NoError(t, err)
+	assert.Equal(t, 3, concurrency)
+}
+
+func TestProviders_ConfigInt(t *testing.T) {
+	config := providers.Config{
+		"number": float64(4),
+		"string": "7",
+		"bad":    "seven",
+	}
+
+	n, err := config.Int("number", 0)
+	require.NoError(t, err)
+	assert.Equal(t, 4, n)
+
+	n, err = config.Int("string", 0)
+	require.NoError(t, err)
+	assert.Equal(t, 7, n)
+
+	_, err = config.Int("bad", 0)
+	assert.Error(t, err)
+}
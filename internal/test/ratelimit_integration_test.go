@@ -0,0 +1,42 @@
+package test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/schraf/assistant/internal/openai"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOpenAIClient_RateLimited(t *testing.T) {
+	calls := 0
+
+	newOpenAIServer(t, func(body map[string]any) (int, any) {
+		calls++
+		return http.StatusOK, completion("Hello")
+	})
+	t.Setenv("OPENAI_REQUESTS_PER_MINUTE", "1")
+
+	client, err := openai.NewClient(context.Background(), 1)
+	require.NoError(t, err)
+
+	_, err = client.Ask(context.Background(), "persona", "request")
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	_, err = client.Ask(ctx, "persona", "request")
+	assert.ErrorIs(t, err, context.DeadlineExceeded, "the second request should wait for the quota")
+	assert.Equal(t, 1, calls)
+}
+
+func TestOpenAIClient_InvalidRateLimit(t *testing.T) {
+	t.Setenv("OPENAI_TOKENS_PER_MINUTE", "many")
+
+	_, err := openai.NewClient(context.Background(), 1)
+	assert.ErrorContains(t, err, "invalid OPENAI_TOKENS_PER_MINUTE")
+}
@@ -0,0 +1,197 @@
+package test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/schraf/assistant/internal/mocks"
+	"github.com/schraf/assistant/internal/structured"
+	"github.com/schraf/assistant/pkg/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type outlineMeta struct {
+	Created time.Time `json:"created"`
+}
+
+type outlineSection struct {
+	Heading string   `json:"heading" jsonschema:"description=Short section heading"`
+	Points  []string `json:"points" jsonschema:"minItems=1,maxItems=5"`
+	Words   int      `json:"words,omitempty"`
+}
+
+type outline struct {
+	outlineMeta
+	Title    string           `json:"title"`
+	Tone     string           `json:"tone" jsonschema:"enum=formal|casual"`
+	Level    int              `json:"level" jsonschema:"enum=1|2|3"`
+	Tags     []string         `json:"tags,omitempty" jsonschema:"enum=news|opinion,required"`
+	Summary  *string          `json:"summary" jsonschema:"optional"`
+	Sections []outlineSection `json:"sections"`
+	Related  *outline         `json:"related,omitempty"`
+	Extra    map[string]int   `json:"extra,omitempty"`
+	Ignored  string           `json:"-"`
+	internal string
+}
+
+func TestSchemaFor(t *testing.T) {
+	schema := models.SchemaFor[outline]()
+
+	expected := map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"created": map[string]any{"type": "string", "format": "date-time"},
+			"title":   map[string]any{"type": "string"},
+			"tone":    map[string]any{"type": "string", "enum": []any{"formal", "casual"}},
+			"level":   map[string]any{"type": "integer", "enum": []any{1.0, 2.0, 3.0}},
+			"tags": map[string]any{
+				"type":  "array",
+				"items": map[string]any{"type": "string", "enum": []any{"news", "opinion"}},
+			},
+			"summary": map[string]any{"type": []string{"string", "null"}},
+			"sections": map[string]any{
+				"type": "array",
+				"items": map[string]any{
+					"type": "object",
+					"properties": map[string]any{
+						"heading": map[string]any{"type": "string", "description": "Short section heading"},
+						"points":  map[string]any{"type": "array", "items": map[string]any{"type": "string"}, "minItems": 1, "maxItems": 5},
+						"words":   map[string]any{"type": "integer"},
+					},
+					"required": []string{"heading", "points"},
+				},
+			},
+			"related": map[string]any{"type": []string{"object", "null"}},
+			"extra":   map[string]any{"type": "object"},
+		},
+		"required": []string{"created", "title", "tone", "level", "tags", "sections"},
+	}
+
+	assert.Equal(t, expected, schema)
+}
+
+func TestSchemaFor_ValidatesOwnValues(t *testing.T) {
+	// A value of the type should always satisfy its own schema
+	summary := "A summary"
+	value := outline{
+		Title:    "Title",
+		Tone:     "casual",
+		Level:    2,
+		Tags:     []string{"news"},
+		Summary:  &summary,
+		Sections: []outlineSection{{Heading: "H", Points: []string{"p"}}},
+	}
+
+	data, err := json.Marshal(value)
+	require.NoError(t, err)
+
+	assert.Empty(t, structured.Validate(models.SchemaFor[outline](), decode(t, string(data))))
+}
+
+type tagged struct {
+	ID      uuid.UUID   `json:"id"`
+	Parent  *uuid.UUID  `json:"parent"`
+	Related []uuid.UUID `json:"related"`
+}
+
+func TestSchemaFor_TextMarshaler(t *testing.T) {
+	schema := models.SchemaFor[tagged]()
+
+	properties := schema["properties"].(map[string]any)
+	assert.Equal(t, map[string]any{"type": "string"}, properties["id"], "text marshalers should be strings")
+	assert.Equal(t, map[string]any{"type": []string{"string", "null"}}, properties["parent"])
+	assert.Equal(t, map[string]any{"type": "array", "items": map[string]any{"type": "string"}}, properties["related"])
+
+	parent := uuid.New()
+	data, err := json.Marshal(tagged{ID: uuid.New(), Parent: &parent, Related: []uuid.UUID{uuid.New()}})
+	require.NoError(t, err)
+
+	assert.Empty(t, structured.Validate(schema, decode(t, string(data))))
+}
+
+type payload struct {
+	Name     string  `json:"name"`
+	Data     []byte  `json:"data"`
+	Count    *int    `json:"count"`
+	Kind     *string `json:"kind" jsonschema:"enum=a|b"`
+	Note     *string `json:"note,omitempty"`
+	Children []int   `json:"children"`
+}
+
+func TestSchemaFor_RoundTrip(t *testing.T) {
+	schema := models.SchemaFor[payload]()
+
+	properties := schema["properties"].(map[string]any)
+	assert.Equal(t, map[string]any{"type": "string", "contentEncoding": "base64"}, properties["data"], "byte slices should be base64 strings")
+	assert.Equal(t, map[string]any{"type": []string{"integer", "null"}}, properties["count"], "pointers should be nullable")
+	assert.Equal(t, map[string]any{"type": []string{"string", "null"}, "enum": []any{"a", "b", nil}}, properties["kind"])
+
+	// Marshalled values of the type, nil pointers included, should satisfy
+	// its own schema
+	count := 3
+	kind := "b"
+
+	for _, value := range []payload{
+		{Name: "empty", Data: []byte{}, Children: []int{}},
+		{Name: "full", Data: []byte("bytes"), Count: &count, Kind: &kind, Children: []int{1}},
+	} {
+		data, err := json.Marshal(value)
+		require.NoError(t, err)
+
+		assert.Empty(t, structured.Validate(schema, decode(t, string(data))), "%s should validate", value.Name)
+	}
+}
+
+type node struct {
+	*node
+	Value int `json:"value"`
+}
+
+func TestSchemaFor_SelfEmbedding(t *testing.T) {
+	schema := models.SchemaFor[node]()
+
+	assert.Equal(t, map[string]any{
+		"type":       "object",
+		"properties": map[string]any{"value": map[string]any{"type": "integer"}},
+		"required":   []string{"value"},
+	}, schema)
+}
+
+func TestStructuredAskAs(t *testing.T) {
+	var captured map[string]any
+
+	assistant := &mocks.MockAssistant{
+		StructuredAskFunc: func(ctx context.Context, persona string, request string, schema map[string]any) (json.RawMessage, error) {
+			captured = schema
+			return json.RawMessage(`{"heading":"Intro","points":["a","b"],"words":120}`), nil
+		},
+	}
+
+	section, err := models.StructuredAskAs[outlineSection](context.Background(), assistant, "persona", "request")
+	require.NoError(t, err)
+	assert.Equal(t, &outlineSection{Heading: "Intro", Points: []string{"a", "b"}, Words: 120}, section)
+	assert.Equal(t, models.SchemaFor[outlineSection](), captured, "the derived schema should be sent")
+}
+
+func TestStructuredAskAs_Errors(t *testing.T) {
+	assistant := &mocks.MockAssistant{
+		StructuredAskFunc: func(ctx context.Context, persona string, request string, schema map[string]any) (json.RawMessage, error) {
+			return nil, errors.New("failed")
+		},
+	}
+
+	_, err := models.StructuredAskAs[outlineSection](context.Background(), assistant, "persona", "request")
+	assert.EqualError(t, err, "failed")
+
+	assistant.StructuredAskFunc = func(ctx context.Context, persona string, request string, schema map[string]any) (json.RawMessage, error) {
+		return json.RawMessage(`{"heading":1}`), nil
+	}
+
+	_, err = models.StructuredAskAs[outlineSection](context.Background(), assistant, "persona", "request")
+	assert.ErrorContains(t, err, "failed to decode structured response")
+}
@@ -0,0 +1,215 @@
+package test
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/schraf/assistant/internal/job"
+	"github.com/schraf/assistant/internal/log"
+	"github.com/schraf/assistant/internal/mocks"
+	"github.com/schraf/assistant/internal/transcript"
+	"github.com/schraf/assistant/pkg/cassette"
+	"github.com/schraf/assistant/pkg/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordJob runs the test-assistant-generator job with a transcript and
+// returns the transcript's path.
+func recordJob(t *testing.T, assistant models.Assistant) string {
+	dir := t.TempDir()
+	requestID := uuid.New()
+	bodyJSON, _ := json.Marshal(map[string]any{"topic": "AI"})
+	configJSON, _ := json.Marshal(map[string]any{"model": "pro", "temperature": 0.5})
+
+	t.Setenv("REQUEST_ID", requestID.String())
+	t.Setenv("REQUEST_BODY", base64.StdEncoding.EncodeToString(bodyJSON))
+	t.Setenv("CONTENT_CONFIG", base64.StdEncoding.EncodeToString(configJSON))
+	t.Setenv("CONTENT_TYPE", "test-assistant-generator")
+	t.Setenv("TRANSCRIPT_DIR", dir)
+
+	processor := job.NewProcessor("gemini", assistant, &mocks.MockPublisher{}, &mocks.MockNotifier{}, log.NewLogger())
+	require.NoError(t, processor.Process(context.Background()))
+
+	return filepath.Join(dir, requestID.String()+".jsonl")
+}
+
+func TestReplay_ReproducesJob(t *testing.T) {
+	path := recordJob(t, &mocks.MockAssistant{
+		AskFunc: func(ctx context.Context, persona string, request string) (*string, error) {
+			response := "The recorded paragraph has two sentences. That keeps it through cleaning."
+			return &response, nil
+		},
+	})
+
+	recorded, err := job.LoadReplay(path)
+	require.NoError(t, err)
+	require.Len(t, recorded.Calls, 1)
+	require.NotNil(t, recorded.Document)
+	assert.Equal(t, "gemini", recorded.Request.Provider)
+
+	// Clobber the environment so the replay has to restore it
+	t.Setenv("REQUEST_ID", uuid.New().String())
+	t.Setenv("CONTENT_TYPE", "test-generator")
+	require.NoError(t, recorded.SetEnv())
+	assert.Equal(t, recorded.Request.RequestID, os.Getenv("REQUEST_ID"))
+	assert.Equal(t, "test-assistant-generator", os.Getenv("CONTENT_TYPE"))
+	assert.Equal(t, "gemini", recorded.Provider())
+	assert.Empty(t, os.Getenv("TRANSCRIPT_DIR"))
+
+	assistant, err := recorded.Assistant(cassette.MatchRequest)
+	require.NoError(t, err)
+
+	var out bytes.Buffer
+	publisher := job.NewReplayPublisher(&out)
+
+	processor := job.NewProcessor(recorded.Provider(), assistant, publisher, job.NewReplayNotifier(log.NewLogger()), log.NewLogger())
+	require.NoError(t, processor.Process(context.Background()))
+
+	require.NotNil(t, publisher.Document())
+	require.NotEmpty(t, publisher.Document().Sections[0].Paragraphs)
+	assert.Equal(t, recorded.Document.Sections[0].Paragraphs, publisher.Document().Sections[0].Paragraphs)
+	assert.True(t, recorded.Matches(publisher.Document()))
+	assert.Empty(t, assistant.Unused())
+	assert.Contains(t, out.String(), "The recorded paragraph")
+
+	entries, err := transcript.ReadFile(path)
+	require.NoError(t, err)
+	assert.Len(t, entries, 3, "the replay should not append to the transcript")
+}
+
+func TestReplay_ChangedPromptFails(t *testing.T) {
+	var buf bytes.Buffer
+	record := transcript.New(&buf, uuid.New().String())
+	require.NoError(t, record.WriteRequest("gemini", "test-assistant-generator", nil, models.ContentRequest{Body: map[string]any{"topic": "AI"}}))
+
+	assistant := models.Chain(&mocks.MockAssistant{}, record.Middleware())
+	_, err := assistant.Ask(context.Background(), "Test persona", "An older prompt")
+	require.NoError(t, err)
+
+	path := filepath.Join(t.TempDir(), "transcript.jsonl")
+	require.NoError(t, os.WriteFile(path, buf.Bytes(), 0o644))
+
+	recorded, err := job.LoadReplay(path)
+	require.NoError(t, err)
+
+	byRequest, err := recorded.Assistant(cassette.MatchRequest)
+	require.NoError(t, err)
+
+	_, err = byRequest.Ask(context.Background(), "Test persona", "Write a test paragraph")
+	assert.ErrorIs(t, err, cassette.ErrNoInteraction)
+
+	sequence, err := recorded.Assistant(cassette.MatchSequence)
+	require.NoError(t, err)
+
+	response, err := sequence.Ask(context.Background(), "Test persona", "Write a test paragraph")
+	require.NoError(t, err)
+	assert.Equal(t, "Mock response", *response)
+}
+
+func TestReplay_ServesEachMethod(t *testing.T) {
+	var buf bytes.Buffer
+	record := transcript.New(&buf, uuid.New().String())
+	require.NoError(t, record.WriteRequest("gemini", "test-generator", nil, models.ContentRequest{}))
+
+	mockAssistant := &mocks.MockAssistant{
+		AskWithSourcesFunc: func(ctx context.Context, persona string, request string) (*models.Answer, error) {
+			return &models.Answer{Text: "Sourced answer"}, nil
+		},
+	}
+
+	assistant := models.Chain(mockAssistant, record.Middleware())
+	ctx := context.Background()
+	messages := []models.Message{{Role: models.RoleUser, Content: "Hello"}}
+
+	_, err := assistant.AskWithSources(ctx, "persona", "sources")
+	require.NoError(t, err)
+	_, err = assistant.Chat(ctx, "persona", messages)
+	require.NoError(t, err)
+	structured, err := assistant.StructuredAsk(ctx, "persona", "article", articleSchema)
+	require.NoError(t, err)
+
+	path := filepath.Join(t.TempDir(), "transcript.jsonl")
+	require.NoError(t, os.WriteFile(path, buf.Bytes(), 0o644))
+
+	recorded, err := job.LoadReplay(path)
+	require.NoError(t, err)
+
+	replayer, err := recorded.Assistant(cassette.MatchRequest)
+	require.NoError(t, err)
+
+	answer, err := replayer.AskWithSources(ctx, "persona", "sources")
+	require.NoError(t, err)
+	assert.Equal(t, "Sourced answer", answer.Text)
+
+	chat, err := replayer.Chat(ctx, "persona", messages)
+	require.NoError(t, err)
+	assert.Equal(t, "Mock response", *chat)
+
+	replayed, err := replayer.StructuredAsk(ctx, "persona", "article", articleSchema)
+	require.NoError(t, err)
+	assert.JSONEq(t, string(structured), string(replayed))
+}
+
+func TestReplay_ErrorsMatchSentinels(t *testing.T) {
+	var buf bytes.Buffer
+	record := transcript.New(&buf, uuid.New().String())
+	require.NoError(t, record.WriteRequest("gemini", "test-generator", nil, models.ContentRequest{}))
+
+	assistant := models.Chain(&mocks.MockAssistant{
+		AskFunc: func(ctx context.Context, persona string, request string) (*string, error) {
+			return nil, &models.ContentBlockedError{FinishReason: "SAFETY"}
+		},
+	}, record.Middleware())
+
+	_, blocked := assistant.Ask(context.Background(), "persona", "blocked")
+	require.ErrorIs(t, blocked, models.ErrContentBlocked)
+
+	path := filepath.Join(t.TempDir(), "transcript.jsonl")
+	require.NoError(t, os.WriteFile(path, buf.Bytes(), 0o644))
+
+	recorded, err := job.LoadReplay(path)
+	require.NoError(t, err)
+
+	replayer, err := recorded.Assistant(cassette.MatchRequest)
+	require.NoError(t, err)
+
+	_, replayed := replayer.Ask(context.Background(), "persona", "blocked")
+	assert.ErrorIs(t, replayed, models.ErrContentBlocked, "a replayed failure should match its sentinel")
+	assert.Equal(t, blocked.Error(), replayed.Error())
+}
+
+func TestReplay_UsesLastAttempt(t *testing.T) {
+	var buf bytes.Buffer
+	record := transcript.New(&buf, uuid.New().String())
+	assistant := models.Chain(&mocks.MockAssistant{}, record.Middleware())
+
+	require.NoError(t, record.WriteRequest("gemini", "test-generator", nil, models.ContentRequest{Body: map[string]any{"attempt": 1.0}}))
+	_, _ = assistant.Ask(context.Background(), "persona", "first")
+	require.NoError(t, record.WriteRequest("gemini", "test-generator", nil, models.ContentRequest{Body: map[string]any{"attempt": 2.0}}))
+	_, _ = assistant.Ask(context.Background(), "persona", "second")
+
+	path := filepath.Join(t.TempDir(), "transcript.jsonl")
+	require.NoError(t, os.WriteFile(path, buf.Bytes(), 0o644))
+
+	recorded, err := job.LoadReplay(path)
+	require.NoError(t, err)
+	assert.Equal(t, 2.0, recorded.Request.Body["attempt"])
+	require.Len(t, recorded.Calls, 1)
+	assert.Equal(t, "second", recorded.Calls[0].Request)
+	assert.Nil(t, recorded.Document)
+}
+
+func TestReplay_NoRequest(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "transcript.jsonl")
+	require.NoError(t, os.WriteFile(path, nil, 0o644))
+
+	_, err := job.LoadReplay(path)
+	assert.Error(t, err)
+}
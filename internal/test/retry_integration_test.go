@@ -0,0 +1,88 @@
+package test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/schraf/assistant/internal/openai"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOpenAIClient_RetriesWithRetryAfter(t *testing.T) {
+	calls := 0
+
+	server := newOpenAIServer(t, func(body map[string]any) (int, any) {
+		calls++
+		if calls == 1 {
+			return http.StatusTooManyRequests, map[string]any{"error": map[string]any{"message": "slow down"}}
+		}
+		return http.StatusOK, completion("Hello")
+	})
+	server.Config.Handler = retryAfterHandler(server.Config.Handler, "0")
+
+	client, err := openai.NewClient(context.Background(), 1)
+	require.NoError(t, err)
+
+	start := time.Now()
+	response, err := client.Ask(context.Background(), "persona", "request")
+	require.NoError(t, err)
+	assert.Equal(t, "Hello", *response)
+	assert.Equal(t, 2, calls)
+	assert.Less(t, time.Since(start), 500*time.Millisecond, "Retry-After should replace the backoff")
+}
+
+func TestOpenAIClient_GivesUpOnLongRetryAfter(t *testing.T) {
+	calls := 0
+
+	server := newOpenAIServer(t, func(body map[string]any) (int, any) {
+		calls++
+		return http.StatusTooManyRequests, map[string]any{"error": map[string]any{"message": "come back later"}}
+	})
+	server.Config.Handler = retryAfterHandler(server.Config.Handler, "3600")
+
+	client, err := openai.NewClient(context.Background(), 1)
+	require.NoError(t, err)
+
+	start := time.Now()
+	_, err = client.Ask(context.Background(), "persona", "request")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "status 429")
+	assert.Equal(t, 1, calls, "an hour long Retry-After should not be waited out")
+	assert.Less(t, time.Since(start), 500*time.Millisecond)
+}
+
+func retryAfterHandler(next http.Handler, retryAfter string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", retryAfter)
+		next.ServeHTTP(w, r)
+	})
+}
+
+func TestGeminiClient_RetriesUnavailable(t *testing.T) {
+	calls := 0
+
+	newGeminiServer(t, func(body map[string]any) (int, any) {
+		calls++
+		if calls == 1 {
+			return http.StatusServiceUnavailable, map[string]any{
+				"error": map[string]any{
+					"code":    503,
+					"status":  "UNAVAILABLE",
+					"message": "overloaded",
+					"details": []any{
+						map[string]any{"@type": "type.googleapis.com/google.rpc.RetryInfo", "retryDelay": "0s"},
+					},
+				},
+			}
+		}
+		return http.StatusOK, candidate("Hello")
+	})
+
+	response, err := newGeminiClient(t).Ask(context.Background(), "persona", "request")
+	require.NoError(t, err)
+	assert.Equal(t, "Hello", *response)
+	assert.Equal(t, 2, calls)
+}
@@ -0,0 +1,200 @@
+package test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/schraf/assistant/internal/ollama"
+	"github.com/schraf/assistant/internal/openai"
+	"github.com/schraf/assistant/pkg/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var articleSchema = map[string]any{
+	"type": "object",
+	"properties": map[string]any{
+		"title": map[string]any{"type": "string"},
+		"tone":  map[string]any{"type": "string", "enum": []string{"formal", "casual"}},
+		"sections": map[string]any{
+			"type":     "array",
+			"minItems": 1,
+			"maxItems": 3,
+			"items": map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"heading": map[string]any{"type": "string"},
+					"words":   map[string]any{"type": "integer"},
+				},
+				"required": []string{"heading"},
+			},
+		},
+	},
+	"required": []string{"title", "sections"},
+}
+
+func decode(t *testing.T, data string) any {
+	t.Helper()
+
+	var value any
+	require.NoError(t, json.Unmarshal([]byte(data), &value))
+	return value
+}
+
+func TestOllamaClient_StructuredAskRepairs(t *testing.T) {
+	responses := []string{
+		"```json\n{\"title\":\"T\"}\n```",
+		`{"title":"T","sections":[{"heading":"H"}]}`,
+	}
+
+	var requests []map[string]any
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]any
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+
+		content := responses[len(requests)]
+		requests = append(requests, body)
+
+		json.NewEncoder(w).Encode(map[string]any{
+			"message": map[string]any{"role": "assistant", "content": content},
+			"done":    true,
+		})
+	}))
+	defer server.Close()
+
+	t.Setenv("OLLAMA_BASE_URL", server.URL)
+
+	client, err := ollama.NewClient(context.Background(), 1)
+	require.NoError(t, err)
+
+	response, err := client.StructuredAsk(context.Background(), "persona", "Write an outline", articleSchema)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"title":"T","sections":[{"heading":"H"}]}`, string(response))
+
+	require.Len(t, requests, 2, "the invalid response should be repaired once")
+
+	repair := requests[1]["messages"].([]any)[1].(map[string]any)["content"].(string)
+	assert.Contains(t, repair, "Write an outline")
+	assert.Contains(t, repair, `{"title":"T"}`, "the repair should include the previous response")
+	assert.Contains(t, repair, `missing required property "sections"`, "the repair should include the errors")
+}
+
+func TestOpenAIClient_StructuredAskValidationError(t *testing.T) {
+	calls := 0
+
+	newOpenAIServer(t, func(body map[string]any) (int, any) {
+		calls++
+		return http.StatusOK, completion(`{"title":"T"}`)
+	})
+
+	client, err := openai.NewClient(context.Background(), 1)
+	require.NoError(t, err)
+
+	ctx := models.WithSchemaRepairs(context.Background(), 1)
+
+	_, err = client.StructuredAsk(ctx, "persona", "Write an outline", articleSchema)
+	require.ErrorIs(t, err, models.ErrSchemaValidation)
+
+	var validation *models.SchemaValidationError
+	require.ErrorAs(t, err, &validation)
+	assert.Equal(t, 2, validation.Attempts)
+	assert.Equal(t, []string{`$: missing required property "sections"`}, validation.Errors)
+	assert.JSONEq(t, `{"title":"T"}`, string(validation.Response))
+	assert.Equal(t, 2, calls, "one attempt plus one repair")
+}
+
+func TestOllamaClient_StructuredAskNativeFormat(t *testing.T) {
+	var captured map[string]any
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&captured))
+
+		json.NewEncoder(w).Encode(map[string]any{
+			"message": map[string]any{"role": "assistant", "content": `{"title":"T","sections":[{"heading":"H"}]}`},
+			"done":    true,
+		})
+	}))
+	defer server.Close()
+
+	t.Setenv("OLLAMA_BASE_URL", server.URL)
+
+	client, err := ollama.NewClient(context.Background(), 1)
+	require.NoError(t, err)
+
+	_, err = client.StructuredAsk(context.Background(), "persona", "Write an outline", articleSchema)
+	require.NoError(t, err)
+
+	format, ok := captured["format"].(map[string]any)
+	require.True(t, ok, "the schema should be sent as the format")
+	assert.Equal(t, "object", format["type"])
+
+	persona := captured["messages"].([]any)[0].(map[string]any)["content"]
+	assert.Equal(t, "persona", persona, "the persona should not be changed")
+}
+
+func TestOllamaClient_StructuredAskLegacyFormat(t *testing.T) {
+	var formats []any
+	var personas []any
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]any
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+
+		formats = append(formats, body["format"])
+		personas = append(personas, body["messages"].([]any)[0].(map[string]any)["content"])
+
+		if _, ok := body["format"].(map[string]any); ok {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]any{
+				"error": "json: cannot unmarshal object into Go struct field ChatRequest.format of type string",
+			})
+			return
+		}
+
+		json.NewEncoder(w).Encode(map[string]any{
+			"message": map[string]any{"role": "assistant", "content": `{"title":"T","sections":[{"heading":"H"}]}`},
+			"done":    true,
+		})
+	}))
+	defer server.Close()
+
+	t.Setenv("OLLAMA_BASE_URL", server.URL)
+
+	client, err := ollama.NewClient(context.Background(), 1)
+	require.NoError(t, err)
+
+	_, err = client.StructuredAsk(context.Background(), "persona", "Write an outline", articleSchema)
+	require.NoError(t, err)
+
+	_, err = client.StructuredAsk(context.Background(), "persona", "Write another outline", articleSchema)
+	require.NoError(t, err)
+
+	require.Len(t, formats, 3, "only the first call should try the schema format")
+	assert.IsType(t, map[string]any{}, formats[0])
+	assert.Equal(t, "json", formats[1])
+	assert.Equal(t, "json", formats[2])
+
+	assert.Equal(t, "persona", personas[0])
+	assert.Contains(t, personas[1], "must respond with valid JSON", "legacy mode should describe the schema")
+}
+
+func TestOllamaClient_StructuredAskBadRequest(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]any{"error": "model is required"})
+	}))
+	defer server.Close()
+
+	t.Setenv("OLLAMA_BASE_URL", server.URL)
+
+	client, err := ollama.NewClient(context.Background(), 1)
+	require.NoError(t, err)
+
+	_, err = client.StructuredAsk(context.Background(), "persona", "request", articleSchema)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "ollama API returned status 400")
+}
@@ -0,0 +1,107 @@
+package test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/schraf/assistant/internal/telegraph"
+	"github.com/schraf/assistant/pkg/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTelegraphServer(t *testing.T, content *[]any) string {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/createPage", r.URL.Path)
+		require.NoError(t, r.ParseForm())
+		require.NoError(t, json.Unmarshal([]byte(r.PostForm.Get("content")), content))
+
+		json.NewEncoder(w).Encode(map[string]any{
+			"ok":     true,
+			"result": map[string]any{"path": "test-page", "url": "https://telegra.ph/test-page"},
+		})
+	}))
+
+	t.Cleanup(server.Close)
+	t.Setenv("TELEGRAPH_API_KEY", "test-token")
+
+	return server.URL
+}
+
+func TestTelegraphPublisher_RendersSources(t *testing.T) {
+	var content []any
+	baseURL := newTelegraphServer(t, &content)
+
+	publisher := telegraph.NewPublisherWithClient(telegraph.NewClient(telegraph.Config{BaseURL: baseURL}))
+
+	doc := &models.Document{
+		Title:    "Test",
+		Sections: []models.DocumentSection{{Title: "Intro", Paragraphs: []string{"Some text."}}},
+	}
+	doc.AddReferences(
+		models.Reference{Title: "Example", URL: "https://example.com"},
+		models.Reference{URL: "https://example.org"},
+	)
+
+	pageURL, err := publisher.PublishDocument(context.Background(), doc)
+	require.NoError(t, err)
+	assert.Equal(t, "https://telegra.ph/test-page", pageURL.String())
+
+	require.Len(t, content, 4, "section heading, paragraph, sources heading and list")
+
+	heading := content[2].(map[string]any)
+	assert.Equal(t, "h3", heading["tag"])
+	assert.Equal(t, []any{"Sources"}, heading["children"])
+
+	list := content[3].(map[string]any)
+	assert.Equal(t, "ol", list["tag"])
+
+	items := list["children"].([]any)
+	require.Len(t, items, 2)
+
+	link := items[0].(map[string]any)["children"].([]any)[0].(map[string]any)
+	assert.Equal(t, "a", link["tag"])
+	assert.Equal(t, map[string]any{"href": "https://example.com"}, link["attrs"])
+	assert.Equal(t, []any{"Example"}, link["children"])
+
+	link = items[1].(map[string]any)["children"].([]any)[0].(map[string]any)
+	assert.Equal(t, []any{"https://example.org"}, link["children"], "untitled references should show their URL")
+}
+
+func TestTelegraphPublisher_NoSources(t *testing.T) {
+	var content []any
+	baseURL := newTelegraphServer(t, &content)
+
+	publisher := telegraph.NewPublisherWithClient(telegraph.NewClient(telegraph.Config{BaseURL: baseURL}))
+
+	doc := &models.Document{
+		Title:    "Test",
+		Sections: []models.DocumentSection{{Title: "Intro", Paragraphs: []string{"Some text."}}},
+	}
+
+	_, err := publisher.PublishDocument(context.Background(), doc)
+	require.NoError(t, err)
+	assert.Len(t, content, 2, "no sources section without references")
+}
+
+func TestDocument_AddReferences(t *testing.T) {
+	doc := &models.Document{}
+
+	doc.AddReferences(
+		models.Reference{Title: "A", URL: "https://a.example"},
+		models.Reference{Title: "B", URL: "https://b.example"},
+	)
+	doc.AddReferences(
+		models.Reference{Title: "A again", URL: "https://a.example"},
+		models.Reference{Title: "No URL"},
+	)
+
+	require.Len(t, doc.References, 2, "duplicate and empty URLs should be skipped")
+	assert.Equal(t, "A", doc.References[0].Title)
+	assert.Equal(t, "B", doc.References[1].Title)
+}
@@ -0,0 +1,323 @@
+package test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/schraf/assistant/internal/ollama"
+	"github.com/schraf/assistant/internal/openai"
+	"github.com/schraf/assistant/pkg/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func weatherTool(calls *[]string) models.Tool {
+	return models.Tool{
+		Name:        "get_weather",
+		Description: "Returns the weather for a city",
+		Parameters: map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"city": map[string]any{"type": "string"},
+			},
+			"required": []string{"city"},
+		},
+		Handler: func(ctx context.Context, arguments json.RawMessage) (any, error) {
+			var args struct {
+				City string `json:"city"`
+			}
+			if err := json.Unmarshal(arguments, &args); err != nil {
+				return nil, err
+			}
+
+			*calls = append(*calls, args.City)
+			return map[string]any{"forecast": "sunny"}, nil
+		},
+	}
+}
+
+func TestCallTool(t *testing.T) {
+	var calls []string
+
+	tools := []models.Tool{
+		weatherTool(&calls),
+		{
+			Name: "broken",
+			Handler: func(ctx context.Context, arguments json.RawMessage) (any, error) {
+				return nil, errors.New("tool failed")
+			},
+		},
+	}
+
+	result := models.CallTool(context.Background(), tools, "get_weather", json.RawMessage(`{"city":"Paris"}`))
+	assert.Equal(t, map[string]any{"result": map[string]any{"forecast": "sunny"}}, result)
+	assert.Equal(t, []string{"Paris"}, calls)
+
+	result = models.CallTool(context.Background(), tools, "broken", nil)
+	assert.Equal(t, map[string]any{"error": "tool failed"}, result, "handler errors should be reported to the model")
+
+	result = models.CallTool(context.Background(), tools, "missing", nil)
+	assert.Equal(t, map[string]any{"error": "unknown tool 'missing'"}, result, "unknown tools should be reported to the model")
+}
+
+func TestToolsFromContext(t *testing.T) {
+	ctx := context.Background()
+	assert.Empty(t, models.ToolsFromContext(ctx))
+	assert.Equal(t, models.DefaultMaxToolIterations, models.MaxToolIterationsFromContext(ctx))
+
+	ctx = models.WithTools(ctx, models.Tool{Name: "a"}, models.Tool{Name: "b"})
+	ctx = models.WithMaxToolIterations(ctx, 2)
+
+	tools := models.ToolsFromContext(ctx)
+	require.Len(t, tools, 2)
+	assert.Equal(t, "a", tools[0].Name)
+	assert.Equal(t, 2, models.MaxToolIterationsFromContext(ctx))
+}
+
+func openAIToolCall(id string, name string, arguments string) map[string]any {
+	return map[string]any{
+		"choices": []any{
+			map[string]any{
+				"message": map[string]any{
+					"role":    "assistant",
+					"content": "",
+					"tool_calls": []any{
+						map[string]any{
+							"id":   id,
+							"type": "function",
+							"function": map[string]any{
+								"name":      name,
+								"arguments": arguments,
+							},
+						},
+					},
+				},
+				"finish_reason": "tool_calls",
+			},
+		},
+	}
+}
+
+func TestOpenAIClient_AskWithTools(t *testing.T) {
+	var requests []map[string]any
+
+	newOpenAIServer(t, func(body map[string]any) (int, any) {
+		requests = append(requests, body)
+
+		if len(requests) == 1 {
+			return http.StatusOK, openAIToolCall("call_1", "get_weather", `{"city":"Paris"}`)
+		}
+
+		return http.StatusOK, completion("It is sunny in Paris")
+	})
+
+	client, err := openai.NewClient(context.Background(), 1)
+	require.NoError(t, err)
+
+	var calls []string
+	ctx := models.WithTools(context.Background(), weatherTool(&calls))
+
+	response, err := client.Ask(ctx, "You are helpful", "What is the weather in Paris?")
+	require.NoError(t, err)
+	assert.Equal(t, "It is sunny in Paris", *response)
+	assert.Equal(t, []string{"Paris"}, calls, "tool should be called once")
+
+	require.Len(t, requests, 2)
+
+	tools := requests[0]["tools"].([]any)
+	require.Len(t, tools, 1)
+	function := tools[0].(map[string]any)["function"].(map[string]any)
+	assert.Equal(t, "get_weather", function["name"])
+
+	messages := requests[1]["messages"].([]any)
+	require.Len(t, messages, 4, "follow up should carry the tool call and its result")
+
+	toolMessage := messages[3].(map[string]any)
+	assert.Equal(t, "tool", toolMessage["role"])
+	assert.Equal(t, "call_1", toolMessage["tool_call_id"])
+	assert.JSONEq(t, `{"result":{"forecast":"sunny"}}`, toolMessage["content"].(string))
+}
+
+func TestOpenAIClient_ToolIterationsExceeded(t *testing.T) {
+	newOpenAIServer(t, func(body map[string]any) (int, any) {
+		return http.StatusOK, openAIToolCall("call", "get_weather", `{"city":"Paris"}`)
+	})
+
+	client, err := openai.NewClient(context.Background(), 1)
+	require.NoError(t, err)
+
+	var calls []string
+	ctx := models.WithTools(context.Background(), weatherTool(&calls))
+	ctx = models.WithMaxToolIterations(ctx, 2)
+
+	_, err = client.Ask(ctx, "You are helpful", "What is the weather?")
+	assert.ErrorIs(t, err, models.ErrToolIterationsExceeded)
+	assert.Len(t, calls, 2, "tools should run until the guard is reached")
+}
+
+func TestOllamaClient_AskWithTools(t *testing.T) {
+	var requests []map[string]any
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]any
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		requests = append(requests, body)
+
+		message := map[string]any{"role": "assistant", "content": "It is sunny in Paris"}
+		if len(requests) == 1 {
+			message = map[string]any{
+				"role":    "assistant",
+				"content": "",
+				"tool_calls": []any{
+					map[string]any{
+						"function": map[string]any{
+							"name":      "get_weather",
+							"arguments": map[string]any{"city": "Paris"},
+						},
+					},
+				},
+			}
+		}
+
+		json.NewEncoder(w).Encode(map[string]any{"message": message, "done": true})
+	}))
+	defer server.Close()
+
+	t.Setenv("OLLAMA_BASE_URL", server.URL)
+
+	client, err := ollama.NewClient(context.Background(), 1)
+	require.NoError(t, err)
+
+	var calls []string
+	ctx := models.WithTools(context.Background(), weatherTool(&calls))
+
+	response, err := client.Ask(ctx, "You are helpful", "What is the weather in Paris?")
+	require.NoError(t, err)
+	assert.Equal(t, "It is sunny in Paris", *response)
+	assert.Equal(t, []string{"Paris"}, calls)
+
+	require.Len(t, requests, 2)
+	assert.Len(t, requests[0]["tools"], 1, "tools should be sent with the request")
+
+	messages := requests[1]["messages"].([]any)
+	require.Len(t, messages, 4)
+
+	toolMessage := messages[3].(map[string]any)
+	assert.Equal(t, "tool", toolMessage["role"])
+	assert.Equal(t, "get_weather", toolMessage["tool_name"])
+	assert.JSONEq(t, `{"result":{"forecast":"sunny"}}`, toolMessage["content"].(string))
+}
+
+// delegateTool returns a tool whose handler asks the assistant itself, as a
+// tool delegating a sub-task to the model would.
+func delegateTool(assistant func() models.Assistant) models.Tool {
+	return models.Tool{
+		Name:        "delegate",
+		Description: "Asks the model a sub-question",
+		Handler: func(ctx context.Context, arguments json.RawMessage) (any, error) {
+			return assistant().Ask(models.WithTools(ctx), "You are helpful", "Sub-question")
+		},
+	}
+}
+
+// askReentrant calls Ask with the delegate tool and fails the test instead
+// of hanging when the nested call cannot get a permit.
+func askReentrant(t *testing.T, assistant models.Assistant) {
+	t.Helper()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	ctx = models.WithTools(ctx, delegateTool(func() models.Assistant { return assistant }))
+
+	response, err := assistant.Ask(ctx, "You are helpful", "Main question")
+	require.NoError(t, err, "a tool handler calling back into the assistant should not deadlock")
+	assert.Equal(t, "Final answer", *response)
+}
+
+func TestOpenAIClient_ReentrantTool(t *testing.T) {
+	newOpenAIServer(t, func(body map[string]any) (int, any) {
+		messages := body["messages"].([]any)
+
+		switch {
+		case body["tools"] == nil:
+			return http.StatusOK, completion("Sub-answer")
+		case len(messages) == 2:
+			return http.StatusOK, openAIToolCall("call_1", "delegate", `{}`)
+		default:
+			return http.StatusOK, completion("Final answer")
+		}
+	})
+
+	client, err := openai.NewClient(context.Background(), 1)
+	require.NoError(t, err)
+
+	askReentrant(t, client)
+}
+
+func TestOllamaClient_ReentrantTool(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]any
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+
+		message := map[string]any{"role": "assistant", "content": "Final answer"}
+
+		switch {
+		case body["tools"] == nil:
+			message["content"] = "Sub-answer"
+		case len(body["messages"].([]any)) == 2:
+			message = map[string]any{
+				"role":    "assistant",
+				"content": "",
+				"tool_calls": []any{
+					map[string]any{
+						"function": map[string]any{"name": "delegate", "arguments": map[string]any{}},
+					},
+				},
+			}
+		}
+
+		json.NewEncoder(w).Encode(map[string]any{"message": message, "done": true})
+	}))
+	defer server.Close()
+
+	t.Setenv("OLLAMA_BASE_URL", server.URL)
+
+	client, err := ollama.NewClient(context.Background(), 1)
+	require.NoError(t, err)
+
+	askReentrant(t, client)
+}
+
+func TestGeminiClient_ReentrantTool(t *testing.T) {
+	newGeminiServer(t, func(body map[string]any) (int, any) {
+		tools, _ := body["tools"].([]any)
+		hasFunctions := len(tools) > 0 && tools[0].(map[string]any)["functionDeclarations"] != nil
+
+		switch {
+		case !hasFunctions:
+			return http.StatusOK, candidate("Sub-answer")
+		case len(body["contents"].([]any)) == 1:
+			return http.StatusOK, map[string]any{
+				"candidates": []any{
+					map[string]any{
+						"content": map[string]any{
+							"role":  "model",
+							"parts": []any{map[string]any{"functionCall": map[string]any{"name": "delegate", "args": map[string]any{}}}},
+						},
+						"finishReason": "STOP",
+					},
+				},
+			}
+		default:
+			return http.StatusOK, candidate("Final answer")
+		}
+	})
+
+	askReentrant(t, newGeminiClient(t))
+}
@@ -0,0 +1,143 @@
+package test
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/url"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/schraf/assistant/internal/job"
+	"github.com/schraf/assistant/internal/log"
+	"github.com/schraf/assistant/internal/mocks"
+	"github.com/schraf/assistant/internal/transcript"
+	"github.com/schraf/assistant/pkg/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTranscript_RecordsCalls(t *testing.T) {
+	var buf bytes.Buffer
+	record := transcript.New(&buf, "request-1")
+
+	mockAssistant := &mocks.MockAssistant{
+		AskFunc: func(ctx context.Context, persona string, request string) (*string, error) {
+			if request == "fail" {
+				return nil, errors.New("provider unavailable")
+			}
+			response := "Hello"
+			return &response, nil
+		},
+		WithModelFunc: func(ctx context.Context, model string) context.Context {
+			return ctx
+		},
+	}
+
+	assistant := models.Chain(mockAssistant, record.Middleware())
+	ctx := assistant.WithModel(context.Background(), models.TierPro)
+
+	require.NoError(t, record.WriteRequest("gemini", "newspaper", map[string]any{"model": "pro"}, models.ContentRequest{Body: map[string]any{"topic": "AI"}}))
+
+	_, err := assistant.Ask(ctx, "writer", "greet")
+	require.NoError(t, err)
+
+	_, err = assistant.StructuredAsk(ctx, "writer", "article", articleSchema)
+	require.NoError(t, err)
+
+	_, err = assistant.Ask(ctx, "writer", "fail")
+	require.Error(t, err)
+
+	publishedURL, _ := url.Parse("https://telegra.ph/test-page")
+	require.NoError(t, record.WriteDocument(&models.Document{Title: "Title"}, publishedURL))
+
+	entries, err := transcript.Read(&buf)
+	require.NoError(t, err)
+	require.Len(t, entries, 5)
+
+	for _, entry := range entries {
+		assert.Equal(t, "request-1", entry.RequestID)
+		assert.False(t, entry.Time.IsZero())
+	}
+
+	assert.Equal(t, transcript.KindRequest, entries[0].Kind)
+	assert.Equal(t, "gemini", entries[0].Provider)
+	assert.Equal(t, "newspaper", entries[0].ContentType)
+	assert.Equal(t, "AI", entries[0].Body["topic"])
+
+	assert.Equal(t, transcript.KindCall, entries[1].Kind)
+	assert.Equal(t, models.MethodAsk, entries[1].Method)
+	assert.Equal(t, models.TierPro, entries[1].Model)
+	assert.Equal(t, "writer", entries[1].Persona)
+	assert.Equal(t, "greet", entries[1].Request)
+	assert.Equal(t, "Hello", entries[1].Response)
+	assert.Equal(t, "ok", entries[1].Outcome)
+
+	assert.Equal(t, models.MethodStructuredAsk, entries[2].Method)
+	assert.NotEmpty(t, entries[2].Schema)
+	assert.NotEmpty(t, entries[2].Response)
+
+	assert.Equal(t, "provider unavailable", entries[3].Error)
+	assert.Equal(t, "error", entries[3].Outcome)
+
+	assert.Equal(t, transcript.KindDocument, entries[4].Kind)
+	assert.Equal(t, "Title", entries[4].Document.Title)
+	assert.Equal(t, "https://telegra.ph/test-page", entries[4].URL)
+}
+
+func TestTranscript_ConcurrentCalls(t *testing.T) {
+	var buf bytes.Buffer
+	assistant := models.Chain(&mocks.MockAssistant{}, transcript.New(&buf, "request-1").Middleware())
+
+	var wg sync.WaitGroup
+
+	for range 20 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, _ = assistant.Ask(context.Background(), "persona", "request")
+		}()
+	}
+
+	wg.Wait()
+
+	entries, err := transcript.Read(&buf)
+	require.NoError(t, err, "lines should not be interleaved")
+	assert.Len(t, entries, 20)
+}
+
+func TestProcessor_WritesTranscript(t *testing.T) {
+	dir := t.TempDir()
+	requestID := uuid.New()
+	bodyJSON, _ := json.Marshal(map[string]any{"topic": "AI"})
+	configJSON, _ := json.Marshal(map[string]any{"temperature": 0.5})
+
+	t.Setenv("REQUEST_ID", requestID.String())
+	t.Setenv("REQUEST_BODY", base64.StdEncoding.EncodeToString(bodyJSON))
+	t.Setenv("CONTENT_CONFIG", base64.StdEncoding.EncodeToString(configJSON))
+	t.Setenv("CONTENT_TYPE", "test-assistant-generator")
+	t.Setenv("TRANSCRIPT_DIR", dir)
+
+	processor := job.NewProcessor("gemini", &mocks.MockAssistant{}, &mocks.MockPublisher{}, &mocks.MockNotifier{}, log.NewLogger())
+	require.NoError(t, processor.Process(context.Background()))
+
+	entries, err := transcript.ReadFile(filepath.Join(dir, requestID.String()+".jsonl"))
+	require.NoError(t, err)
+	require.Len(t, entries, 3)
+
+	assert.Equal(t, transcript.KindRequest, entries[0].Kind)
+	assert.Equal(t, "test-assistant-generator", entries[0].ContentType)
+	assert.Equal(t, 0.5, entries[0].Config["temperature"])
+
+	assert.Equal(t, transcript.KindCall, entries[1].Kind)
+	assert.Equal(t, "Test persona", entries[1].Persona)
+	assert.Equal(t, "Write a test paragraph", entries[1].Request)
+	assert.Equal(t, "Mock response", entries[1].Response)
+
+	assert.Equal(t, transcript.KindDocument, entries[2].Kind)
+	assert.Equal(t, "Test Document", entries[2].Document.Title)
+}
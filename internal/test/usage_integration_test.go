@@ -0,0 +1,153 @@
+package test
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/schraf/assistant/internal/job"
+	"github.com/schraf/assistant/internal/log"
+	"github.com/schraf/assistant/internal/mocks"
+	"github.com/schraf/assistant/internal/ollama"
+	"github.com/schraf/assistant/internal/usage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUsageTracker_Summary(t *testing.T) {
+	prices := usage.PriceTable{
+		"priced-model": {InputPerMillion: 1, OutputPerMillion: 10},
+	}
+
+	tracker := usage.NewTracker(prices)
+	tracker.Add(usage.Usage{Model: "priced-model", PromptTokens: 1_000_000, ResponseTokens: 100_000, ThinkingTokens: 100_000})
+	tracker.Add(usage.Usage{Model: "priced-model", PromptTokens: 1_000_000})
+	tracker.Add(usage.Usage{Model: "local-model", PromptTokens: 500, ResponseTokens: 50})
+
+	summary := tracker.Summary()
+
+	require.Len(t, summary.Models, 2)
+	assert.Equal(t, 2, summary.Models["priced-model"].Calls)
+	assert.InDelta(t, 4.0, summary.Models["priced-model"].Cost, 0.0001, "cost should include thinking tokens at the output rate")
+	assert.Zero(t, summary.Models["local-model"].Cost, "unpriced models should be free")
+
+	assert.Equal(t, 3, summary.Total.Calls)
+	assert.Equal(t, 2_000_500, summary.Total.PromptTokens)
+	assert.Equal(t, 100_050, summary.Total.ResponseTokens)
+	assert.Equal(t, 100_000, summary.Total.ThinkingTokens)
+	assert.Contains(t, summary.String(), "priced-model: 2 calls")
+}
+
+func TestUsage_RecordWithoutTracker(t *testing.T) {
+	assert.NotPanics(t, func() {
+		usage.Record(context.Background(), usage.Usage{Model: "any", PromptTokens: 1})
+	}, "recording without a tracker should be a no-op")
+}
+
+func TestOllamaClient_RecordsUsage(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{
+			"message":           map[string]any{"role": "assistant", "content": "hi"},
+			"done":              true,
+			"prompt_eval_count": 12,
+			"eval_count":        34,
+		})
+	}))
+	defer server.Close()
+
+	t.Setenv("OLLAMA_BASE_URL", server.URL)
+
+	client, err := ollama.NewClient(context.Background(), 1)
+	require.NoError(t, err)
+
+	tracker := usage.NewTracker(usage.DefaultPrices)
+	ctx := usage.WithTracker(client.WithModel(context.Background(), "llama-test"), tracker)
+
+	_, err = client.Ask(ctx, "persona", "request")
+	require.NoError(t, err)
+
+	totals := tracker.Summary().Models["llama-test"]
+	assert.Equal(t, 1, totals.Calls)
+	assert.Equal(t, 12, totals.PromptTokens)
+	assert.Equal(t, 34, totals.ResponseTokens)
+}
+
+func TestProcessor_Integration_UsageSummary(t *testing.T) {
+	bodyJSON, _ := json.Marshal(map[string]any{"topic": "AI"})
+
+	os.Setenv("REQUEST_ID", uuid.New().String())
+	os.Setenv("REQUEST_BODY", base64.StdEncoding.EncodeToString(bodyJSON))
+	os.Setenv("CONTENT_TYPE", "test-assistant-generator")
+	defer func() {
+		os.Unsetenv("REQUEST_ID")
+		os.Unsetenv("REQUEST_BODY")
+		os.Unsetenv("CONTENT_TYPE")
+	}()
+
+	mockAssistant := &mocks.MockAssistant{
+		AskFunc: func(ctx context.Context, persona string, request string) (*string, error) {
+			usage.Record(ctx, usage.Usage{Model: "gemini-flash-latest", PromptTokens: 100, ResponseTokens: 200})
+			response := "Generated content"
+			return &response, nil
+		},
+	}
+
+	var notifiedDetails string
+
+	mockNotifier := &mocks.MockNotifier{
+		SendPublishedURLNotificationFunc: func(publishedURL *url.URL, title string, details string) error {
+			notifiedDetails = details
+			return nil
+		},
+	}
+
+	processor := job.NewProcessor("gemini", mockAssistant, &mocks.MockPublisher{}, mockNotifier, log.NewLogger())
+
+	err := processor.Process(context.Background())
+	require.NoError(t, err, "processor.Process() should succeed")
+
+	assert.Contains(t, notifiedDetails, "gemini-flash-latest: 1 calls", "notification should include per-model usage")
+	assert.Contains(t, notifiedDetails, "total: 1 calls", "notification should include usage totals")
+}
+
+func TestGeminiClient_StreamRecordsUsageOnError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.True(t, strings.HasSuffix(r.URL.Path, ":streamGenerateContent"), "request should stream content")
+
+		chunk := candidate("Partial answer")
+		chunk["usageMetadata"] = map[string]any{"promptTokenCount": 12, "candidatesTokenCount": 34}
+		data, _ := json.Marshal(chunk)
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprintf(w, "data: %s\n\n", data)
+	}))
+	defer server.Close()
+
+	t.Setenv("GOOGLE_GEMINI_BASE_URL", server.URL)
+	t.Setenv("GOOGLE_API_KEY", "test-key")
+	t.Setenv("GOOGLE_GENAI_USE_VERTEXAI", "false")
+
+	client := newGeminiClient(t)
+	tracker := usage.NewTracker(usage.DefaultPrices)
+	ctx := usage.WithTracker(client.WithModel(context.Background(), "gemini-test"), tracker)
+	stopped := errors.New("stopped")
+
+	_, err := client.AskStream(ctx, "persona", "request", func(chunk string) error {
+		return stopped
+	})
+	require.ErrorIs(t, err, stopped)
+
+	totals := tracker.Summary().Models["gemini-test"]
+	assert.Equal(t, 1, totals.Calls, "usage should be recorded when the stream fails")
+	assert.Equal(t, 12, totals.PromptTokens)
+	assert.Equal(t, 34, totals.ResponseTokens)
+}
@@ -0,0 +1,233 @@
+package transcript
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/schraf/assistant/pkg/models"
+)
+
+// Entry kinds. A transcript starts with the request, has a call entry for
+// every assistant call and ends with the document once it is published.
+const (
+	KindRequest  = "request"
+	KindCall     = "call"
+	KindDocument = "document"
+)
+
+// Entry is one line of a transcript.
+type Entry struct {
+	Kind      string    `json:"kind"`
+	Time      time.Time `json:"time"`
+	RequestID string    `json:"request_id"`
+
+	// Request entries
+	Provider    string              `json:"provider,omitempty"`
+	ContentType string              `json:"content_type,omitempty"`
+	Config      map[string]any      `json:"config,omitempty"`
+	Body        map[string]any      `json:"body,omitempty"`
+	Attachments []models.Attachment `json:"attachments,omitempty"`
+
+	// Call entries
+	Method    string           `json:"method,omitempty"`
+	Model     string           `json:"model,omitempty"`
+	Persona   string           `json:"persona,omitempty"`
+	Request   string           `json:"request,omitempty"`
+	Messages  []models.Message `json:"messages,omitempty"`
+	Schema    map[string]any   `json:"schema,omitempty"`
+	Response  string           `json:"response,omitempty"`
+	Error     string           `json:"error,omitempty"`
+	Outcome   string           `json:"outcome,omitempty"`
+	LatencyMS int64            `json:"latency_ms,omitempty"`
+
+	// Document entries
+	Document *models.Document `json:"document,omitempty"`
+	URL      string           `json:"url,omitempty"`
+}
+
+// Transcript writes entries as JSON lines. It is safe for concurrent use,
+// so generators may make calls from several goroutines.
+type Transcript struct {
+	requestID string
+	path      string
+
+	lock   sync.Mutex
+	writer io.Writer
+	closer io.Closer
+}
+
+// New returns a Transcript for the request writing to w.
+func New(w io.Writer, requestID string) *Transcript {
+	return &Transcript{
+		requestID: requestID,
+		writer:    w,
+	}
+}
+
+// Create returns a Transcript writing to <dir>/<requestID>.jsonl. An
+// existing transcript for the request is appended to, so a retried job
+// keeps the record of its earlier attempts.
+func Create(dir string, requestID string) (*Transcript, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create transcript directory: %w", err)
+	}
+
+	path := filepath.Join(dir, requestID+".jsonl")
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open transcript: %w", err)
+	}
+
+	return &Transcript{
+		requestID: requestID,
+		path:      path,
+		writer:    file,
+		closer:    file,
+	}, nil
+}
+
+// Path returns the file the transcript is written to, or "" when it was
+// created with New.
+func (t *Transcript) Path() string {
+	return t.path
+}
+
+// Close closes the transcript file.
+func (t *Transcript) Close() error {
+	if t.closer == nil {
+		return nil
+	}
+
+	return t.closer.Close()
+}
+
+// WriteRequest records the content request being processed and the
+// provider answering it. Attachments are kept whole, so a replay can hand
+// the generator the same files.
+func (t *Transcript) WriteRequest(provider string, contentType string, config map[string]any, request models.ContentRequest) error {
+	return t.write(Entry{
+		Kind:        KindRequest,
+		Provider:    provider,
+		ContentType: contentType,
+		Config:      config,
+		Body:        request.Body,
+		Attachments: request.Attachments,
+	})
+}
+
+// WriteDocument records the published document and where it was published.
+func (t *Transcript) WriteDocument(doc *models.Document, publishedURL *url.URL) error {
+	entry := Entry{
+		Kind:     KindDocument,
+		Document: doc,
+	}
+
+	if publishedURL != nil {
+		entry.URL = publishedURL.String()
+	}
+
+	return t.write(entry)
+}
+
+// Middleware returns a middleware recording every call made through the
+// assistant. A call is never failed because its entry could not be written,
+// the error is logged instead.
+func (t *Transcript) Middleware() models.Middleware {
+	return models.Intercept(func(ctx context.Context, call *models.Call, next func(ctx context.Context) error) error {
+		start := time.Now()
+		err := next(ctx)
+
+		entry := Entry{
+			Kind:      KindCall,
+			Method:    call.Method,
+			Model:     call.Model,
+			Persona:   call.Persona,
+			Request:   call.Request,
+			Messages:  call.Messages,
+			Schema:    call.Schema,
+			Response:  call.Response,
+			Outcome:   models.Outcome(err),
+			LatencyMS: time.Since(start).Milliseconds(),
+		}
+
+		if err != nil {
+			entry.Error = err.Error()
+		}
+
+		if writeErr := t.write(entry); writeErr != nil {
+			slog.WarnContext(ctx, "transcript_write_failed",
+				slog.String("error", writeErr.Error()),
+			)
+		}
+
+		return err
+	})
+}
+
+func (t *Transcript) write(entry Entry) error {
+	entry.Time = time.Now().UTC()
+	entry.RequestID = t.requestID
+
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to marshal transcript entry: %w", err)
+	}
+
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if _, err := t.writer.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("failed to write transcript entry: %w", err)
+	}
+
+	return nil
+}
+
+// Read reads the entries of a transcript.
+func Read(r io.Reader) ([]Entry, error) {
+	var entries []Entry
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+
+	for scanner.Scan() {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+
+		var entry Entry
+
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return nil, fmt.Errorf("failed to parse transcript entry %d: %w", len(entries)+1, err)
+		}
+
+		entries = append(entries, entry)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read transcript: %w", err)
+	}
+
+	return entries, nil
+}
+
+// ReadFile reads the entries of a transcript file.
+func ReadFile(path string) ([]Entry, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open transcript: %w", err)
+	}
+	defer file.Close()
+
+	return Read(file)
+}
@@ -0,0 +1,79 @@
+package usage
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/schraf/assistant/pkg/models"
+)
+
+// Budget limits the usage of a request. Zero values are unlimited.
+type Budget struct {
+	MaxTokens int
+	MaxCost   float64
+}
+
+func (b Budget) exceeded(t Totals) bool {
+	if b.MaxTokens > 0 && t.TotalTokens() >= b.MaxTokens {
+		return true
+	}
+
+	if b.MaxCost > 0 && t.Cost >= b.MaxCost {
+		return true
+	}
+
+	return false
+}
+
+// BudgetExceededError is returned by assistant calls once a request has spent
+// its budget. It matches models.ErrBudgetExceeded with errors.Is.
+type BudgetExceededError struct {
+	Budget Budget
+	Used   Totals
+}
+
+func (e *BudgetExceededError) Error() string {
+	return fmt.Sprintf("budget exceeded: used %d tokens (max %d) and $%.4f (max $%.4f)",
+		e.Used.TotalTokens(), e.Budget.MaxTokens, e.Used.Cost, e.Budget.MaxCost)
+}
+
+func (e *BudgetExceededError) Unwrap() error {
+	return models.ErrBudgetExceeded
+}
+
+// SetBudget sets the budget enforced by Check.
+func (t *Tracker) SetBudget(budget Budget) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	t.budget = budget
+}
+
+// Check returns a *BudgetExceededError if the tracker carried by the context
+// has spent its budget. Providers call it before every request so that a
+// runaway generator fails instead of spending without limit.
+func Check(ctx context.Context) error {
+	tracker := FromContext(ctx)
+	if tracker == nil {
+		return nil
+	}
+
+	return tracker.Check()
+}
+
+// Check returns a *BudgetExceededError if the tracker has spent its budget.
+func (t *Tracker) Check() error {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	total := t.summary().Total
+
+	if t.budget.exceeded(total) {
+		return &BudgetExceededError{
+			Budget: t.budget,
+			Used:   total,
+		}
+	}
+
+	return nil
+}
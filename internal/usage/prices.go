@@ -0,0 +1,37 @@
+package usage
+
+// Price is the cost in US dollars per million tokens. Thinking tokens are
+// billed at the output rate.
+type Price struct {
+	InputPerMillion  float64 `json:"input_per_million"`
+	OutputPerMillion float64 `json:"output_per_million"`
+}
+
+// PriceTable maps model names to prices. Models that are not in the table,
+// such as local Ollama models, are treated as free.
+type PriceTable map[string]Price
+
+// DefaultPrices holds the published list prices for the hosted models we use.
+var DefaultPrices = PriceTable{
+	"gemini-pro-latest":        {InputPerMillion: 1.25, OutputPerMillion: 10.00},
+	"gemini-flash-latest":      {InputPerMillion: 0.30, OutputPerMillion: 2.50},
+	"gemini-flash-lite-latest": {InputPerMillion: 0.10, OutputPerMillion: 0.40},
+	"gemini-2.5-pro":           {InputPerMillion: 1.25, OutputPerMillion: 10.00},
+	"gemini-2.5-flash":         {InputPerMillion: 0.30, OutputPerMillion: 2.50},
+	"gemini-2.5-flash-lite":    {InputPerMillion: 0.10, OutputPerMillion: 0.40},
+	"gpt-4o":                   {InputPerMillion: 2.50, OutputPerMillion: 10.00},
+	"gpt-4o-mini":              {InputPerMillion: 0.15, OutputPerMillion: 0.60},
+}
+
+// Cost estimates the cost of a call in US dollars.
+func (p PriceTable) Cost(u Usage) float64 {
+	price, ok := p[u.Model]
+	if !ok {
+		return 0
+	}
+
+	input := float64(u.PromptTokens) * price.InputPerMillion
+	output := float64(u.ResponseTokens+u.ThinkingTokens) * price.OutputPerMillion
+
+	return (input + output) / 1_000_000
+}
@@ -0,0 +1,156 @@
+package usage
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Usage is the token usage reported by a provider for a single call.
+type Usage struct {
+	Model          string
+	PromptTokens   int
+	ResponseTokens int
+	ThinkingTokens int
+}
+
+// TotalTokens returns the number of tokens the call consumed.
+func (u Usage) TotalTokens() int {
+	return u.PromptTokens + u.ResponseTokens + u.ThinkingTokens
+}
+
+// Totals accumulates usage and estimated cost across calls.
+type Totals struct {
+	Calls          int     `json:"calls"`
+	PromptTokens   int     `json:"prompt_tokens"`
+	ResponseTokens int     `json:"response_tokens"`
+	ThinkingTokens int     `json:"thinking_tokens"`
+	Cost           float64 `json:"estimated_cost_usd"`
+}
+
+// TotalTokens returns the number of tokens consumed across all categories.
+func (t Totals) TotalTokens() int {
+	return t.PromptTokens + t.ResponseTokens + t.ThinkingTokens
+}
+
+func (t *Totals) add(other Totals) {
+	t.Calls += other.Calls
+	t.PromptTokens += other.PromptTokens
+	t.ResponseTokens += other.ResponseTokens
+	t.ThinkingTokens += other.ThinkingTokens
+	t.Cost += other.Cost
+}
+
+// Summary is a snapshot of a Tracker broken down by model.
+type Summary struct {
+	Models map[string]Totals `json:"models"`
+	Total  Totals            `json:"total"`
+}
+
+// String formats the summary as plain text suitable for a notification.
+func (s Summary) String() string {
+	names := make([]string, 0, len(s.Models))
+	for name := range s.Models {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+
+	b.WriteString("Assistant usage:\n")
+
+	for _, name := range names {
+		b.WriteString(formatTotals(name, s.Models[name]))
+	}
+
+	b.WriteString(formatTotals("total", s.Total))
+
+	return b.String()
+}
+
+func formatTotals(name string, t Totals) string {
+	return fmt.Sprintf("  %s: %d calls, %d prompt / %d response / %d thinking tokens, ~$%.4f\n",
+		name, t.Calls, t.PromptTokens, t.ResponseTokens, t.ThinkingTokens, t.Cost)
+}
+
+// Tracker accumulates the usage of every assistant call made for a request.
+// It is safe for concurrent use.
+type Tracker struct {
+	lock   sync.Mutex
+	prices PriceTable
+	budget Budget
+	models map[string]*Totals
+}
+
+// NewTracker creates a Tracker that estimates cost using the given prices.
+func NewTracker(prices PriceTable) *Tracker {
+	return &Tracker{
+		prices: prices,
+		models: make(map[string]*Totals),
+	}
+}
+
+// Add records the usage of a single call.
+func (t *Tracker) Add(u Usage) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	totals, ok := t.models[u.Model]
+	if !ok {
+		totals = &Totals{}
+		t.models[u.Model] = totals
+	}
+
+	totals.add(Totals{
+		Calls:          1,
+		PromptTokens:   u.PromptTokens,
+		ResponseTokens: u.ResponseTokens,
+		ThinkingTokens: u.ThinkingTokens,
+		Cost:           t.prices.Cost(u),
+	})
+}
+
+// Summary returns a snapshot of the usage recorded so far.
+func (t *Tracker) Summary() Summary {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	return t.summary()
+}
+
+func (t *Tracker) summary() Summary {
+	summary := Summary{
+		Models: make(map[string]Totals, len(t.models)),
+	}
+
+	for name, totals := range t.models {
+		summary.Models[name] = *totals
+		summary.Total.add(*totals)
+	}
+
+	return summary
+}
+
+type contextKey struct{}
+
+var trackerKey contextKey
+
+// WithTracker returns a context that carries the given tracker.
+func WithTracker(ctx context.Context, tracker *Tracker) context.Context {
+	return context.WithValue(ctx, trackerKey, tracker)
+}
+
+// FromContext returns the tracker carried by the context, or nil.
+func FromContext(ctx context.Context) *Tracker {
+	tracker, _ := ctx.Value(trackerKey).(*Tracker)
+	return tracker
+}
+
+// Record adds usage to the tracker carried by the context, if any.
+func Record(ctx context.Context, u Usage) {
+	if tracker := FromContext(ctx); tracker != nil {
+		tracker.Add(u)
+	}
+}
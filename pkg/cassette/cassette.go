@@ -0,0 +1,81 @@
+package cassette
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/schraf/assistant/pkg/models"
+)
+
+// Methods recorded in a cassette.
+const (
+	MethodAsk            = models.MethodAsk
+	MethodAskWithSources = models.MethodAskWithSources
+	MethodAskStream      = models.MethodAskStream
+	MethodChat           = models.MethodChat
+	MethodStructuredAsk  = models.MethodStructuredAsk
+)
+
+// Interaction is one recorded assistant call. Response holds the JSON of
+// the method's result: a string, a models.Answer or the structured
+// response. Calls that failed have Error set instead, with the error's
+// models.Outcome so a replayed error matches the same sentinel.
+type Interaction struct {
+	Method   string           `json:"method"`
+	Model    string           `json:"model,omitempty"`
+	Persona  string           `json:"persona"`
+	Request  string           `json:"request,omitempty"`
+	Messages []models.Message `json:"messages,omitempty"`
+	Schema   map[string]any   `json:"schema,omitempty"`
+	Response json.RawMessage  `json:"response,omitempty"`
+	Error    string           `json:"error,omitempty"`
+	Outcome  string           `json:"outcome,omitempty"`
+}
+
+// Cassette is the file format written by a Recorder and read by a Replayer.
+type Cassette struct {
+	Interactions []Interaction `json:"interactions"`
+}
+
+// Load reads a cassette file.
+func Load(path string) (*Cassette, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cassette: %w", err)
+	}
+
+	var cassette Cassette
+
+	if err := json.Unmarshal(data, &cassette); err != nil {
+		return nil, fmt.Errorf("failed to parse cassette: %w", err)
+	}
+
+	return &cassette, nil
+}
+
+// Save writes the cassette to path, replacing it atomically so a cassette
+// is never left half written.
+func (c *Cassette) Save(path string) error {
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal cassette: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("failed to create cassette directory: %w", err)
+	}
+
+	temp := path + ".tmp"
+
+	if err := os.WriteFile(temp, data, 0o644); err != nil {
+		return fmt.Errorf("failed to write cassette: %w", err)
+	}
+
+	if err := os.Rename(temp, path); err != nil {
+		return fmt.Errorf("failed to write cassette: %w", err)
+	}
+
+	return nil
+}
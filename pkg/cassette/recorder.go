@@ -0,0 +1,105 @@
+package cassette
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+
+	"github.com/schraf/assistant/pkg/models"
+)
+
+type modelKey struct{}
+
+// Recorder is an assistant that passes every call to a real assistant and
+// records it to a cassette file. The file is rewritten after each call, so a
+// recording is kept even if the program stops part way.
+type Recorder struct {
+	assistant models.Assistant
+	path      string
+
+	lock     sync.Mutex
+	cassette Cassette
+}
+
+// NewRecorder returns a Recorder writing the calls made to assistant to the
+// cassette at path, replacing any earlier recording.
+func NewRecorder(assistant models.Assistant, path string) *Recorder {
+	return &Recorder{
+		assistant: assistant,
+		path:      path,
+	}
+}
+
+// Interactions returns the calls recorded so far.
+func (r *Recorder) Interactions() []Interaction {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	return append([]Interaction(nil), r.cassette.Interactions...)
+}
+
+func (r *Recorder) Ask(ctx context.Context, persona string, request string) (*string, error) {
+	response, err := r.assistant.Ask(ctx, persona, request)
+
+	return response, r.record(ctx, Interaction{Method: MethodAsk, Persona: persona, Request: request}, response, err)
+}
+
+func (r *Recorder) AskWithSources(ctx context.Context, persona string, request string) (*models.Answer, error) {
+	answer, err := r.assistant.AskWithSources(ctx, persona, request)
+
+	return answer, r.record(ctx, Interaction{Method: MethodAskWithSources, Persona: persona, Request: request}, answer, err)
+}
+
+func (r *Recorder) AskStream(ctx context.Context, persona string, request string, handler models.StreamHandler) (*string, error) {
+	response, err := r.assistant.AskStream(ctx, persona, request, handler)
+
+	return response, r.record(ctx, Interaction{Method: MethodAskStream, Persona: persona, Request: request}, response, err)
+}
+
+func (r *Recorder) Chat(ctx context.Context, persona string, messages []models.Message) (*string, error) {
+	response, err := r.assistant.Chat(ctx, persona, messages)
+
+	return response, r.record(ctx, Interaction{Method: MethodChat, Persona: persona, Messages: messages}, response, err)
+}
+
+func (r *Recorder) StructuredAsk(ctx context.Context, persona string, request string, schema map[string]any) (json.RawMessage, error) {
+	response, err := r.assistant.StructuredAsk(ctx, persona, request, schema)
+
+	return response, r.record(ctx, Interaction{Method: MethodStructuredAsk, Persona: persona, Request: request, Schema: schema}, response, err)
+}
+
+// WithModel selects the model on the recorded assistant and notes it in the
+// recording.
+func (r *Recorder) WithModel(ctx context.Context, model string) context.Context {
+	ctx = context.WithValue(ctx, modelKey{}, model)
+	return r.assistant.WithModel(ctx, model)
+}
+
+// record appends the interaction and saves the cassette. It returns the
+// call's error, or the error saving the cassette if the call succeeded.
+func (r *Recorder) record(ctx context.Context, interaction Interaction, response any, err error) error {
+	interaction.Model, _ = ctx.Value(modelKey{}).(string)
+
+	if err != nil {
+		interaction.Error = err.Error()
+		interaction.Outcome = models.Outcome(err)
+	} else {
+		data, marshalErr := json.Marshal(response)
+		if marshalErr != nil {
+			return marshalErr
+		}
+
+		interaction.Response = data
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.cassette.Interactions = append(r.cassette.Interactions, interaction)
+
+	if saveErr := r.cassette.Save(r.path); saveErr != nil && err == nil {
+		return saveErr
+	}
+
+	return err
+}
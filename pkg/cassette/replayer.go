@@ -0,0 +1,230 @@
+package cassette
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+
+	"github.com/schraf/assistant/pkg/models"
+)
+
+var ErrNoInteraction = errors.New("no recorded interaction matches")
+
+// Match sets how closely a call must resemble a recorded interaction to be
+// answered by it. The method must always match.
+type Match int
+
+const (
+	// MatchStrict compares the model, persona, request, messages and schema.
+	MatchStrict Match = iota
+	// MatchRequest compares the request, messages and schema, so prompts
+	// can be reworded and models changed without re-recording.
+	MatchRequest
+	// MatchSequence ignores the content of calls and serves the recorded
+	// interactions in order.
+	MatchSequence
+)
+
+// Replayer is an assistant that answers calls from a cassette without
+// contacting a provider. Each interaction answers one call, in the order
+// they were recorded, so repeated identical calls get the responses that
+// were recorded for them. Calls with no unused match fail with
+// ErrNoInteraction.
+type Replayer struct {
+	match Match
+
+	lock         sync.Mutex
+	interactions []Interaction
+	used         []bool
+}
+
+// NewReplayer returns a Replayer serving the cassette at path.
+func NewReplayer(path string, match Match) (*Replayer, error) {
+	cassette, err := Load(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewReplayerFromCassette(cassette, match), nil
+}
+
+// NewReplayerFromCassette returns a Replayer serving a cassette in memory.
+func NewReplayerFromCassette(cassette *Cassette, match Match) *Replayer {
+	return &Replayer{
+		match:        match,
+		interactions: cassette.Interactions,
+		used:         make([]bool, len(cassette.Interactions)),
+	}
+}
+
+// Unused returns the recorded interactions no call has used, which tests
+// can check to notice a generator making fewer calls than it used to.
+func (r *Replayer) Unused() []Interaction {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	var unused []Interaction
+
+	for i, interaction := range r.interactions {
+		if !r.used[i] {
+			unused = append(unused, interaction)
+		}
+	}
+
+	return unused
+}
+
+func (r *Replayer) Ask(ctx context.Context, persona string, request string) (*string, error) {
+	var response *string
+
+	err := r.replay(ctx, Interaction{Method: MethodAsk, Persona: persona, Request: request}, &response)
+	if err != nil {
+		return nil, err
+	}
+
+	return response, nil
+}
+
+func (r *Replayer) AskWithSources(ctx context.Context, persona string, request string) (*models.Answer, error) {
+	var answer *models.Answer
+
+	err := r.replay(ctx, Interaction{Method: MethodAskWithSources, Persona: persona, Request: request}, &answer)
+	if err != nil {
+		return nil, err
+	}
+
+	return answer, nil
+}
+
+// AskStream passes the recorded response to the handler as a single chunk.
+func (r *Replayer) AskStream(ctx context.Context, persona string, request string, handler models.StreamHandler) (*string, error) {
+	var response *string
+
+	err := r.replay(ctx, Interaction{Method: MethodAskStream, Persona: persona, Request: request}, &response)
+	if err != nil {
+		return nil, err
+	}
+
+	if response != nil {
+		if err := handler(*response); err != nil {
+			return nil, err
+		}
+	}
+
+	return response, nil
+}
+
+func (r *Replayer) Chat(ctx context.Context, persona string, messages []models.Message) (*string, error) {
+	var response *string
+
+	err := r.replay(ctx, Interaction{Method: MethodChat, Persona: persona, Messages: messages}, &response)
+	if err != nil {
+		return nil, err
+	}
+
+	return response, nil
+}
+
+func (r *Replayer) StructuredAsk(ctx context.Context, persona string, request string, schema map[string]any) (json.RawMessage, error) {
+	var response json.RawMessage
+
+	err := r.replay(ctx, Interaction{Method: MethodStructuredAsk, Persona: persona, Request: request, Schema: schema}, &response)
+	if err != nil {
+		return nil, err
+	}
+
+	return response, nil
+}
+
+// WithModel returns a context with the model set, which MatchStrict
+// compares with the recorded model.
+func (r *Replayer) WithModel(ctx context.Context, model string) context.Context {
+	return context.WithValue(ctx, modelKey{}, model)
+}
+
+// replay finds the first unused interaction matching call and decodes its
+// response, or returns its recorded error.
+func (r *Replayer) replay(ctx context.Context, call Interaction, response any) error {
+	call.Model, _ = ctx.Value(modelKey{}).(string)
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	for i, interaction := range r.interactions {
+		if r.used[i] || !r.matches(interaction, call) {
+			continue
+		}
+
+		r.used[i] = true
+
+		if interaction.Error != "" {
+			return models.OutcomeError(interaction.Outcome, interaction.Error)
+		}
+
+		if err := json.Unmarshal(interaction.Response, response); err != nil {
+			return fmt.Errorf("failed to decode recorded response: %w", err)
+		}
+
+		return nil
+	}
+
+	return fmt.Errorf("%w: %s of %q", ErrNoInteraction, call.Method, summary(call))
+}
+
+func (r *Replayer) matches(recorded Interaction, call Interaction) bool {
+	if recorded.Method != call.Method {
+		return false
+	}
+
+	switch r.match {
+	case MatchSequence:
+		return true
+	case MatchStrict:
+		if recorded.Model != call.Model || recorded.Persona != call.Persona {
+			return false
+		}
+	}
+
+	return recorded.Request == call.Request &&
+		slices.Equal(recorded.Messages, call.Messages) &&
+		sameSchema(recorded.Schema, call.Schema)
+}
+
+// sameSchema compares schemas through JSON, as a schema read from a file
+// has been through a round trip that turns ints into floats and slices into
+// []any.
+func sameSchema(recorded map[string]any, schema map[string]any) bool {
+	if recorded == nil || schema == nil {
+		return recorded == nil && schema == nil
+	}
+
+	a, err := json.Marshal(recorded)
+	if err != nil {
+		return false
+	}
+
+	b, err := json.Marshal(schema)
+	if err != nil {
+		return false
+	}
+
+	return bytes.Equal(a, b)
+}
+
+// summary shortens a call for error messages.
+func summary(call Interaction) string {
+	text := call.Request
+	if text == "" && len(call.Messages) > 0 {
+		text = call.Messages[len(call.Messages)-1].Content
+	}
+
+	if len(text) > 80 {
+		text = text[:80] + "..."
+	}
+
+	return text
+}
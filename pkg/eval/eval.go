@@ -4,37 +4,49 @@ import (
 	"context"
 	"fmt"
 	"os"
-	"strings"
+	"strconv"
 	"time"
 
-	"github.com/schraf/assistant/internal/gemini"
-	"github.com/schraf/assistant/internal/mocks"
-	"github.com/schraf/assistant/internal/ollama"
+	_ "github.com/schraf/assistant/internal/gemini"
+	_ "github.com/schraf/assistant/internal/mocks"
+	_ "github.com/schraf/assistant/internal/ollama"
+	_ "github.com/schraf/assistant/internal/openai"
 	"github.com/schraf/assistant/pkg/models"
+	"github.com/schraf/assistant/pkg/providers"
 )
 
-func newAssistant(ctx context.Context) (models.Assistant, error) {
-	provider := strings.ToLower(os.Getenv("ASSISTANT_PROVIDER"))
-	switch provider {
-	case "mock":
-		return &mocks.MockAssistant{}, nil
-	case "ollama":
-		return ollama.NewClient(ctx)
-	case "gemini", "":
-		return gemini.NewClient(ctx, 3)
-	default:
-		return nil, fmt.Errorf("unknown assistant provider: %s", provider)
-	}
-}
-
 func Evaluate(ctx context.Context, generator models.ContentGenerator, request models.ContentRequest, model *string) error {
-	assistant, err := newAssistant(ctx)
+	providerName, providerConfig := providers.FromEnv()
+
+	assistant, err := providers.Create(ctx, providerName, providerConfig)
 	if err != nil {
 		return fmt.Errorf("failed creating assistant client: %w", err)
 	}
 
+	modelTables, err := providers.LoadModelTables()
+	if err != nil {
+		return fmt.Errorf("failed loading model tables: %w", err)
+	}
+
+	assistant = providers.WithModelTable(assistant, modelTables[providerName])
+
+	// Resolved before caching hides the provider's models
+	var modelName string
+
 	if model != nil {
-		ctx = assistant.WithModel(ctx, *model)
+		modelName, err = providers.ResolveModel(assistant, *model)
+		if err != nil {
+			return fmt.Errorf("invalid model: %w", err)
+		}
+	}
+
+	assistant, ctx, err = withCache(ctx, assistant, providerName)
+	if err != nil {
+		return err
+	}
+
+	if model != nil {
+		ctx = assistant.WithModel(ctx, modelName)
 	}
 
 	doc, err := generator.Generate(ctx, request, assistant)
@@ -62,3 +74,46 @@ func Evaluate(ctx context.Context, generator models.ContentGenerator, request mo
 	fmt.Printf("\n--- %d characters\n", doc.Length())
 	return nil
 }
+
+// withCache wraps the assistant in a response cache when ASSISTANT_CACHE_DIR
+// is set, so re-running a generator only pays for the calls that changed.
+// ASSISTANT_CACHE_TTL limits how long responses are reused and
+// ASSISTANT_CACHE_BYPASS=true refreshes them.
+func withCache(ctx context.Context, assistant models.Assistant, providerName string) (models.Assistant, context.Context, error) {
+	dir := os.Getenv("ASSISTANT_CACHE_DIR")
+	if dir == "" {
+		return assistant, ctx, nil
+	}
+
+	options := providers.CacheOptions{
+		Dir:      dir,
+		Provider: providerName,
+	}
+
+	if value := os.Getenv("ASSISTANT_CACHE_TTL"); value != "" {
+		ttl, err := time.ParseDuration(value)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid ASSISTANT_CACHE_TTL: %w", err)
+		}
+
+		options.TTL = ttl
+	}
+
+	if value := os.Getenv("ASSISTANT_CACHE_BYPASS"); value != "" {
+		bypass, err := strconv.ParseBool(value)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid ASSISTANT_CACHE_BYPASS: %w", err)
+		}
+
+		if bypass {
+			ctx = providers.WithCacheBypass(ctx)
+		}
+	}
+
+	cached, err := providers.WithCache(assistant, options)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return cached, ctx, nil
+}
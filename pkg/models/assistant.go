@@ -8,10 +8,26 @@ import (
 
 var (
 	ErrContentBlocked = errors.New("content blocked")
+	ErrBudgetExceeded = errors.New("budget exceeded")
 )
 
+// StreamHandler receives each chunk of text as it is generated. Returning an
+// error stops the stream and the error is returned from AskStream.
+type StreamHandler func(chunk string) error
+
+// Answer is a text response together with the sources it was grounded on.
+type Answer struct {
+	Text       string      `json:"text"`
+	References []Reference `json:"references,omitempty"`
+}
+
 type Assistant interface {
 	Ask(ctx context.Context, persona string, request string) (*string, error)
+	// AskWithSources is Ask that also returns the sources the response was
+	// grounded on. Providers without grounding return no references.
+	AskWithSources(ctx context.Context, persona string, request string) (*Answer, error)
+	AskStream(ctx context.Context, persona string, request string, handler StreamHandler) (*string, error)
+	Chat(ctx context.Context, persona string, messages []Message) (*string, error)
 	StructuredAsk(ctx context.Context, persona string, request string, schema map[string]any) (json.RawMessage, error)
 	WithModel(ctx context.Context, model string) context.Context
 }
@@ -0,0 +1,53 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var ErrAttachmentsUnsupported = errors.New("attachments are not supported")
+
+// Attachment is a file sent to the model along with a request, such as a
+// screenshot or a PDF. Data is encoded as base64 in JSON.
+type Attachment struct {
+	Name     string `json:"name,omitempty"`
+	MIMEType string `json:"mime_type"`
+	Data     []byte `json:"data"`
+}
+
+// Validate checks the attachment has a MIME type and content.
+func (a Attachment) Validate() error {
+	if a.MIMEType == "" {
+		return fmt.Errorf("attachment %q has no MIME type", a.Name)
+	}
+
+	if len(a.Data) == 0 {
+		return fmt.Errorf("attachment %q is empty", a.Name)
+	}
+
+	return nil
+}
+
+// IsImage reports whether the attachment is an image.
+func (a Attachment) IsImage() bool {
+	return strings.HasPrefix(a.MIMEType, "image/")
+}
+
+type attachmentsKey struct{}
+
+// WithAttachments returns a context that sends the given files with the
+// request of Ask, AskWithSources, AskStream and StructuredAsk, and with the
+// last message of Chat. Calling it with no attachments removes any set
+// earlier, so a generator can attach a file to the calls reading it and
+// leave it off the rest.
+func WithAttachments(ctx context.Context, attachments ...Attachment) context.Context {
+	return context.WithValue(ctx, attachmentsKey{}, attachments)
+}
+
+// AttachmentsFromContext returns the attachments set on the context.
+func AttachmentsFromContext(ctx context.Context) []Attachment {
+	attachments, _ := ctx.Value(attachmentsKey{}).([]Attachment)
+	return attachments
+}
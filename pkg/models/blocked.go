@@ -0,0 +1,76 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var ErrResponseTruncated = errors.New("response truncated")
+
+// SafetyRating is a provider's rating of a prompt or response for one harm
+// category. Source is either "prompt" or "response".
+type SafetyRating struct {
+	Source      string `json:"source"`
+	Category    string `json:"category"`
+	Probability string `json:"probability"`
+	Blocked     bool   `json:"blocked,omitempty"`
+}
+
+// ContentBlockedError reports why the provider refused to answer. A blocked
+// prompt has a BlockReason, a blocked response has a FinishReason such as
+// SAFETY or RECITATION. It matches ErrContentBlocked with errors.Is.
+type ContentBlockedError struct {
+	BlockReason   string         `json:"block_reason,omitempty"`
+	FinishReason  string         `json:"finish_reason,omitempty"`
+	Message       string         `json:"message,omitempty"`
+	SafetyRatings []SafetyRating `json:"safety_ratings,omitempty"`
+}
+
+func (e *ContentBlockedError) Error() string {
+	var reasons []string
+
+	if e.BlockReason != "" {
+		reasons = append(reasons, "block reason "+e.BlockReason)
+	}
+
+	if e.FinishReason != "" {
+		reasons = append(reasons, "finish reason "+e.FinishReason)
+	}
+
+	for _, rating := range e.SafetyRatings {
+		if rating.Blocked {
+			reasons = append(reasons, fmt.Sprintf("%s %s blocked", rating.Source, rating.Category))
+		}
+	}
+
+	if e.Message != "" {
+		reasons = append(reasons, e.Message)
+	}
+
+	if len(reasons) == 0 {
+		return ErrContentBlocked.Error()
+	}
+
+	return fmt.Sprintf("%s: %s", ErrContentBlocked, strings.Join(reasons, ", "))
+}
+
+func (e *ContentBlockedError) Unwrap() error {
+	return ErrContentBlocked
+}
+
+// TruncatedError reports a response cut short by the output token limit.
+// Text holds the partial response. It matches ErrResponseTruncated with
+// errors.Is.
+type TruncatedError struct {
+	FinishReason string
+	Text         string
+}
+
+func (e *TruncatedError) Error() string {
+	return fmt.Sprintf("%s: finish reason %s after %d characters", ErrResponseTruncated, e.FinishReason, len(e.Text))
+}
+
+func (e *TruncatedError) Unwrap() error {
+	return ErrResponseTruncated
+}
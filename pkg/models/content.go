@@ -6,9 +6,13 @@ import (
 	"github.com/google/uuid"
 )
 
+// ContentRequest is a request for a document. Attachments are files the
+// caller sent with it; generators pass them to the calls that need them with
+// WithAttachments.
 type ContentRequest struct {
-	Id   uuid.UUID
-	Body map[string]any
+	Id          uuid.UUID
+	Body        map[string]any
+	Attachments []Attachment
 }
 
 type ContentGenerator interface {
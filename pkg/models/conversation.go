@@ -0,0 +1,78 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"slices"
+)
+
+// Role identifies who authored a message in a conversation.
+type Role string
+
+const (
+	RoleUser  Role = "user"
+	RoleModel Role = "model"
+)
+
+// Message is a single turn of a conversation.
+type Message struct {
+	Role    Role   `json:"role"`
+	Content string `json:"content"`
+}
+
+var ErrInvalidConversation = errors.New("conversation must end with a user message")
+
+// ValidateMessages checks that messages form a conversation an assistant can
+// reply to, which means it is not empty and ends with a user turn.
+func ValidateMessages(messages []Message) error {
+	if len(messages) == 0 || messages[len(messages)-1].Role != RoleUser {
+		return ErrInvalidConversation
+	}
+
+	return nil
+}
+
+// Conversation keeps the message history of a multi-turn exchange with an
+// assistant, so generators can run draft, critique and revise loops without
+// folding everything into one prompt. A Conversation is not safe for
+// concurrent use.
+type Conversation struct {
+	assistant Assistant
+	persona   string
+	messages  []Message
+}
+
+// NewConversation starts an empty conversation with the given persona.
+func NewConversation(assistant Assistant, persona string) *Conversation {
+	return &Conversation{
+		assistant: assistant,
+		persona:   persona,
+	}
+}
+
+// Send adds a user message, asks the assistant for a reply and adds the
+// reply to the history. If the assistant fails the history is unchanged, so
+// the message can be retried.
+func (c *Conversation) Send(ctx context.Context, message string) (*string, error) {
+	messages := append(slices.Clone(c.messages), Message{
+		Role:    RoleUser,
+		Content: message,
+	})
+
+	reply, err := c.assistant.Chat(ctx, c.persona, messages)
+	if err != nil {
+		return nil, err
+	}
+
+	c.messages = append(messages, Message{
+		Role:    RoleModel,
+		Content: *reply,
+	})
+
+	return reply, nil
+}
+
+// History returns a copy of the messages exchanged so far.
+func (c *Conversation) History() []Message {
+	return slices.Clone(c.messages)
+}
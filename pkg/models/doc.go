@@ -1,16 +1,29 @@
 package models
 
-import "github.com/schraf/assistant/internal/content"
+import (
+	"slices"
+
+	"github.com/schraf/assistant/internal/content"
+)
 
 type DocumentSection struct {
 	Title      string   `json:"title"`
 	Paragraphs []string `json:"paragraphs"`
 }
 
+// Reference is a source a document was grounded on. Supports lists the
+// passages of the response that the source backs.
+type Reference struct {
+	Title    string   `json:"title"`
+	URL      string   `json:"url"`
+	Supports []string `json:"supports,omitempty"`
+}
+
 type Document struct {
-	Title    string            `json:"title"`
-	Author   string            `json:"author"`
-	Sections []DocumentSection `json:"sections"`
+	Title      string            `json:"title"`
+	Author     string            `json:"author"`
+	Sections   []DocumentSection `json:"sections"`
+	References []Reference       `json:"references,omitempty"`
 }
 
 func (d Document) Length() int {
@@ -38,6 +51,20 @@ func (d *Document) AddSection(title string, body string) *DocumentSection {
 	return &d.Sections[index]
 }
 
+// AddReferences appends references, skipping any whose URL the document
+// already references.
+func (d *Document) AddReferences(references ...Reference) {
+	for _, reference := range references {
+		if reference.URL == "" || slices.ContainsFunc(d.References, func(existing Reference) bool {
+			return existing.URL == reference.URL
+		}) {
+			continue
+		}
+
+		d.References = append(d.References, reference)
+	}
+}
+
 func (d *Document) Clean() {
 	d.Title = content.CleanText(d.Title)
 	d.Author = content.CleanText(d.Author)
@@ -48,4 +75,8 @@ func (d *Document) Clean() {
 			d.Sections[i].Paragraphs[j] = content.CleanText(d.Sections[i].Paragraphs[j])
 		}
 	}
+
+	for i := range d.References {
+		d.References[i].Title = content.CleanText(d.References[i].Title)
+	}
 }
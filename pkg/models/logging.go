@@ -0,0 +1,109 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+)
+
+// Logging returns a middleware that logs every call with its method, model,
+// latency, request and response sizes, outcome and the request id set with
+// WithRequestID. Successful calls are logged at info level and failed ones
+// as warnings.
+func Logging(logger *slog.Logger) Middleware {
+	return Intercept(func(ctx context.Context, call *Call, next func(ctx context.Context) error) error {
+		start := time.Now()
+		err := next(ctx)
+
+		attrs := []slog.Attr{
+			slog.String("method", call.Method),
+			slog.String("model", call.Model),
+			slog.Duration("latency", time.Since(start)),
+			slog.Int("request_chars", callSize(call)),
+			slog.Int("response_chars", len(call.Response)),
+			slog.String("outcome", Outcome(err)),
+		}
+
+		if id := RequestIDFromContext(ctx); id != "" {
+			attrs = append(attrs, slog.String("request_id", id))
+		}
+
+		level := slog.LevelInfo
+
+		if err != nil {
+			level = slog.LevelWarn
+			attrs = append(attrs, slog.String("error", err.Error()))
+		}
+
+		logger.LogAttrs(ctx, level, "assistant_call", attrs...)
+
+		return err
+	})
+}
+
+// Outcome names the result of a call for logs and metrics: "ok",
+// "content_blocked", "truncated", "schema_invalid", "budget_exceeded",
+// "canceled" or "error".
+func Outcome(err error) string {
+	switch {
+	case err == nil:
+		return "ok"
+	case errors.Is(err, ErrContentBlocked):
+		return "content_blocked"
+	case errors.Is(err, ErrResponseTruncated):
+		return "truncated"
+	case errors.Is(err, ErrSchemaValidation):
+		return "schema_invalid"
+	case errors.Is(err, ErrBudgetExceeded):
+		return "budget_exceeded"
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return "canceled"
+	default:
+		return "error"
+	}
+}
+
+// outcomeErrors are the errors named by outcomes, so OutcomeError can wrap
+// them again.
+var outcomeErrors = map[string]error{
+	"content_blocked": ErrContentBlocked,
+	"truncated":       ErrResponseTruncated,
+	"schema_invalid":  ErrSchemaValidation,
+	"budget_exceeded": ErrBudgetExceeded,
+}
+
+// OutcomeError rebuilds a recorded failure from its message and Outcome.
+// When the outcome names an error such as ErrContentBlocked, the result
+// wraps it so errors.Is still matches on replay.
+func OutcomeError(outcome string, message string) error {
+	if sentinel, ok := outcomeErrors[outcome]; ok {
+		return &outcomeError{message: message, err: sentinel}
+	}
+
+	return errors.New(message)
+}
+
+type outcomeError struct {
+	message string
+	err     error
+}
+
+func (e *outcomeError) Error() string {
+	return e.message
+}
+
+func (e *outcomeError) Unwrap() error {
+	return e.err
+}
+
+// callSize is the length of the prompt text sent with a call.
+func callSize(call *Call) int {
+	size := len(call.Persona) + len(call.Request)
+
+	for _, message := range call.Messages {
+		size += len(message.Content)
+	}
+
+	return size
+}
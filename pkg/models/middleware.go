@@ -0,0 +1,212 @@
+package models
+
+import (
+	"context"
+	"encoding/json"
+)
+
+// Middleware wraps an assistant to add behaviour around its calls, such as
+// logging, metrics or tracing.
+type Middleware func(next Assistant) Assistant
+
+// Chain wraps assistant in the middleware. The first middleware is the
+// outermost, so it sees each call first and its result last.
+func Chain(assistant Assistant, middleware ...Middleware) Assistant {
+	for i := len(middleware) - 1; i >= 0; i-- {
+		assistant = middleware[i](assistant)
+	}
+
+	return assistant
+}
+
+// Call methods reported to interceptors.
+const (
+	MethodAsk            = "ask"
+	MethodAskWithSources = "ask_with_sources"
+	MethodAskStream      = "ask_stream"
+	MethodChat           = "chat"
+	MethodStructuredAsk  = "structured_ask"
+)
+
+// Call describes an assistant call to an Interceptor. Model is the model
+// chosen with WithModel, if any. Response is set once the call returns: the
+// response text, or the JSON of a structured call.
+type Call struct {
+	Method   string
+	Model    string
+	Persona  string
+	Request  string
+	Messages []Message
+	Schema   map[string]any
+	Response string
+}
+
+// Interceptor runs around every call of an assistant. It must call next to
+// run the call, and may change the context it is run with or the error it
+// returns.
+type Interceptor func(ctx context.Context, call *Call, next func(ctx context.Context) error) error
+
+// Intercept returns a middleware running interceptor around every call, so
+// cross-cutting behaviour does not have to implement each Assistant method.
+func Intercept(interceptor Interceptor) Middleware {
+	return func(next Assistant) Assistant {
+		return &interceptedAssistant{
+			next:        next,
+			interceptor: interceptor,
+		}
+	}
+}
+
+type interceptModelKey struct{}
+
+type interceptedAssistant struct {
+	next        Assistant
+	interceptor Interceptor
+}
+
+func (a *interceptedAssistant) Ask(ctx context.Context, persona string, request string) (*string, error) {
+	call := newCall(ctx, MethodAsk, persona, request)
+
+	var response *string
+
+	err := a.interceptor(ctx, call, func(ctx context.Context) error {
+		var err error
+
+		response, err = a.next.Ask(ctx, persona, request)
+		if response != nil {
+			call.Response = *response
+		}
+
+		return err
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return response, nil
+}
+
+func (a *interceptedAssistant) AskWithSources(ctx context.Context, persona string, request string) (*Answer, error) {
+	call := newCall(ctx, MethodAskWithSources, persona, request)
+
+	var answer *Answer
+
+	err := a.interceptor(ctx, call, func(ctx context.Context) error {
+		var err error
+
+		answer, err = a.next.AskWithSources(ctx, persona, request)
+		if answer != nil {
+			call.Response = answer.Text
+		}
+
+		return err
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return answer, nil
+}
+
+func (a *interceptedAssistant) AskStream(ctx context.Context, persona string, request string, handler StreamHandler) (*string, error) {
+	call := newCall(ctx, MethodAskStream, persona, request)
+
+	var response *string
+
+	err := a.interceptor(ctx, call, func(ctx context.Context) error {
+		var err error
+
+		response, err = a.next.AskStream(ctx, persona, request, handler)
+		if response != nil {
+			call.Response = *response
+		}
+
+		return err
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return response, nil
+}
+
+func (a *interceptedAssistant) Chat(ctx context.Context, persona string, messages []Message) (*string, error) {
+	call := newCall(ctx, MethodChat, persona, "")
+	call.Messages = messages
+
+	var response *string
+
+	err := a.interceptor(ctx, call, func(ctx context.Context) error {
+		var err error
+
+		response, err = a.next.Chat(ctx, persona, messages)
+		if response != nil {
+			call.Response = *response
+		}
+
+		return err
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return response, nil
+}
+
+func (a *interceptedAssistant) StructuredAsk(ctx context.Context, persona string, request string, schema map[string]any) (json.RawMessage, error) {
+	call := newCall(ctx, MethodStructuredAsk, persona, request)
+	call.Schema = schema
+
+	var response json.RawMessage
+
+	err := a.interceptor(ctx, call, func(ctx context.Context) error {
+		var err error
+
+		response, err = a.next.StructuredAsk(ctx, persona, request, schema)
+		call.Response = string(response)
+
+		return err
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return response, nil
+}
+
+// WithModel selects the model on the wrapped assistant and remembers it for
+// Call.Model.
+func (a *interceptedAssistant) WithModel(ctx context.Context, model string) context.Context {
+	ctx = context.WithValue(ctx, interceptModelKey{}, model)
+	return a.next.WithModel(ctx, model)
+}
+
+func newCall(ctx context.Context, method string, persona string, request string) *Call {
+	model, _ := ctx.Value(interceptModelKey{}).(string)
+
+	return &Call{
+		Method:  method,
+		Model:   model,
+		Persona: persona,
+		Request: request,
+	}
+}
+
+type requestIDKey struct{}
+
+// WithRequestID returns a context carrying the id of the content request
+// being processed, so interceptors can tag what they report with it.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestIDFromContext returns the request id set on the context, or "".
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
@@ -0,0 +1,90 @@
+package models
+
+import "context"
+
+// GenerationOptions tune how a model generates a response. Nil fields are
+// left to the provider's defaults.
+type GenerationOptions struct {
+	Temperature     *float64 `json:"temperature,omitempty"`
+	TopP            *float64 `json:"top_p,omitempty"`
+	MaxOutputTokens *int     `json:"max_output_tokens,omitempty"`
+	Seed            *int     `json:"seed,omitempty"`
+	StopSequences   []string `json:"stop_sequences,omitempty"`
+	ThinkingBudget  *int     `json:"thinking_budget,omitempty"`
+}
+
+// merge returns a copy of o with every option set in other applied on top.
+func (o GenerationOptions) merge(other GenerationOptions) GenerationOptions {
+	if other.Temperature != nil {
+		o.Temperature = other.Temperature
+	}
+
+	if other.TopP != nil {
+		o.TopP = other.TopP
+	}
+
+	if other.MaxOutputTokens != nil {
+		o.MaxOutputTokens = other.MaxOutputTokens
+	}
+
+	if other.Seed != nil {
+		o.Seed = other.Seed
+	}
+
+	if other.StopSequences != nil {
+		o.StopSequences = other.StopSequences
+	}
+
+	if other.ThinkingBudget != nil {
+		o.ThinkingBudget = other.ThinkingBudget
+	}
+
+	return o
+}
+
+type generationOptionsKey struct{}
+
+// WithGenerationOptions returns a context with the given options applied on
+// top of any already set, so generators can override individual options for
+// a sub-task while keeping the rest.
+func WithGenerationOptions(ctx context.Context, options GenerationOptions) context.Context {
+	merged := GenerationOptionsFromContext(ctx).merge(options)
+	return context.WithValue(ctx, generationOptionsKey{}, merged)
+}
+
+// GenerationOptionsFromContext returns the options set on the context.
+func GenerationOptionsFromContext(ctx context.Context) GenerationOptions {
+	options, _ := ctx.Value(generationOptionsKey{}).(GenerationOptions)
+	return options
+}
+
+// WithTemperature returns a context with the sampling temperature set.
+func WithTemperature(ctx context.Context, temperature float64) context.Context {
+	return WithGenerationOptions(ctx, GenerationOptions{Temperature: &temperature})
+}
+
+// WithTopP returns a context with nucleus sampling set.
+func WithTopP(ctx context.Context, topP float64) context.Context {
+	return WithGenerationOptions(ctx, GenerationOptions{TopP: &topP})
+}
+
+// WithMaxOutputTokens returns a context with the response length capped.
+func WithMaxOutputTokens(ctx context.Context, maxOutputTokens int) context.Context {
+	return WithGenerationOptions(ctx, GenerationOptions{MaxOutputTokens: &maxOutputTokens})
+}
+
+// WithSeed returns a context with the sampling seed set.
+func WithSeed(ctx context.Context, seed int) context.Context {
+	return WithGenerationOptions(ctx, GenerationOptions{Seed: &seed})
+}
+
+// WithStopSequences returns a context with the stop sequences set.
+func WithStopSequences(ctx context.Context, stopSequences ...string) context.Context {
+	return WithGenerationOptions(ctx, GenerationOptions{StopSequences: stopSequences})
+}
+
+// WithThinkingBudget returns a context with the thinking token budget set.
+// A budget of zero disables thinking on models that allow it.
+func WithThinkingBudget(ctx context.Context, thinkingBudget int) context.Context {
+	return WithGenerationOptions(ctx, GenerationOptions{ThinkingBudget: &thinkingBudget})
+}
@@ -0,0 +1,253 @@
+package models
+
+import (
+	"context"
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// StructuredAskAs asks for a response matching the schema derived from T
+// with SchemaFor and decodes it into a T.
+func StructuredAskAs[T any](ctx context.Context, assistant Assistant, persona string, request string) (*T, error) {
+	response, err := assistant.StructuredAsk(ctx, persona, request, SchemaFor[T]())
+	if err != nil {
+		return nil, err
+	}
+
+	var value T
+	if err := json.Unmarshal(response, &value); err != nil {
+		return nil, fmt.Errorf("failed to decode structured response: %w", err)
+	}
+
+	return &value, nil
+}
+
+// SchemaFor derives a JSON schema from T, which is normally a struct.
+//
+// Properties are named by their json tags, and fields tagged "-" or
+// unexported are skipped. Fields are required unless tagged omitempty, and
+// pointer fields may be null. time.Time and types implementing
+// encoding.TextMarshaler, such as uuid.UUID, are strings, and byte slices are
+// base64 strings. The jsonschema tag refines a field with comma
+// separated options:
+//
+//	description=...   describes the field to the model
+//	enum=a|b|c        restricts the allowed values
+//	required          requires an omitempty field
+//	optional          does not require the field
+//	minItems=n        minimum length of a slice
+//	maxItems=n        maximum length of a slice
+//
+// Descriptions cannot contain commas.
+func SchemaFor[T any]() map[string]any {
+	return schemaOf(reflect.TypeFor[T](), map[reflect.Type]bool{})
+}
+
+var (
+	timeType          = reflect.TypeFor[time.Time]()
+	jsonMarshalerType = reflect.TypeFor[json.Marshaler]()
+	textMarshalerType = reflect.TypeFor[encoding.TextMarshaler]()
+)
+
+func schemaOf(t reflect.Type, visiting map[reflect.Type]bool) map[string]any {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	if t == timeType {
+		return map[string]any{"type": "string", "format": "date-time"}
+	}
+
+	// encoding/json writes text marshalers such as uuid.UUID as strings,
+	// unless they marshal themselves to JSON
+	if implements(t, textMarshalerType) && !implements(t, jsonMarshalerType) {
+		return map[string]any{"type": "string"}
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		// encoding/json writes byte slices as base64 strings
+		if t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8 {
+			return map[string]any{"type": "string", "contentEncoding": "base64"}
+		}
+
+		return map[string]any{"type": "array", "items": schemaOf(t.Elem(), visiting)}
+	case reflect.Map:
+		return map[string]any{"type": "object"}
+	case reflect.Struct:
+		// Recursive types are cut off rather than expanded forever
+		if visiting[t] {
+			return map[string]any{"type": "object"}
+		}
+
+		visiting[t] = true
+		defer delete(visiting, t)
+
+		properties := map[string]any{}
+		required := []string{}
+		addFields(t, properties, &required, visiting)
+
+		return map[string]any{
+			"type":       "object",
+			"properties": properties,
+			"required":   required,
+		}
+	}
+
+	// Interfaces and anything else accept any value
+	return map[string]any{}
+}
+
+// implements reports whether t or a pointer to t implements the interface.
+func implements(t reflect.Type, iface reflect.Type) bool {
+	return t.Implements(iface) || reflect.PointerTo(t).Implements(iface)
+}
+
+// addFields adds the fields of a struct, flattening embedded structs the way
+// encoding/json does.
+func addFields(t reflect.Type, properties map[string]any, required *[]string, visiting map[reflect.Type]bool) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+
+		name, omitempty, skip := jsonName(field)
+		if skip {
+			continue
+		}
+
+		if field.Anonymous && name == "" {
+			embedded := field.Type
+			if embedded.Kind() == reflect.Pointer {
+				embedded = embedded.Elem()
+			}
+
+			if embedded.Kind() == reflect.Struct {
+				// A struct embedding a pointer to itself is only flattened once
+				if !visiting[embedded] {
+					visiting[embedded] = true
+					addFields(embedded, properties, required, visiting)
+					delete(visiting, embedded)
+				}
+				continue
+			}
+		}
+
+		if !field.IsExported() {
+			continue
+		}
+
+		if name == "" {
+			name = field.Name
+		}
+
+		property := schemaOf(field.Type, visiting)
+		isRequired := !omitempty
+
+		for _, option := range strings.Split(field.Tag.Get("jsonschema"), ",") {
+			key, value, _ := strings.Cut(strings.TrimSpace(option), "=")
+
+			switch key {
+			case "description":
+				property["description"] = value
+			case "enum":
+				// The enum of a slice restricts its items
+				target := property
+				if items, ok := property["items"].(map[string]any); ok {
+					target = items
+				}
+
+				target["enum"] = enumValues(field.Type, strings.Split(value, "|"))
+			case "required":
+				isRequired = true
+			case "optional":
+				isRequired = false
+			case "minItems", "maxItems":
+				if n, err := strconv.Atoi(value); err == nil {
+					property[key] = n
+				}
+			}
+		}
+
+		// encoding/json writes nil pointers as null
+		if field.Type.Kind() == reflect.Pointer {
+			nullable(property)
+		}
+
+		properties[name] = property
+
+		if isRequired {
+			*required = append(*required, name)
+		}
+	}
+}
+
+// nullable lets a property be null as well as its own type.
+func nullable(property map[string]any) {
+	t, ok := property["type"].(string)
+	if !ok {
+		return
+	}
+
+	property["type"] = []string{t, "null"}
+
+	if enum, ok := property["enum"].([]any); ok {
+		property["enum"] = append(enum, nil)
+	}
+}
+
+// jsonName reads the json tag of a field.
+func jsonName(field reflect.StructField) (name string, omitempty bool, skip bool) {
+	tag := field.Tag.Get("json")
+	if tag == "-" {
+		return "", false, true
+	}
+
+	name, options, _ := strings.Cut(tag, ",")
+
+	for _, option := range strings.Split(options, ",") {
+		if option == "omitempty" || option == "omitzero" {
+			omitempty = true
+		}
+	}
+
+	return name, omitempty, false
+}
+
+// enumValues converts enum values to the field's type, so integer enums are
+// numbers in the schema.
+func enumValues(t reflect.Type, values []string) []any {
+	for t.Kind() == reflect.Pointer || t.Kind() == reflect.Slice || t.Kind() == reflect.Array {
+		t = t.Elem()
+	}
+
+	enum := make([]any, 0, len(values))
+
+	for _, value := range values {
+		switch t.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+			reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+			reflect.Float32, reflect.Float64:
+			if n, err := strconv.ParseFloat(value, 64); err == nil {
+				enum = append(enum, n)
+				continue
+			}
+		}
+
+		enum = append(enum, value)
+	}
+
+	return enum
+}
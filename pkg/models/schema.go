@@ -0,0 +1,49 @@
+package models
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// DefaultSchemaRepairs is how many times StructuredAsk re-prompts the model
+// with the validation errors before giving up.
+const DefaultSchemaRepairs = 2
+
+var ErrSchemaValidation = errors.New("response does not match schema")
+
+// SchemaValidationError is returned by StructuredAsk when the model's last
+// response still did not match the schema. Response holds that response.
+type SchemaValidationError struct {
+	Errors   []string
+	Response json.RawMessage
+	Attempts int
+}
+
+func (e *SchemaValidationError) Error() string {
+	return fmt.Sprintf("%s after %d attempts: %s", ErrSchemaValidation, e.Attempts, strings.Join(e.Errors, "; "))
+}
+
+func (e *SchemaValidationError) Unwrap() error {
+	return ErrSchemaValidation
+}
+
+type schemaRepairsKey struct{}
+
+// WithSchemaRepairs returns a context with the number of StructuredAsk
+// repair attempts set. Zero disables repairs but still validates.
+func WithSchemaRepairs(ctx context.Context, repairs int) context.Context {
+	return context.WithValue(ctx, schemaRepairsKey{}, repairs)
+}
+
+// SchemaRepairsFromContext returns the number of repair attempts set on the
+// context, or DefaultSchemaRepairs.
+func SchemaRepairsFromContext(ctx context.Context) int {
+	if repairs, ok := ctx.Value(schemaRepairsKey{}).(int); ok {
+		return repairs
+	}
+
+	return DefaultSchemaRepairs
+}
@@ -0,0 +1,11 @@
+package models
+
+// Model tiers are aliases that provider model tables map to concrete models.
+// Generators can pass a tier to Assistant.WithModel to pick a cheaper or
+// stronger model for a specific sub-task without naming a provider's model.
+const (
+	TierPro   = "pro"
+	TierBasic = "basic"
+	TierCheap = "cheap"
+	TierLocal = "local"
+)
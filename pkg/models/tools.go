@@ -0,0 +1,108 @@
+package models
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// DefaultMaxToolIterations bounds how many rounds of tool calls an assistant
+// will answer before giving up on a final response.
+const DefaultMaxToolIterations = 8
+
+var ErrToolIterationsExceeded = errors.New("tool call iterations exceeded")
+
+// ToolHandler runs a tool with the JSON arguments chosen by the model. The
+// result is encoded as JSON and returned to the model.
+type ToolHandler func(ctx context.Context, arguments json.RawMessage) (any, error)
+
+// Tool is a Go function exposed to the model. Parameters is a JSON schema
+// describing the arguments object.
+type Tool struct {
+	Name        string
+	Description string
+	Parameters  map[string]any
+	Handler     ToolHandler
+}
+
+type toolsKey struct{}
+
+type maxToolIterationsKey struct{}
+
+// WithTools returns a context that exposes the given tools to Ask and Chat.
+// The assistant answers the model's tool calls by running the handlers until
+// the model produces a final response. StructuredAsk and AskStream ignore
+// tools.
+func WithTools(ctx context.Context, tools ...Tool) context.Context {
+	return context.WithValue(ctx, toolsKey{}, tools)
+}
+
+// ToolsFromContext returns the tools set on the context.
+func ToolsFromContext(ctx context.Context) []Tool {
+	tools, _ := ctx.Value(toolsKey{}).([]Tool)
+	return tools
+}
+
+// WithMaxToolIterations returns a context with the tool call guard set.
+func WithMaxToolIterations(ctx context.Context, iterations int) context.Context {
+	return context.WithValue(ctx, maxToolIterationsKey{}, iterations)
+}
+
+// MaxToolIterationsFromContext returns the tool call guard set on the
+// context, or DefaultMaxToolIterations.
+func MaxToolIterationsFromContext(ctx context.Context) int {
+	if iterations, ok := ctx.Value(maxToolIterationsKey{}).(int); ok {
+		return iterations
+	}
+
+	return DefaultMaxToolIterations
+}
+
+// CallTool runs the named tool and returns the response to send back to the
+// model, either {"result": ...} or {"error": "..."}. Failures are reported to
+// the model rather than returned, so it can correct its arguments and retry.
+func CallTool(ctx context.Context, tools []Tool, name string, arguments json.RawMessage) map[string]any {
+	for _, tool := range tools {
+		if tool.Name != name {
+			continue
+		}
+
+		result, err := tool.Handler(ctx, arguments)
+		if err != nil {
+			return map[string]any{"error": err.Error()}
+		}
+
+		return map[string]any{"result": result}
+	}
+
+	return map[string]any{"error": fmt.Sprintf("unknown tool '%s'", name)}
+}
+
+// BuiltinTool is a tool the provider runs itself, such as web search. Only
+// Gemini offers built-in tools; other providers ignore them.
+type BuiltinTool string
+
+const (
+	BuiltinSearch        BuiltinTool = "search"
+	BuiltinURLContext    BuiltinTool = "url_context"
+	BuiltinCodeExecution BuiltinTool = "code_execution"
+)
+
+type builtinToolsKey struct{}
+
+// WithBuiltinTools returns a context that enables exactly the given built-in
+// tools for Ask, AskStream, Chat and StructuredAsk. Calling it with no tools
+// disables them all, which keeps deterministic prompts away from web search.
+// Without it, Ask, AskStream and Chat use search and URL context and
+// StructuredAsk uses none.
+func WithBuiltinTools(ctx context.Context, tools ...BuiltinTool) context.Context {
+	return context.WithValue(ctx, builtinToolsKey{}, append([]BuiltinTool{}, tools...))
+}
+
+// BuiltinToolsFromContext returns the built-in tools set on the context, and
+// whether any choice was made at all.
+func BuiltinToolsFromContext(ctx context.Context) ([]BuiltinTool, bool) {
+	tools, ok := ctx.Value(builtinToolsKey{}).([]BuiltinTool)
+	return tools, ok
+}
@@ -0,0 +1,293 @@
+package providers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/schraf/assistant/pkg/models"
+)
+
+// CacheOptions configure WithCache.
+type CacheOptions struct {
+	// Dir holds one file per cached response. It is created if needed.
+	Dir string
+	// TTL is how long a response stays valid. Zero keeps responses forever.
+	TTL time.Duration
+	// Provider is part of the cache key, so providers sharing a directory
+	// do not answer for each other.
+	Provider string
+}
+
+// WithCache wraps an assistant so that responses are stored on disk and
+// repeated calls with the same provider, model, generation options, tools,
+// persona, request and schema are answered from the cache. Failed calls are
+// not cached. Cached responses skip tool handlers and record no usage.
+func WithCache(assistant models.Assistant, options CacheOptions) (models.Assistant, error) {
+	if options.Dir == "" {
+		return nil, fmt.Errorf("cache directory is not set")
+	}
+
+	if err := os.MkdirAll(options.Dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create cache directory: %w", err)
+	}
+
+	return &cacheAssistant{
+		Assistant: assistant,
+		options:   options,
+	}, nil
+}
+
+type cacheBypassKey struct{}
+
+type cacheModelKey struct{}
+
+// WithCacheBypass returns a context whose calls skip cached responses. The
+// fresh responses still replace the cached ones.
+func WithCacheBypass(ctx context.Context) context.Context {
+	return context.WithValue(ctx, cacheBypassKey{}, true)
+}
+
+type cacheAssistant struct {
+	models.Assistant
+	options CacheOptions
+}
+
+// cacheKey holds everything that can change a response.
+type cacheKey struct {
+	Provider     string                   `json:"provider"`
+	Model        string                   `json:"model"`
+	Method       string                   `json:"method"`
+	Options      models.GenerationOptions `json:"options"`
+	Tools        []string                 `json:"tools,omitempty"`
+	BuiltinTools []models.BuiltinTool     `json:"builtin_tools"`
+	Persona      string                   `json:"persona"`
+	Request      string                   `json:"request,omitempty"`
+	Messages     []models.Message         `json:"messages,omitempty"`
+	Schema       map[string]any           `json:"schema,omitempty"`
+	Attachments  []string                 `json:"attachments,omitempty"`
+}
+
+type cacheEntry struct {
+	CreatedAt time.Time       `json:"created_at"`
+	Key       cacheKey        `json:"key"`
+	Response  json.RawMessage `json:"response"`
+}
+
+func (a *cacheAssistant) Ask(ctx context.Context, persona string, request string) (*string, error) {
+	return cached(ctx, a, a.key(ctx, "ask", persona, request), func() (*string, error) {
+		return a.Assistant.Ask(ctx, persona, request)
+	})
+}
+
+func (a *cacheAssistant) AskWithSources(ctx context.Context, persona string, request string) (*models.Answer, error) {
+	return cached(ctx, a, a.key(ctx, "ask_with_sources", persona, request), func() (*models.Answer, error) {
+		return a.Assistant.AskWithSources(ctx, persona, request)
+	})
+}
+
+// AskStream passes a cached response to the handler as a single chunk.
+func (a *cacheAssistant) AskStream(ctx context.Context, persona string, request string, handler models.StreamHandler) (*string, error) {
+	key := a.key(ctx, "ask", persona, request)
+
+	var response *string
+
+	if a.load(ctx, key, &response) && response != nil {
+		if err := handler(*response); err != nil {
+			return nil, err
+		}
+
+		return response, nil
+	}
+
+	response, err := a.Assistant.AskStream(ctx, persona, request, handler)
+	if err != nil {
+		return nil, err
+	}
+
+	a.store(ctx, key, response)
+
+	return response, nil
+}
+
+func (a *cacheAssistant) Chat(ctx context.Context, persona string, messages []models.Message) (*string, error) {
+	key := a.key(ctx, "chat", persona, "")
+	key.Messages = messages
+
+	return cached(ctx, a, key, func() (*string, error) {
+		return a.Assistant.Chat(ctx, persona, messages)
+	})
+}
+
+func (a *cacheAssistant) StructuredAsk(ctx context.Context, persona string, request string, schema map[string]any) (json.RawMessage, error) {
+	key := a.key(ctx, "structured_ask", persona, request)
+	key.Schema = schema
+
+	return cached(ctx, a, key, func() (json.RawMessage, error) {
+		return a.Assistant.StructuredAsk(ctx, persona, request, schema)
+	})
+}
+
+// WithModel remembers the model for the cache key as well as selecting it.
+func (a *cacheAssistant) WithModel(ctx context.Context, model string) context.Context {
+	ctx = context.WithValue(ctx, cacheModelKey{}, model)
+	return a.Assistant.WithModel(ctx, model)
+}
+
+func (a *cacheAssistant) key(ctx context.Context, method string, persona string, request string) cacheKey {
+	model, _ := ctx.Value(cacheModelKey{}).(string)
+
+	key := cacheKey{
+		Provider: a.options.Provider,
+		Model:    model,
+		Method:   method,
+		Options:  models.GenerationOptionsFromContext(ctx),
+		Persona:  persona,
+		Request:  request,
+	}
+
+	// Tools are keyed by a digest of their definition, so changing what the
+	// model is told about a tool invalidates the responses it gave before
+	for _, tool := range models.ToolsFromContext(ctx) {
+		definition, _ := json.Marshal([]any{tool.Description, tool.Parameters})
+		sum := sha256.Sum256(definition)
+		key.Tools = append(key.Tools, tool.Name+":"+hex.EncodeToString(sum[:]))
+	}
+
+	if builtins, ok := models.BuiltinToolsFromContext(ctx); ok {
+		key.BuiltinTools = append([]models.BuiltinTool{}, builtins...)
+	}
+
+	// Attachments are keyed by a digest of their content, so cache entries
+	// do not carry a copy of every file
+	for _, attachment := range models.AttachmentsFromContext(ctx) {
+		sum := sha256.Sum256(attachment.Data)
+		key.Attachments = append(key.Attachments, attachment.MIMEType+":"+hex.EncodeToString(sum[:]))
+	}
+
+	return key
+}
+
+func cached[T any](ctx context.Context, a *cacheAssistant, key cacheKey, call func() (T, error)) (T, error) {
+	var response T
+
+	if a.load(ctx, key, &response) {
+		return response, nil
+	}
+
+	response, err := call()
+	if err != nil {
+		return response, err
+	}
+
+	a.store(ctx, key, response)
+
+	return response, nil
+}
+
+func (a *cacheAssistant) path(key cacheKey) (string, error) {
+	data, err := json.Marshal(key)
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(data)
+
+	return filepath.Join(a.options.Dir, hex.EncodeToString(sum[:])+".json"), nil
+}
+
+// load reads a cached response into response and reports whether it found
+// one that has not expired.
+func (a *cacheAssistant) load(ctx context.Context, key cacheKey, response any) bool {
+	if bypass, _ := ctx.Value(cacheBypassKey{}).(bool); bypass {
+		return false
+	}
+
+	path, err := a.path(key)
+	if err != nil {
+		return false
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return false
+	}
+
+	var entry cacheEntry
+
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return false
+	}
+
+	if a.options.TTL > 0 && time.Since(entry.CreatedAt) > a.options.TTL {
+		return false
+	}
+
+	if err := json.Unmarshal(entry.Response, response); err != nil {
+		return false
+	}
+
+	slog.DebugContext(ctx, "cache_hit",
+		slog.String("method", key.Method),
+		slog.String("model", key.Model),
+	)
+
+	return true
+}
+
+// store writes a response to the cache. Failing to cache is logged and
+// otherwise ignored, the caller already has its response.
+func (a *cacheAssistant) store(ctx context.Context, key cacheKey, response any) {
+	if err := a.write(key, response); err != nil {
+		slog.WarnContext(ctx, "cache_write_failed",
+			slog.String("error", err.Error()),
+		)
+	}
+}
+
+func (a *cacheAssistant) write(key cacheKey, response any) error {
+	path, err := a.path(key)
+	if err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(response)
+	if err != nil {
+		return err
+	}
+
+	entry, err := json.MarshalIndent(cacheEntry{
+		CreatedAt: time.Now(),
+		Key:       key,
+		Response:  data,
+	}, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	// Written to a temporary file first so a concurrent reader never sees
+	// a partial entry
+	file, err := os.CreateTemp(a.options.Dir, "entry-*.tmp")
+	if err != nil {
+		return err
+	}
+
+	if _, err := file.Write(entry); err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return err
+	}
+
+	if err := file.Close(); err != nil {
+		os.Remove(file.Name())
+		return err
+	}
+
+	return os.Rename(file.Name(), path)
+}
@@ -0,0 +1,375 @@
+package providers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/schraf/assistant/internal/breaker"
+	"github.com/schraf/assistant/pkg/models"
+)
+
+const (
+	DefaultFailureThreshold = 3
+	DefaultCooldown         = time.Minute
+)
+
+var ErrAllProvidersFailed = errors.New("all providers failed")
+
+// Link is one entry of a failover chain. When Model is set the link always
+// uses it, otherwise it uses the model chosen with WithModel, if any.
+type Link struct {
+	Name      string
+	Assistant models.Assistant
+	Model     string
+}
+
+// FailoverOptions configure the circuit breakers of a failover chain.
+type FailoverOptions struct {
+	// FailureThreshold is the number of consecutive failures that open a
+	// link's circuit. Defaults to DefaultFailureThreshold.
+	FailureThreshold int
+	// Cooldown is how long an open circuit skips its link before a trial
+	// call is let through. Defaults to DefaultCooldown.
+	Cooldown time.Duration
+	// RetryBlocked passes prompts a provider refused on to the next link
+	// instead of returning the models.ErrContentBlocked error.
+	RetryBlocked bool
+}
+
+// NewFailover returns an assistant that sends each call to the first link
+// whose circuit is closed, falling through to the next link when a call
+// fails. Errors that another provider would repeat, such as an exceeded
+// budget or a truncated response, are returned without failing over.
+func NewFailover(links []Link, options FailoverOptions) (models.Assistant, error) {
+	if len(links) == 0 {
+		return nil, errors.New("failover chain has no providers")
+	}
+
+	if options.FailureThreshold <= 0 {
+		options.FailureThreshold = DefaultFailureThreshold
+	}
+
+	if options.Cooldown <= 0 {
+		options.Cooldown = DefaultCooldown
+	}
+
+	assistant := &failoverAssistant{
+		links:        make([]*failoverLink, 0, len(links)),
+		retryBlocked: options.RetryBlocked,
+	}
+
+	for _, link := range links {
+		if link.Assistant == nil {
+			return nil, fmt.Errorf("failover provider '%s' has no assistant", link.Name)
+		}
+
+		name := link.Name
+
+		assistant.links = append(assistant.links, &failoverLink{
+			Link: link,
+			breaker: &breaker.Breaker{
+				FailureThreshold: options.FailureThreshold,
+				Cooldown:         options.Cooldown,
+				OnStateChange: func(from breaker.State, to breaker.State) {
+					slog.Warn("circuit_state_changed",
+						slog.String("provider", name),
+						slog.String("from", from.String()),
+						slog.String("to", to.String()),
+					)
+				},
+			},
+		})
+	}
+
+	return assistant, nil
+}
+
+type failoverLink struct {
+	Link
+	breaker *breaker.Breaker
+}
+
+type failoverAssistant struct {
+	links        []*failoverLink
+	retryBlocked bool
+}
+
+type failoverModelKey struct{}
+
+func (a *failoverAssistant) Ask(ctx context.Context, persona string, request string) (*string, error) {
+	return failover(ctx, a, func(ctx context.Context, assistant models.Assistant) (*string, error) {
+		return assistant.Ask(ctx, persona, request)
+	})
+}
+
+func (a *failoverAssistant) AskWithSources(ctx context.Context, persona string, request string) (*models.Answer, error) {
+	return failover(ctx, a, func(ctx context.Context, assistant models.Assistant) (*models.Answer, error) {
+		return assistant.AskWithSources(ctx, persona, request)
+	})
+}
+
+// AskStream only fails over while nothing has been streamed, otherwise the
+// handler would see the start of the response twice.
+func (a *failoverAssistant) AskStream(ctx context.Context, persona string, request string, handler models.StreamHandler) (*string, error) {
+	return failover(ctx, a, func(ctx context.Context, assistant models.Assistant) (*string, error) {
+		var (
+			streamed   bool
+			handlerErr error
+		)
+
+		response, err := assistant.AskStream(ctx, persona, request, func(chunk string) error {
+			streamed = true
+			handlerErr = handler(chunk)
+			return handlerErr
+		})
+
+		if err != nil && streamed {
+			return nil, &stopError{err: err, healthy: handlerErr != nil}
+		}
+
+		return response, err
+	})
+}
+
+func (a *failoverAssistant) Chat(ctx context.Context, persona string, messages []models.Message) (*string, error) {
+	return failover(ctx, a, func(ctx context.Context, assistant models.Assistant) (*string, error) {
+		return assistant.Chat(ctx, persona, messages)
+	})
+}
+
+func (a *failoverAssistant) StructuredAsk(ctx context.Context, persona string, request string, schema map[string]any) (json.RawMessage, error) {
+	return failover(ctx, a, func(ctx context.Context, assistant models.Assistant) (json.RawMessage, error) {
+		return assistant.StructuredAsk(ctx, persona, request, schema)
+	})
+}
+
+// WithModel records the model for the links that are not pinned to one. The
+// model is passed to each link's own WithModel when it is called.
+func (a *failoverAssistant) WithModel(ctx context.Context, model string) context.Context {
+	return context.WithValue(ctx, failoverModelKey{}, model)
+}
+
+// ResolveModel checks the model against every link that is not pinned to a
+// model, since any of them may be asked to use it. The name is returned
+// unchanged, as each link resolves aliases through its own table.
+func (a *failoverAssistant) ResolveModel(model string) (string, error) {
+	for _, link := range a.links {
+		if link.Model != "" {
+			continue
+		}
+
+		if _, err := ResolveModel(link.Assistant, model); err != nil {
+			return "", fmt.Errorf("%s: %w", link.Name, err)
+		}
+	}
+
+	return model, nil
+}
+
+// stopError ends the chain with err. healthy is set when the provider is not
+// to blame, such as when a stream handler failed.
+type stopError struct {
+	err     error
+	healthy bool
+}
+
+func (e *stopError) Error() string {
+	return e.err.Error()
+}
+
+func (e *stopError) Unwrap() error {
+	return e.err
+}
+
+func failover[T any](ctx context.Context, a *failoverAssistant, call func(ctx context.Context, assistant models.Assistant) (T, error)) (T, error) {
+	var (
+		zero T
+		errs []error
+	)
+
+	model, _ := ctx.Value(failoverModelKey{}).(string)
+
+	for _, link := range a.links {
+		if !link.breaker.Allow() {
+			errs = append(errs, fmt.Errorf("%s: %w", link.Name, breaker.ErrOpen))
+			continue
+		}
+
+		linkCtx := ctx
+		if link.Model != "" {
+			linkCtx = link.Assistant.WithModel(ctx, link.Model)
+		} else if model != "" {
+			linkCtx = link.Assistant.WithModel(ctx, model)
+		}
+
+		result, err := call(linkCtx, link.Assistant)
+		if err == nil {
+			link.breaker.Success()
+			return result, nil
+		}
+
+		var stop *stopError
+
+		switch {
+		case errors.As(err, &stop):
+			// The chain ends either way, but only an outage counts against
+			// the provider
+			switch {
+			case stop.healthy || ctx.Err() != nil || isCallerError(stop.err):
+				link.breaker.Release()
+			case errors.Is(stop.err, models.ErrContentBlocked) || isResponseError(stop.err):
+				link.breaker.Success()
+			default:
+				link.breaker.Failure()
+			}
+			return zero, stop.err
+		case ctx.Err() != nil || isCallerError(err):
+			link.breaker.Release()
+			return zero, err
+		case errors.Is(err, models.ErrAttachmentsUnsupported):
+			// The provider is fine, another one may read the files
+			link.breaker.Release()
+		case errors.Is(err, models.ErrContentBlocked):
+			// The provider is up, it just would not answer this prompt
+			link.breaker.Success()
+
+			if !a.retryBlocked {
+				return zero, err
+			}
+		case isResponseError(err):
+			link.breaker.Success()
+			return zero, err
+		default:
+			link.breaker.Failure()
+		}
+
+		errs = append(errs, fmt.Errorf("%s: %w", link.Name, err))
+
+		slog.WarnContext(ctx, "provider_failed",
+			slog.String("provider", link.Name),
+			slog.String("error", err.Error()),
+		)
+	}
+
+	return zero, fmt.Errorf("%w: %w", ErrAllProvidersFailed, errors.Join(errs...))
+}
+
+// isCallerError reports errors caused by the request or its limits, which
+// every provider would fail the same way.
+func isCallerError(err error) bool {
+	return errors.Is(err, models.ErrBudgetExceeded) ||
+		errors.Is(err, models.ErrInvalidConversation)
+}
+
+// isResponseError reports errors about a response the provider did return.
+func isResponseError(err error) bool {
+	return errors.Is(err, models.ErrResponseTruncated) ||
+		errors.Is(err, models.ErrToolIterationsExceeded) ||
+		errors.Is(err, models.ErrSchemaValidation)
+}
+
+// ParseChain parses a comma separated failover chain of provider[:model]
+// entries, e.g. "gemini:pro,gemini:basic,ollama". Everything after the first
+// colon is the model, so Ollama tags such as "ollama:qwen3:32b" work.
+func ParseChain(chain string) ([]Link, []string, error) {
+	var (
+		links     []Link
+		providers []string
+	)
+
+	for _, entry := range strings.Split(chain, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		provider, model, _ := strings.Cut(entry, ":")
+		if provider == "" {
+			return nil, nil, fmt.Errorf("invalid failover entry '%s'", entry)
+		}
+
+		links = append(links, Link{Name: entry, Model: model})
+		providers = append(providers, provider)
+	}
+
+	if len(links) == 0 {
+		return nil, nil, errors.New("failover chain has no providers")
+	}
+
+	return links, providers, nil
+}
+
+func init() {
+	MustRegister("failover", newFailoverProvider)
+}
+
+// newFailoverProvider builds the chain from the "chain" config value. Each
+// provider is created once and shared by its links, and link models are
+// resolved through the provider's model table.
+func newFailoverProvider(ctx context.Context, config Config) (models.Assistant, error) {
+	chain, _ := config["chain"].(string)
+
+	links, names, err := ParseChain(chain)
+	if err != nil {
+		return nil, err
+	}
+
+	threshold, err := config.Int("failure_threshold", DefaultFailureThreshold)
+	if err != nil {
+		return nil, err
+	}
+
+	cooldown, err := config.Duration("cooldown", DefaultCooldown)
+	if err != nil {
+		return nil, err
+	}
+
+	retryBlocked, err := config.Bool("retry_blocked", false)
+	if err != nil {
+		return nil, err
+	}
+
+	tables, err := LoadModelTables()
+	if err != nil {
+		return nil, err
+	}
+
+	assistants := make(map[string]models.Assistant)
+
+	for i, name := range names {
+		if name == "failover" {
+			return nil, errors.New("failover chain cannot contain the failover provider")
+		}
+
+		assistant, ok := assistants[name]
+		if !ok {
+			assistant, err = Create(ctx, name, config)
+			if err != nil {
+				return nil, fmt.Errorf("failed creating failover provider '%s': %w", name, err)
+			}
+
+			assistant = WithModelTable(assistant, tables[name])
+			assistants[name] = assistant
+		}
+
+		if links[i].Model != "" {
+			links[i].Model, err = tables[name].Resolve(links[i].Model)
+			if err != nil {
+				return nil, fmt.Errorf("invalid failover model for '%s': %w", name, err)
+			}
+		}
+
+		links[i].Assistant = assistant
+	}
+
+	return NewFailover(links, FailoverOptions{
+		FailureThreshold: threshold,
+		Cooldown:         cooldown,
+		RetryBlocked:     retryBlocked,
+	})
+}
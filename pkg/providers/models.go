@@ -0,0 +1,217 @@
+package providers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/schraf/assistant/pkg/models"
+)
+
+// ModelTable maps model aliases, such as the tiers in pkg/models, to the
+// concrete model names of a provider. Models lists further concrete names
+// that may be requested directly.
+type ModelTable struct {
+	Aliases map[string]string `json:"aliases"`
+	Models  []string          `json:"models"`
+}
+
+// DefaultModelTables are the built-in tables for each provider. They can be
+// extended or overridden with the ASSISTANT_MODELS environment variable.
+var DefaultModelTables = map[string]ModelTable{
+	"gemini": {
+		Aliases: map[string]string{
+			models.TierPro:   "gemini-pro-latest",
+			models.TierBasic: "gemini-flash-latest",
+			models.TierCheap: "gemini-flash-lite-latest",
+		},
+		Models: []string{
+			"gemini-2.5-pro",
+			"gemini-2.5-flash",
+			"gemini-2.5-flash-lite",
+		},
+	},
+	"ollama": {
+		Aliases: map[string]string{
+			models.TierPro:   "llama3.2",
+			models.TierBasic: "llama3.2",
+			models.TierCheap: "llama3.2",
+			models.TierLocal: "llama3.2",
+		},
+	},
+	"openai": {
+		Aliases: map[string]string{
+			models.TierPro:   "gpt-4o",
+			models.TierBasic: "gpt-4o-mini",
+			models.TierCheap: "gpt-4o-mini",
+		},
+	},
+}
+
+// Lookup returns the model an alias maps to, or the name unchanged if it is
+// not an alias.
+func (t ModelTable) Lookup(model string) string {
+	if target, ok := t.Aliases[model]; ok {
+		return target
+	}
+
+	return model
+}
+
+// Resolve returns the concrete model for an alias or model name, failing for
+// names the table does not know. An empty table accepts any name, so
+// providers without a table keep passing model names straight through.
+func (t ModelTable) Resolve(model string) (string, error) {
+	if len(t.Aliases) == 0 && len(t.Models) == 0 {
+		return model, nil
+	}
+
+	if target, ok := t.Aliases[model]; ok {
+		return target, nil
+	}
+
+	if slices.Contains(t.Models, model) {
+		return model, nil
+	}
+
+	for _, target := range t.Aliases {
+		if target == model {
+			return model, nil
+		}
+	}
+
+	return "", fmt.Errorf("unknown model '%s'", model)
+}
+
+func (t ModelTable) merge(other ModelTable) ModelTable {
+	merged := ModelTable{
+		Aliases: make(map[string]string, len(t.Aliases)+len(other.Aliases)),
+		Models:  slices.Clone(t.Models),
+	}
+
+	for alias, model := range t.Aliases {
+		merged.Aliases[alias] = model
+	}
+
+	for alias, model := range other.Aliases {
+		merged.Aliases[alias] = model
+	}
+
+	for _, model := range other.Models {
+		if !slices.Contains(merged.Models, model) {
+			merged.Models = append(merged.Models, model)
+		}
+	}
+
+	return merged
+}
+
+// LoadModelTables returns DefaultModelTables merged with the tables from
+// ASSISTANT_MODELS, which holds either JSON or the path to a JSON file
+// keyed by provider name, e.g. {"ollama": {"aliases": {"pro": "qwen3:32b"}}}.
+func LoadModelTables() (map[string]ModelTable, error) {
+	tables := make(map[string]ModelTable, len(DefaultModelTables))
+	for name, table := range DefaultModelTables {
+		tables[name] = table.merge(ModelTable{})
+	}
+
+	source := strings.TrimSpace(os.Getenv("ASSISTANT_MODELS"))
+	if source == "" {
+		return tables, nil
+	}
+
+	data := []byte(source)
+
+	if !strings.HasPrefix(source, "{") {
+		var err error
+
+		data, err = os.ReadFile(source)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read ASSISTANT_MODELS file: %w", err)
+		}
+	}
+
+	var overrides map[string]ModelTable
+
+	if err := json.Unmarshal(data, &overrides); err != nil {
+		return nil, fmt.Errorf("failed to parse ASSISTANT_MODELS: %w", err)
+	}
+
+	for name, table := range overrides {
+		tables[name] = tables[name].merge(table)
+	}
+
+	return tables, nil
+}
+
+// modelResolver is implemented by assistants that know which models they
+// accept.
+type modelResolver interface {
+	ResolveModel(model string) (string, error)
+}
+
+// ResolveModel returns the model to pass to the assistant's WithModel for a
+// requested alias or model name, failing for names the assistant does not
+// know. Assistants wrapped with WithModelTable check their table and
+// failover chains check the tables of their links. Any other assistant is
+// given the name unchanged.
+func ResolveModel(assistant models.Assistant, model string) (string, error) {
+	if resolver, ok := assistant.(modelResolver); ok {
+		return resolver.ResolveModel(model)
+	}
+
+	return model, nil
+}
+
+// defaultModeler is implemented by assistants that report the model they
+// use when none is chosen.
+type defaultModeler interface {
+	DefaultModel() string
+}
+
+// DefaultModel returns the model the assistant uses when none is chosen with
+// WithModel, or "" when it does not say, as for failover chains whose links
+// each use their own.
+func DefaultModel(assistant models.Assistant) string {
+	if modeler, ok := assistant.(defaultModeler); ok {
+		return modeler.DefaultModel()
+	}
+
+	return ""
+}
+
+// WithModelTable wraps an assistant so that WithModel accepts aliases from
+// the table, letting generators request a tier for a sub-task.
+func WithModelTable(assistant models.Assistant, table ModelTable) models.Assistant {
+	return &modelTableAssistant{
+		Assistant: assistant,
+		table:     table,
+	}
+}
+
+type modelTableAssistant struct {
+	models.Assistant
+	table ModelTable
+}
+
+func (a *modelTableAssistant) WithModel(ctx context.Context, model string) context.Context {
+	return a.Assistant.WithModel(ctx, a.table.Lookup(model))
+}
+
+// ResolveModel resolves the model through the table. An empty table leaves
+// the check to the wrapped assistant, so wrapping a failover chain keeps its
+// validation.
+func (a *modelTableAssistant) ResolveModel(model string) (string, error) {
+	if len(a.table.Aliases) == 0 && len(a.table.Models) == 0 {
+		return ResolveModel(a.Assistant, model)
+	}
+
+	return a.table.Resolve(model)
+}
+
+func (a *modelTableAssistant) DefaultModel() string {
+	return DefaultModel(a.Assistant)
+}
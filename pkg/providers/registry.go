@@ -0,0 +1,160 @@
+package providers
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/schraf/assistant/pkg/models"
+)
+
+const DefaultProvider = "gemini"
+
+var (
+	lock      sync.RWMutex
+	providers map[string]Factory
+)
+
+type Config map[string]any
+
+type Factory func(ctx context.Context, config Config) (models.Assistant, error)
+
+func Register(name string, factory Factory) error {
+	lock.Lock()
+	defer lock.Unlock()
+
+	if providers == nil {
+		providers = make(map[string]Factory)
+	}
+
+	if _, exists := providers[name]; exists {
+		return fmt.Errorf("provider '%s' is already registered", name)
+	}
+
+	providers[name] = factory
+	return nil
+}
+
+func MustRegister(name string, factory Factory) {
+	if err := Register(name, factory); err != nil {
+		panic(fmt.Sprintf("failed to register provider: %v", err))
+	}
+}
+
+func Create(ctx context.Context, name string, config Config) (models.Assistant, error) {
+	lock.RLock()
+	factory, exists := providers[name]
+	lock.RUnlock()
+
+	if !exists {
+		return nil, fmt.Errorf("unknown assistant provider: %s", name)
+	}
+
+	return factory(ctx, config)
+}
+
+// failoverEnv maps the failover provider's config keys to the environment.
+var failoverEnv = map[string]string{
+	"chain":             "ASSISTANT_FAILOVER",
+	"failure_threshold": "ASSISTANT_FAILOVER_THRESHOLD",
+	"cooldown":          "ASSISTANT_FAILOVER_COOLDOWN",
+	"retry_blocked":     "ASSISTANT_FAILOVER_RETRY_BLOCKED",
+}
+
+// FromEnv returns the provider name and config selected by the environment.
+// ASSISTANT_PROVIDER names the provider (defaulting to gemini) and
+// ASSISTANT_CONCURRENCY, when set, becomes the "concurrency" config value.
+// The failover provider reads its chain and circuit breaker settings from
+// ASSISTANT_FAILOVER, ASSISTANT_FAILOVER_THRESHOLD,
+// ASSISTANT_FAILOVER_COOLDOWN and ASSISTANT_FAILOVER_RETRY_BLOCKED.
+func FromEnv() (string, Config) {
+	name := strings.ToLower(os.Getenv("ASSISTANT_PROVIDER"))
+	if name == "" {
+		name = DefaultProvider
+	}
+
+	config := Config{}
+
+	if concurrency := os.Getenv("ASSISTANT_CONCURRENCY"); concurrency != "" {
+		config["concurrency"] = concurrency
+	}
+
+	for key, env := range failoverEnv {
+		if value := os.Getenv(env); value != "" {
+			config[key] = value
+		}
+	}
+
+	return name, config
+}
+
+// Int reads an integer config value, accepting JSON numbers as well as the
+// strings produced by environment variables and X-Config-* headers.
+func (c Config) Int(key string, fallback int) (int, error) {
+	value, ok := c[key]
+	if !ok || value == nil {
+		return fallback, nil
+	}
+
+	switch v := value.(type) {
+	case int:
+		return v, nil
+	case float64:
+		return int(v), nil
+	case string:
+		n, err := strconv.Atoi(strings.TrimSpace(v))
+		if err != nil {
+			return 0, fmt.Errorf("invalid %s config value '%s': %w", key, v, err)
+		}
+		return n, nil
+	default:
+		return 0, fmt.Errorf("invalid %s config value of type %T", key, value)
+	}
+}
+
+// Duration reads a duration config value such as "30s" or "5m".
+func (c Config) Duration(key string, fallback time.Duration) (time.Duration, error) {
+	value, ok := c[key]
+	if !ok || value == nil {
+		return fallback, nil
+	}
+
+	switch v := value.(type) {
+	case time.Duration:
+		return v, nil
+	case string:
+		d, err := time.ParseDuration(strings.TrimSpace(v))
+		if err != nil {
+			return 0, fmt.Errorf("invalid %s config value '%s': %w", key, v, err)
+		}
+		return d, nil
+	default:
+		return 0, fmt.Errorf("invalid %s config value of type %T", key, value)
+	}
+}
+
+// Bool reads a boolean config value, accepting the strings understood by
+// strconv.ParseBool.
+func (c Config) Bool(key string, fallback bool) (bool, error) {
+	value, ok := c[key]
+	if !ok || value == nil {
+		return fallback, nil
+	}
+
+	switch v := value.(type) {
+	case bool:
+		return v, nil
+	case string:
+		b, err := strconv.ParseBool(strings.TrimSpace(v))
+		if err != nil {
+			return false, fmt.Errorf("invalid %s config value '%s': %w", key, v, err)
+		}
+		return b, nil
+	default:
+		return false, fmt.Errorf("invalid %s config value of type %T", key, value)
+	}
+}
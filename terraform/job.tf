@@ -17,6 +17,31 @@ resource "google_cloud_run_v2_job" "assistant" {
           value = var.google_api_key
         }
 
+        env {
+          name  = "ASSISTANT_PROVIDER"
+          value = var.assistant_provider
+        }
+
+        env {
+          name  = "ASSISTANT_CONCURRENCY"
+          value = var.assistant_concurrency
+        }
+
+        env {
+          name  = "ASSISTANT_MODELS"
+          value = var.assistant_models
+        }
+
+        env {
+          name  = "ASSISTANT_FAILOVER"
+          value = var.assistant_failover
+        }
+
+        env {
+          name  = "TRANSCRIPT_DIR"
+          value = var.transcripts ? "/transcripts" : ""
+        }
+
         env {
           name  = "TELEGRAPH_API_KEY"
           value = var.telegraph_api_key
@@ -63,8 +88,26 @@ resource "google_cloud_run_v2_job" "assistant" {
             memory = "1Gi"
           }
         }
+
+        volume_mounts {
+          name       = "transcripts"
+          mount_path = "/transcripts"
+        }
       }
 
+      # The container's filesystem is discarded with the execution, so
+      # transcripts are written to a Cloud Storage volume
+      volumes {
+        name = "transcripts"
+        gcs {
+          bucket    = google_storage_bucket.transcripts.name
+          read_only = false
+        }
+      }
+
+      # Cloud Storage volumes need the second generation environment
+      execution_environment = "EXECUTION_ENVIRONMENT_GEN2"
+
       timeout     = "3600s"
       max_retries = 2
     }
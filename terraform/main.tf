@@ -25,6 +25,7 @@ resource "google_project_service" "required_apis" {
     "run.googleapis.com",
     "artifactregistry.googleapis.com",
     "cloudbuild.googleapis.com",
+    "storage.googleapis.com",
   ])
 
   project = var.project_id
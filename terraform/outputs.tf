@@ -39,3 +39,7 @@ output "api_key" {
   sensitive   = true
 }
 
+output "transcripts_bucket" {
+  description = "Cloud Storage bucket holding the job's prompt transcripts"
+  value       = google_storage_bucket.transcripts.name
+}
@@ -38,6 +38,11 @@ resource "google_cloud_run_v2_service" "assistant" {
         value = var.region
       }
 
+      env {
+        name  = "ATTACHMENTS_BUCKET"
+        value = google_storage_bucket.attachments.name
+      }
+
       resources {
         limits = {
           cpu    = "1"
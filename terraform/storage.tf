@@ -0,0 +1,48 @@
+# Bucket the service stages request attachments in for the job to read
+resource "google_storage_bucket" "attachments" {
+  name     = "${var.project_id}-assistant-attachments"
+  location = var.region
+
+  uniform_bucket_level_access = true
+
+  # The job leaves the attachments in place for its retries
+  lifecycle_rule {
+    condition {
+      age = 1
+    }
+    action {
+      type = "Delete"
+    }
+  }
+
+  depends_on = [
+    google_project_service.required_apis,
+  ]
+}
+
+# Grant the service account permission to write and read the attachments
+resource "google_storage_bucket_iam_member" "assistant_attachments" {
+  bucket = google_storage_bucket.attachments.name
+  role   = "roles/storage.objectUser"
+  member = "serviceAccount:${google_service_account.assistant.email}"
+}
+
+# Bucket mounted on the job for the prompt transcripts, which are kept for
+# auditing and replaying requests
+resource "google_storage_bucket" "transcripts" {
+  name     = "${var.project_id}-assistant-transcripts"
+  location = var.region
+
+  uniform_bucket_level_access = true
+
+  depends_on = [
+    google_project_service.required_apis,
+  ]
+}
+
+# Grant the service account permission to write and read the transcripts
+resource "google_storage_bucket_iam_member" "assistant_transcripts" {
+  bucket = google_storage_bucket.transcripts.name
+  role   = "roles/storage.objectUser"
+  member = "serviceAccount:${google_service_account.assistant.email}"
+}
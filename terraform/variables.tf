@@ -21,6 +21,36 @@ variable "google_api_key" {
   sensitive   = true
 }
 
+variable "assistant_provider" {
+  description = "Assistant provider used by the job (gemini, ollama, openai)"
+  type        = string
+  default     = "gemini"
+}
+
+variable "assistant_concurrency" {
+  description = "Maximum concurrent requests the job makes to the assistant provider"
+  type        = string
+  default     = "3"
+}
+
+variable "assistant_models" {
+  description = "JSON model alias tables keyed by provider, merged over the built-in tables"
+  type        = string
+  default     = ""
+}
+
+variable "assistant_failover" {
+  description = "Failover chain of provider[:model] entries used when assistant_provider is failover, e.g. gemini:pro,gemini:basic,ollama"
+  type        = string
+  default     = ""
+}
+
+variable "transcripts" {
+  description = "Whether the job writes prompt transcripts to the transcripts bucket"
+  type        = bool
+  default     = true
+}
+
 variable "telegraph_api_key" {
   description = "API key for posting to Telegra.ph"
   type        = string